@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/node"
+)
+
+// startShellNode brings up an in-process node for driving the shell.
+func startShellNode(t *testing.T) *node.Node {
+	t.Helper()
+	n, err := node.NewNode(context.Background(), node.NodeConfig{
+		Interests: []string{"machine learning"},
+		Threshold: 0.1,
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func runCommand(t *testing.T, n *node.Node, line string) string {
+	t.Helper()
+	var out bytes.Buffer
+	s := &shell{n: n, out: &out}
+	if quit := s.handle(line); quit {
+		t.Fatalf("command %q requested shell exit", line)
+	}
+	return out.String()
+}
+
+func TestGetCommandRoundTrip(t *testing.T) {
+	n := startShellNode(t)
+	hash, err := n.Publish([]byte("stored via the shell"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	out := runCommand(t, n, "get "+hash.Hex())
+	if !strings.Contains(out, "stored via the shell") {
+		t.Fatalf("get output %q missing content", out)
+	}
+}
+
+func TestReconstructCommandVerifiesChain(t *testing.T) {
+	n := startShellNode(t)
+	root, err := n.Publish([]byte("root content"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	tip, err := n.PublishExtension(root, []byte(" and an extension"))
+	if err != nil {
+		t.Fatalf("PublishExtension: %v", err)
+	}
+
+	out := runCommand(t, n, "reconstruct "+tip.Hex())
+	if !strings.Contains(out, "verified") {
+		t.Fatalf("reconstruct output %q missing verification line", out)
+	}
+	if !strings.Contains(out, "root content and an extension") {
+		t.Fatalf("reconstruct output %q missing reconstructed content", out)
+	}
+}
+
+func TestHashCommandsRejectBadInput(t *testing.T) {
+	n := startShellNode(t)
+	if out := runCommand(t, n, "get not-hex"); !strings.Contains(out, "bad hash") {
+		t.Fatalf("get output %q missing parse error", out)
+	}
+	if out := runCommand(t, n, "reconstruct"); !strings.Contains(out, "usage:") {
+		t.Fatalf("reconstruct output %q missing usage", out)
+	}
+}
+
+func TestQueryCommandReturnsRemoteMatches(t *testing.T) {
+	a := startShellNode(t)
+	b, err := node.NewNode(context.Background(), node.NodeConfig{
+		BootstrapPeer: a.AddrStrings()[0],
+		Interests:     []string{"machine learning"},
+		Threshold:     0.1,
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	if _, err := b.Publish([]byte("neural networks are a machine learning technique")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	// Give gossipsub a moment to build the mesh before querying.
+	time.Sleep(2 * time.Second)
+
+	out := runCommand(t, a, "query machine learning")
+	if strings.Contains(out, "0 match(es)") {
+		t.Fatalf("query output %q has no matches", out)
+	}
+}
+
+func TestQuitCommandExitsShell(t *testing.T) {
+	n := startShellNode(t)
+	var out bytes.Buffer
+	s := &shell{n: n, out: &out}
+	if !s.handle("quit") {
+		t.Fatal("quit did not request shell exit")
+	}
+}
+
+func TestStatsCommandJSON(t *testing.T) {
+	n := startShellNode(t)
+	var out bytes.Buffer
+	s := &shell{n: n, out: &out, json: true}
+	if s.handle("stats") {
+		t.Fatal("stats requested shell exit")
+	}
+
+	var stats core.GatekeeperStats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("stats output %q is not JSON: %v", out.String(), err)
+	}
+}
+
+func TestPublishCommandJSON(t *testing.T) {
+	n := startShellNode(t)
+	var out bytes.Buffer
+	s := &shell{n: n, out: &out, json: true}
+	if s.handle("publish json mode content") {
+		t.Fatal("publish requested shell exit")
+	}
+
+	var published struct {
+		Published string `json:"published"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &published); err != nil {
+		t.Fatalf("publish output %q is not JSON: %v", out.String(), err)
+	}
+	if published.Published == "" {
+		t.Fatal("publish confirmation missing hash")
+	}
+}
+
+func TestVerifyAndChainCommands(t *testing.T) {
+	n := startShellNode(t)
+	root, err := n.Publish([]byte("root content"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	mid, err := n.PublishExtension(root, []byte(" plus one"))
+	if err != nil {
+		t.Fatalf("PublishExtension: %v", err)
+	}
+	tip, err := n.PublishExtension(mid, []byte(" plus two"))
+	if err != nil {
+		t.Fatalf("PublishExtension: %v", err)
+	}
+
+	// The published root is itself stored as an extension of the zero
+	// hash, so both commands count that genesis link.
+	out := runCommand(t, n, "verify "+root.Hex()+" "+tip.Hex())
+	if !strings.Contains(out, "3 link(s) valid") {
+		t.Fatalf("verify output %q missing valid chain summary", out)
+	}
+
+	out = runCommand(t, n, "chain "+tip.Hex())
+	if !strings.Contains(out, root.Hex()[:12]) || !strings.Contains(out, tip.Hex()[:12]) {
+		t.Fatalf("chain output %q missing link hashes", out)
+	}
+	if !strings.Contains(out, crypto.Zero().Hex()[:12]) {
+		t.Fatalf("chain output %q missing genesis link", out)
+	}
+	if !strings.Contains(out, "9 byte(s)") {
+		t.Fatalf("chain output %q missing delta length", out)
+	}
+	if !strings.Contains(out, "local") {
+		t.Fatalf("chain output %q missing publisher column", out)
+	}
+	if !strings.Contains(out, "3 link(s)") {
+		t.Fatalf("chain output %q missing link count", out)
+	}
+
+	if out := runCommand(t, n, "verify "+root.Hex()); !strings.Contains(out, "usage:") {
+		t.Fatalf("verify output %q missing usage", out)
+	}
+}
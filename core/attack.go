@@ -0,0 +1,109 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// AttackConfig shapes one SimulateAttack run: a defending interest and
+// a mix of legitimate and hostile extensions to throw at it.
+type AttackConfig struct {
+	// Interest and Threshold configure the defending gatekeeper.
+	Interest  string
+	Threshold float64
+	// Legitimate is how many on-interest extensions to send.
+	Legitimate int
+	// CryptoInvalid is how many extensions with forged child hashes to
+	// send — the cheap spam the crypto gate exists for.
+	CryptoInvalid int
+	// Irrelevant is how many crypto-valid but off-interest extensions
+	// to send — spam that can only be caught semantically.
+	Irrelevant int
+	// Seed drives the generator; equal configs produce equal reports.
+	Seed int64
+}
+
+// AttackReport quantifies how the gatekeeper held up.
+type AttackReport struct {
+	TotalSent int `json:"total_sent"`
+	// FalsePositives counts legitimate extensions blocked;
+	// FalseNegatives counts spam forwarded. The rates divide by the
+	// size of the respective population, zero when it is empty.
+	FalsePositives    int     `json:"false_positives"`
+	FalseNegatives    int     `json:"false_negatives"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	FalseNegativeRate float64 `json:"false_negative_rate"`
+	BlockRate         float64 `json:"block_rate"`
+	Stats             GatekeeperStats
+}
+
+// irrelevantVocabulary is the word pool off-interest spam is drawn
+// from; deliberately far from any plausible technical interest.
+var irrelevantVocabulary = []string{
+	"gardening", "recipe", "soup", "kayak", "ledger", "violin",
+	"stadium", "harvest", "pottery", "meadow", "sourdough", "falcon",
+	"tapestry", "lighthouse", "orchard", "accordion",
+}
+
+// SimulateAttack runs a configurable mix of legitimate extensions,
+// crypto-forged spam, and semantically irrelevant spam through one
+// gatekeeper and reports how much of each got through. It is the
+// benchmark behind TERA's spam-resistance claim, exported so
+// deployments can measure their own threshold choices.
+func SimulateAttack(cfg AttackConfig) AttackReport {
+	gk := NewGatekeeper()
+	query := NewQuery(cfg.Interest, cfg.Threshold)
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	type shot struct {
+		ext   *Extension
+		legit bool
+	}
+	shots := make([]shot, 0, cfg.Legitimate+cfg.CryptoInvalid+cfg.Irrelevant)
+	for i := 0; i < cfg.Legitimate; i++ {
+		data := []byte(fmt.Sprintf("%s, update %d: further notes on %s", cfg.Interest, i, cfg.Interest))
+		shots = append(shots, shot{ext: NewExtension(crypto.Zero(), data), legit: true})
+	}
+	for i := 0; i < cfg.CryptoInvalid; i++ {
+		// Forged spam mimics the interest so only the crypto gate can
+		// catch it.
+		data := []byte(fmt.Sprintf("%s, forged batch %d", cfg.Interest, i))
+		ext := NewExtension(crypto.Zero(), data)
+		ext.Child = crypto.HashElement([]byte(fmt.Sprintf("forged %d", i)))
+		shots = append(shots, shot{ext: ext})
+	}
+	for i := 0; i < cfg.Irrelevant; i++ {
+		words := make([]byte, 0, 64)
+		for w := 0; w < 6; w++ {
+			if w > 0 {
+				words = append(words, ' ')
+			}
+			words = append(words, irrelevantVocabulary[rng.Intn(len(irrelevantVocabulary))]...)
+		}
+		words = append(words, []byte(fmt.Sprintf(" %d", i))...)
+		shots = append(shots, shot{ext: NewExtension(crypto.Zero(), words)})
+	}
+	rng.Shuffle(len(shots), func(i, j int) { shots[i], shots[j] = shots[j], shots[i] })
+
+	report := AttackReport{TotalSent: len(shots)}
+	for _, s := range shots {
+		decision := gk.ShouldForward(s.ext, query)
+		if s.legit && !decision.Forward {
+			report.FalsePositives++
+		}
+		if !s.legit && decision.Forward {
+			report.FalseNegatives++
+		}
+	}
+	report.Stats = gk.GetStats()
+	report.BlockRate = report.Stats.BlockRate
+	if cfg.Legitimate > 0 {
+		report.FalsePositiveRate = float64(report.FalsePositives) / float64(cfg.Legitimate)
+	}
+	if spam := cfg.CryptoInvalid + cfg.Irrelevant; spam > 0 {
+		report.FalseNegativeRate = float64(report.FalseNegatives) / float64(spam)
+	}
+	return report
+}
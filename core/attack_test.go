@@ -0,0 +1,42 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimulateAttackBlocksForgedSpam(t *testing.T) {
+	cfg := AttackConfig{
+		Interest:      "machine learning models",
+		Threshold:     0.3,
+		Legitimate:    50,
+		CryptoInvalid: 50,
+		Irrelevant:    50,
+		Seed:          1,
+	}
+	report := SimulateAttack(cfg)
+
+	if report.TotalSent != 150 {
+		t.Fatalf("sent %d extensions, want 150", report.TotalSent)
+	}
+	// Crypto-forged spam must be blocked without exception.
+	if report.Stats.CryptoBlocked != cfg.CryptoInvalid {
+		t.Fatalf("crypto gate blocked %d of %d forged extensions",
+			report.Stats.CryptoBlocked, cfg.CryptoInvalid)
+	}
+	if report.FalsePositives != 0 {
+		t.Fatalf("%d legitimate extensions blocked", report.FalsePositives)
+	}
+	// Semantic false negatives can exist in principle; at this
+	// threshold the off-interest vocabulary should stay well under it.
+	if report.FalseNegativeRate > 0.05 {
+		t.Fatalf("false negative rate %.3f, want <= 0.05", report.FalseNegativeRate)
+	}
+	if report.BlockRate <= 0 || report.BlockRate >= 1 {
+		t.Fatalf("block rate %.3f outside (0,1) for a mixed load", report.BlockRate)
+	}
+
+	if again := SimulateAttack(cfg); !reflect.DeepEqual(again, report) {
+		t.Fatalf("same seed produced a different report: %+v vs %+v", again, report)
+	}
+}
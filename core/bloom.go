@@ -0,0 +1,79 @@
+package core
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a fixed-memory alternative to the LRU seen cache: it
+// remembers which keys were added with no per-entry allocation, at the
+// cost of a configurable false-positive rate. It cannot store the
+// decision itself, only membership, so a hit means "skip" rather than
+// "replay the cached decision".
+type bloomFilter struct {
+	bits   []uint64
+	nbits  uint64
+	hashes int
+}
+
+// newBloomFilter sizes a filter for the expected number of keys and
+// target false-positive rate using the standard optimal-m/k formulas.
+func newBloomFilter(expected int, fpRate float64) *bloomFilter {
+	if expected < 1 {
+		expected = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(expected) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	nbits := uint64(m)
+	if nbits < 64 {
+		nbits = 64
+	}
+	return &bloomFilter{
+		bits:   make([]uint64, (nbits+63)/64),
+		nbits:  nbits,
+		hashes: k,
+	}
+}
+
+// indexes derives the filter's k bit positions for key via double
+// hashing over one 64-bit FNV digest.
+func (bf *bloomFilter) indexes(key string) (h1, h2 uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1 = sum
+	h2 = sum>>33 | sum<<31
+	if h2 == 0 {
+		h2 = 1
+	}
+	return h1, h2
+}
+
+// add marks key as seen.
+func (bf *bloomFilter) add(key string) {
+	h1, h2 := bf.indexes(key)
+	for i := 0; i < bf.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % bf.nbits
+		bf.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// contains reports whether key was (probably) added before. False
+// positives occur at roughly the configured rate; false negatives
+// never.
+func (bf *bloomFilter) contains(key string) bool {
+	h1, h2 := bf.indexes(key)
+	for i := 0; i < bf.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % bf.nbits
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
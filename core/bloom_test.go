@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+func TestBloomFalsePositiveRateNearTarget(t *testing.T) {
+	const expected = 10000
+	const target = 0.01
+	bf := newBloomFilter(expected, target)
+	for i := 0; i < expected; i++ {
+		bf.add(fmt.Sprintf("member-%d", i))
+	}
+
+	for i := 0; i < expected; i++ {
+		if !bf.contains(fmt.Sprintf("member-%d", i)) {
+			t.Fatalf("false negative for member-%d", i)
+		}
+	}
+
+	falsePositives := 0
+	const probes = 100000
+	for i := 0; i < probes; i++ {
+		if bf.contains(fmt.Sprintf("stranger-%d", i)) {
+			falsePositives++
+		}
+	}
+	rate := float64(falsePositives) / probes
+	if rate > 3*target {
+		t.Fatalf("false-positive rate %.4f far above target %.4f", rate, target)
+	}
+}
+
+func TestGatekeeperBloomSkipsDuplicates(t *testing.T) {
+	g := NewGatekeeperWithBloom(1000, 0.01)
+	ext := NewExtension(crypto.Zero(), []byte("machine learning models learn from data"))
+	queries := []*Query{NewQuery("machine learning", 0.1)}
+
+	first := g.ShouldForwardAny(ext, queries)
+	if !first.Forward {
+		t.Fatalf("first delivery blocked: %s", first.Reason)
+	}
+	second := g.ShouldForwardAny(ext, queries)
+	if second.Forward {
+		t.Fatal("duplicate delivery forwarded")
+	}
+	stats := g.GetStats()
+	if stats.SeenCacheHits != 1 || stats.TotalSeen != 1 {
+		t.Fatalf("stats = %+v, want one fresh decision and one dedup hit", stats)
+	}
+}
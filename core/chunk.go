@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// ChunkContent splits large data into a root plus a chain of
+// extensions, one per chunk, so a big publish streams through the same
+// verifiable extension mechanism as any append. The chain's final
+// hash is the homomorphic sum of the chunk hashes, so receivers verify
+// each chunk incrementally as it arrives. A chunkSize at or below zero,
+// or data that fits in one chunk, returns just the root.
+func ChunkContent(data []byte, chunkSize int) (root *Content, exts []*Extension) {
+	if chunkSize <= 0 || len(data) <= chunkSize {
+		return NewContent(data), nil
+	}
+	root = NewContent(data[:chunkSize])
+	tip := root.Hash
+	for off := chunkSize; off < len(data); off += chunkSize {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		ext := NewExtension(tip, data[off:end])
+		exts = append(exts, ext)
+		tip = ext.Child
+	}
+	return root, exts
+}
+
+// Reassemble inverts ChunkContent: it concatenates the root and the
+// extension deltas back into the original bytes, verifying each link
+// along the way. The extensions must be in chain order starting from
+// the root.
+func Reassemble(root *Content, exts []*Extension) ([]byte, error) {
+	data := append([]byte(nil), root.Data...)
+	tip := root.Hash
+	for i, ext := range exts {
+		if !ext.Parent.Equal(tip) {
+			return nil, fmt.Errorf("extension %d parents %s, want chain tip %s", i, ext.Parent, tip)
+		}
+		if !ext.Verify() {
+			return nil, fmt.Errorf("extension %d failed verification: %s", i, ext)
+		}
+		data = append(data, ext.Delta...)
+		tip = ext.Child
+	}
+	return data, nil
+}
+
+// ChunkedHash returns the hash the chain built by ChunkContent ends
+// at: the homomorphic sum of the chunk element hashes.
+func ChunkedHash(data []byte, chunkSize int) *crypto.Hash {
+	if chunkSize <= 0 || len(data) <= chunkSize {
+		return crypto.HashElement(data)
+	}
+	tip := crypto.Zero()
+	for off := 0; off < len(data); off += chunkSize {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		tip = crypto.Extend(tip, data[off:end])
+	}
+	return tip
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+func TestChunkContentRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 40)
+	const chunkSize = 128
+
+	root, exts := ChunkContent(data, chunkSize)
+	if len(exts) == 0 {
+		t.Fatal("large content produced no extensions")
+	}
+	for i, ext := range exts {
+		if !ext.Verify() {
+			t.Fatalf("chunk extension %d does not verify", i)
+		}
+	}
+
+	got, err := Reassemble(root, exts)
+	if err != nil {
+		t.Fatalf("Reassemble: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("reassembled bytes differ from original")
+	}
+
+	// The chain tip is the homomorphic sum of the chunk hashes.
+	tip := exts[len(exts)-1].Child
+	if !tip.Equal(ChunkedHash(data, chunkSize)) {
+		t.Fatal("chain tip differs from the aggregate chunk hash")
+	}
+	chunks := make([][]byte, 0, len(exts)+1)
+	chunks = append(chunks, root.Data)
+	for _, ext := range exts {
+		chunks = append(chunks, ext.Delta)
+	}
+	if !tip.Equal(crypto.HashSet(chunks)) {
+		t.Fatal("chain tip differs from HashSet over the chunks")
+	}
+}
+
+func TestChunkContentSmallData(t *testing.T) {
+	data := []byte("fits in one chunk")
+	root, exts := ChunkContent(data, 1024)
+	if len(exts) != 0 {
+		t.Fatalf("small content produced %d extensions, want 0", len(exts))
+	}
+	if !root.Hash.Equal(crypto.HashElement(data)) {
+		t.Fatal("single-chunk root hash differs from HashElement")
+	}
+}
+
+func TestReassembleRejectsTamperedChunk(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefgh"), 64)
+	root, exts := ChunkContent(data, 64)
+	exts[1].Delta = []byte("tampered")
+	if _, err := Reassemble(root, exts); err == nil {
+		t.Fatal("Reassemble accepted a tampered chunk")
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+// BoolOp is a logical combinator over sub-queries.
+type BoolOp int
+
+const (
+	// OpLeaf marks a node that wraps a single Query.
+	OpLeaf BoolOp = iota
+	OpAnd
+	OpOr
+	OpNot
+)
+
+// CompoundQuery combines queries logically, so interests like
+// "machine learning AND NOT cooking" can be expressed. Each leaf
+// keeps its own kernel params and threshold.
+type CompoundQuery struct {
+	Op       BoolOp
+	Leaf     *Query
+	Children []*CompoundQuery
+}
+
+// Leaf wraps a single query as a compound leaf.
+func Leaf(q *Query) *CompoundQuery {
+	return &CompoundQuery{Op: OpLeaf, Leaf: q}
+}
+
+// And matches when every child matches.
+func And(children ...*CompoundQuery) *CompoundQuery {
+	return &CompoundQuery{Op: OpAnd, Children: children}
+}
+
+// Or matches when at least one child matches.
+func Or(children ...*CompoundQuery) *CompoundQuery {
+	return &CompoundQuery{Op: OpOr, Children: children}
+}
+
+// Not matches when its child does not.
+func Not(child *CompoundQuery) *CompoundQuery {
+	return &CompoundQuery{Op: OpNot, Children: []*CompoundQuery{child}}
+}
+
+// Matches evaluates the boolean tree against content.
+func (cq *CompoundQuery) Matches(c *Content) bool {
+	switch cq.Op {
+	case OpLeaf:
+		return cq.Leaf.Matches(c)
+	case OpAnd:
+		for _, child := range cq.Children {
+			if !child.Matches(c) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, child := range cq.Children {
+			if child.Matches(c) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return len(cq.Children) == 1 && !cq.Children[0].Matches(c)
+	default:
+		return false
+	}
+}
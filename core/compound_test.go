@@ -0,0 +1,52 @@
+package core
+
+import "testing"
+
+func TestCompoundAnd(t *testing.T) {
+	q := And(
+		Leaf(NewQuery("machine learning", 0.15)),
+		Leaf(NewQuery("neural networks", 0.15)),
+	)
+	both := NewContent([]byte("machine learning with neural networks"))
+	onlyOne := NewContent([]byte("machine learning with decision trees and gradient boosting"))
+
+	if !q.Matches(both) {
+		t.Fatal("content matching both terms rejected by AND")
+	}
+	if q.Matches(onlyOne) {
+		t.Fatal("content matching one term accepted by AND")
+	}
+}
+
+func TestCompoundOr(t *testing.T) {
+	q := Or(
+		Leaf(NewQuery("machine learning", 0.15)),
+		Leaf(NewQuery("sourdough baking", 0.15)),
+	)
+	ml := NewContent([]byte("notes on machine learning models"))
+	bread := NewContent([]byte("my sourdough baking experiments"))
+	neither := NewContent([]byte("quarterly report on steel production tonnage"))
+
+	if !q.Matches(ml) || !q.Matches(bread) {
+		t.Fatal("OR rejected content matching one branch")
+	}
+	if q.Matches(neither) {
+		t.Fatal("OR accepted unrelated content")
+	}
+}
+
+func TestCompoundNot(t *testing.T) {
+	q := And(
+		Leaf(NewQuery("machine learning", 0.15)),
+		Not(Leaf(NewQuery("cooking recipes", 0.15))),
+	)
+	clean := NewContent([]byte("machine learning model evaluation"))
+	spammy := NewContent([]byte("machine learning cooking recipes cooking recipes"))
+
+	if !q.Matches(clean) {
+		t.Fatal("AND NOT rejected clean content")
+	}
+	if q.Matches(spammy) {
+		t.Fatal("AND NOT accepted content matching the negated term")
+	}
+}
@@ -0,0 +1,120 @@
+// Package core ties the crypto and semantic layers together: content
+// carries both a homomorphic hash and a semantic fingerprint, queries
+// express interests over that fingerprint, and the gatekeeper decides
+// whether an extension is authentic and relevant enough to forward.
+package core
+
+import (
+	"encoding/base32"
+	"fmt"
+	"time"
+
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/semantic"
+)
+
+// Content is a piece of data addressed both cryptographically (for
+// verification) and semantically (for relevance).
+type Content struct {
+	// ID is an optional caller-supplied label; it plays no part in
+	// addressing.
+	ID       string
+	Data     []byte
+	Hash     *crypto.Hash
+	Features *semantic.Features
+}
+
+// NewContent hashes and fingerprints data.
+func NewContent(data []byte) *Content {
+	return &Content{
+		Data:     data,
+		Hash:     crypto.HashElement(data),
+		Features: semantic.ExtractFeatures(data),
+	}
+}
+
+// contentIDEncoding is unpadded lowercase base32, chosen so content
+// IDs are short, URL-safe, and case-stable in systems that fold case.
+var contentIDEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// ContentID returns a stable, URL-safe identifier for the content:
+// the unpadded lowercase base32 encoding of its 32-byte crypto hash.
+// Unlike the free-form ID field it is derived, so the same data always
+// yields the same identifier.
+func (c *Content) ContentID() string {
+	return contentIDEncoding.EncodeToString(c.Hash.Bytes())
+}
+
+// ParseContentID recovers the crypto hash behind a ContentID.
+func ParseContentID(id string) (*crypto.Hash, error) {
+	b, err := contentIDEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content ID: %w", err)
+	}
+	return crypto.FromBytes(b)
+}
+
+// Extension is an append to existing content: a delta plus the parent
+// and resulting child hashes that let any node verify the append
+// without the full history.
+type Extension struct {
+	Parent    *crypto.Hash
+	Child     *crypto.Hash
+	Delta     []byte
+	Content   *Content
+	Publisher string
+	Timestamp int64
+	// ValidFrom and ValidUntil bound when receivers may accept the
+	// extension (UnixNano); zero leaves that side of the window open.
+	// A signed window stops pre-signed extensions from being released
+	// early or replayed long after the fact.
+	ValidFrom  int64
+	ValidUntil int64
+	// Deleted marks a tombstone: an empty-delta extension that
+	// retracts the parent content instead of appending to it.
+	Deleted bool
+}
+
+// NewExtension builds an extension of an existing chain tip. The
+// parent hash is all that is needed; the chain's content does not have
+// to be present locally.
+func NewExtension(parent *crypto.Hash, delta []byte) *Extension {
+	return &Extension{
+		Parent:    parent,
+		Child:     crypto.Extend(parent, delta),
+		Delta:     delta,
+		Content:   NewContent(delta),
+		Timestamp: time.Now().UnixNano(),
+	}
+}
+
+// NewTombstone builds a deletion marker for the content at parent. It
+// carries no data; its crypto claim is the same parent/child relation
+// as any extension, so receivers verify it the usual way before
+// honoring the retraction.
+func NewTombstone(parent *crypto.Hash) *Extension {
+	ext := NewExtension(parent, nil)
+	ext.Deleted = true
+	return ext
+}
+
+// Extend appends delta to the content, returning the extension that
+// describes the append. The extension's Content is the delta itself,
+// which is what gatekeepers judge for relevance.
+func (c *Content) Extend(delta []byte) *Extension {
+	return NewExtension(c.Hash, delta)
+}
+
+// Verify checks the extension's crypto claim: that Child really is
+// Parent extended by Delta.
+func (e *Extension) Verify() bool {
+	if e.Parent == nil || e.Child == nil {
+		return false
+	}
+	return crypto.VerifyExtension(e.Parent, e.Child, e.Delta)
+}
+
+// String summarizes the extension for logs.
+func (e *Extension) String() string {
+	return fmt.Sprintf("extension %s -> %s (%d bytes)", e.Parent, e.Child, len(e.Delta))
+}
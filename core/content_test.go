@@ -0,0 +1,34 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentIDRoundTrip(t *testing.T) {
+	a := NewContent([]byte("identified content"))
+	b := NewContent([]byte("identified content"))
+
+	id := a.ContentID()
+	if id != b.ContentID() {
+		t.Fatalf("same data produced different IDs: %s vs %s", id, b.ContentID())
+	}
+	if strings.ToLower(id) != id || strings.Contains(id, "=") {
+		t.Fatalf("ID %q is not lowercase unpadded base32", id)
+	}
+
+	hash, err := ParseContentID(id)
+	if err != nil {
+		t.Fatalf("ParseContentID: %v", err)
+	}
+	if !hash.Equal(a.Hash) {
+		t.Fatalf("parsed hash %s, want %s", hash, a.Hash)
+	}
+
+	if _, err := ParseContentID("not!valid@base32"); err == nil {
+		t.Fatal("malformed content ID parsed")
+	}
+	if _, err := ParseContentID("abc"); err == nil {
+		t.Fatal("truncated content ID parsed")
+	}
+}
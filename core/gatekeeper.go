@@ -0,0 +1,393 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/semantic"
+)
+
+// GatekeeperDecision records why an extension was forwarded or
+// blocked: the crypto verdict, the semantic verdict, and the scores
+// behind them.
+type GatekeeperDecision struct {
+	Forward          bool
+	CryptoValid      bool
+	SemanticRelevant bool
+	SimilarityScore  float64
+	Breakdown        semantic.SimilarityBreakdown
+	Reason           string
+}
+
+// MarshalJSON emits the decision with its full component breakdown so
+// operators can log exactly why an extension was forwarded or blocked.
+func (d *GatekeeperDecision) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Forward          bool                         `json:"forward"`
+		CryptoValid      bool                         `json:"crypto_valid"`
+		SemanticRelevant bool                         `json:"semantic_relevant"`
+		SimilarityScore  float64                      `json:"similarity_score"`
+		Breakdown        semantic.SimilarityBreakdown `json:"similarity_breakdown"`
+		Reason           string                       `json:"reason,omitempty"`
+	}{
+		Forward:          d.Forward,
+		CryptoValid:      d.CryptoValid,
+		SemanticRelevant: d.SemanticRelevant,
+		SimilarityScore:  d.SimilarityScore,
+		Breakdown:        d.Breakdown,
+		Reason:           d.Reason,
+	})
+}
+
+// GatekeeperStats are cumulative counters over all decisions.
+// Duplicate deliveries of an already-decided extension count only as
+// SeenCacheHits, not as new decisions.
+type GatekeeperStats struct {
+	TotalSeen       int     `json:"total_seen"`
+	Forwarded       int     `json:"forwarded"`
+	CryptoBlocked   int     `json:"crypto_blocked"`
+	SemanticBlocked int     `json:"semantic_blocked"`
+	WindowBlocked   int     `json:"window_blocked"`
+	SeenCacheHits   int     `json:"seen_cache_hits"`
+	BlockRate       float64 `json:"block_rate"`
+}
+
+// Gatekeeper is the dual filter every incoming extension passes
+// through: crypto verification first (cheap to fake content is the
+// common spam case), then semantic relevance against the node's
+// interest query.
+type Gatekeeper struct {
+	mu          sync.Mutex
+	stats       GatekeeperStats
+	seen        *lruCache
+	bloom       *bloomFilter
+	decisionLog io.Writer
+
+	// audit is a ring of the most recent fresh decisions; auditNext is
+	// the overwrite position once the ring is full. Empty until sized
+	// with SetAuditSize.
+	audit     []AuditEntry
+	auditNext int
+	auditCap  int
+
+	// clockSkew is the tolerated drift when judging validity windows;
+	// zero takes defaultClockSkew.
+	clockSkew time.Duration
+
+	// Exponentially decaying statistics over the similarity scores of
+	// crypto-valid extensions, for threshold auto-tuning.
+	emaScore    float64
+	emaVariance float64
+	emaPrimed   bool
+}
+
+// emaDecay weights each new similarity score in the moving average; at
+// 0.05 the average reflects roughly the last few dozen extensions.
+const emaDecay = 0.05
+
+// defaultSeenCacheSize bounds the decision cache; in a gossip mesh
+// the same extension typically arrives within a short window, so a
+// modest cache absorbs nearly all duplicates.
+const defaultSeenCacheSize = 10000
+
+// NewGatekeeper returns a gatekeeper with zeroed counters and the
+// default-sized seen cache.
+func NewGatekeeper() *Gatekeeper {
+	return NewGatekeeperWithCache(defaultSeenCacheSize)
+}
+
+// NewGatekeeperWithCache sizes the duplicate-decision cache; size <= 0
+// disables caching.
+func NewGatekeeperWithCache(size int) *Gatekeeper {
+	g := &Gatekeeper{}
+	if size > 0 {
+		g.seen = newLRUCache(size)
+	}
+	return g
+}
+
+// NewGatekeeperWithBloom replaces the LRU decision cache with a
+// bloom-filter dedup sized for the expected number of distinct
+// extensions at the given false-positive rate. Memory is constant
+// regardless of traffic, but a re-delivery is skipped outright rather
+// than replaying its cached decision, and at the configured rate a
+// never-seen extension is wrongly skipped too — acceptable for gossip,
+// where a neighbour will forward it anyway.
+func NewGatekeeperWithBloom(expected int, fpRate float64) *Gatekeeper {
+	return &Gatekeeper{bloom: newBloomFilter(expected, fpRate)}
+}
+
+// ShouldForward decides whether ext should be propagated for a node
+// whose interest is expressed by query. Re-deliveries of an extension
+// already decided on return the cached decision without re-verifying
+// crypto or recomputing similarity.
+func (g *Gatekeeper) ShouldForward(ext *Extension, query *Query) *GatekeeperDecision {
+	return g.ShouldForwardAny(ext, []*Query{query})
+}
+
+// ShouldForwardAny decides against several interest queries at once:
+// one cache lookup, one crypto verification, and the extension
+// forwards if any query finds it relevant. The decision carries the
+// breakdown of the best-scoring query.
+func (g *Gatekeeper) ShouldForwardAny(ext *Extension, queries []*Query) *GatekeeperDecision {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var cacheKey string
+	if ext.Child != nil && (g.seen != nil || g.bloom != nil) {
+		cacheKey = ext.Child.Hex()
+	}
+	if cacheKey != "" && g.seen != nil {
+		if cached, ok := g.seen.get(cacheKey); ok {
+			g.stats.SeenCacheHits++
+			return cached
+		}
+	}
+	if cacheKey != "" && g.bloom != nil {
+		if g.bloom.contains(cacheKey) {
+			g.stats.SeenCacheHits++
+			return &GatekeeperDecision{Reason: "duplicate delivery"}
+		}
+	}
+	g.stats.TotalSeen++
+
+	decision := &GatekeeperDecision{}
+	defer func() {
+		if cacheKey != "" && g.seen != nil {
+			g.seen.put(cacheKey, decision)
+		}
+		if cacheKey != "" && g.bloom != nil {
+			g.bloom.add(cacheKey)
+		}
+		g.emitDecision(decision)
+		g.recordAudit(decision, ext.Child)
+	}()
+
+	decision.CryptoValid = ext.Verify()
+	if !decision.CryptoValid {
+		decision.Reason = "crypto verification failed"
+		g.stats.CryptoBlocked++
+		g.updateBlockRate()
+		return decision
+	}
+
+	// A valid signature over a stale or premature window is still a
+	// replay; judge the window only after crypto so forged extensions
+	// keep counting against CryptoBlocked.
+	if reason, early := g.windowViolation(ext); reason != "" {
+		if early {
+			// A premature extension becomes acceptable once its window
+			// opens, so its rejection must not be replayed from cache.
+			cacheKey = ""
+		}
+		decision.Reason = reason
+		g.stats.WindowBlocked++
+		g.updateBlockRate()
+		return decision
+	}
+
+	var bestThreshold float64
+	for _, query := range queries {
+		breakdown := semantic.Explain(query.Content.Features, ext.Content.Features, query.Params)
+		// Negative-term penalties net against the positive score before
+		// thresholding; the breakdown keeps the raw components.
+		score := breakdown.Combined - query.Penalty(ext.Content)
+		if score < 0 {
+			score = 0
+		}
+		if score > decision.SimilarityScore || decision.Breakdown == (semantic.SimilarityBreakdown{}) {
+			decision.Breakdown = breakdown
+			decision.SimilarityScore = score
+			bestThreshold = query.Threshold
+		}
+		if score >= query.Threshold {
+			decision.SemanticRelevant = true
+			decision.Breakdown = breakdown
+			decision.SimilarityScore = score
+			break
+		}
+	}
+	g.observeSimilarity(decision.SimilarityScore)
+	if !decision.SemanticRelevant {
+		decision.Reason = fmt.Sprintf("similarity %.3f below threshold %.3f",
+			decision.SimilarityScore, bestThreshold)
+		g.stats.SemanticBlocked++
+		g.updateBlockRate()
+		return decision
+	}
+
+	decision.Forward = true
+	g.stats.Forwarded++
+	g.updateBlockRate()
+	return decision
+}
+
+// defaultClockSkew is the validity-window drift tolerated when no
+// explicit tolerance is configured; a minute absorbs ordinary NTP
+// drift plus gossip propagation delay.
+const defaultClockSkew = time.Minute
+
+// SetClockSkew sets the tolerated clock drift for validity-window
+// checks; zero restores the default.
+func (g *Gatekeeper) SetClockSkew(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.clockSkew = d
+}
+
+// windowViolation reports why ext falls outside its validity window
+// ("" when it does not) and whether the violation is being early,
+// which is recoverable; callers must hold the lock.
+func (g *Gatekeeper) windowViolation(ext *Extension) (reason string, early bool) {
+	skew := g.clockSkew
+	if skew == 0 {
+		skew = defaultClockSkew
+	}
+	now := time.Now().UnixNano()
+	if ext.ValidFrom != 0 && now+int64(skew) < ext.ValidFrom {
+		return "extension not yet valid", true
+	}
+	if ext.ValidUntil != 0 && now-int64(skew) > ext.ValidUntil {
+		return "extension validity window expired", false
+	}
+	return "", false
+}
+
+// observeSimilarity folds one similarity score into the decaying
+// average and variance; callers must hold the lock.
+func (g *Gatekeeper) observeSimilarity(score float64) {
+	if !g.emaPrimed {
+		g.emaScore = score
+		g.emaPrimed = true
+		return
+	}
+	diff := score - g.emaScore
+	incr := emaDecay * diff
+	g.emaScore += incr
+	g.emaVariance = (1 - emaDecay) * (g.emaVariance + diff*incr)
+}
+
+// RecentSimilarity returns the exponentially decaying average of the
+// similarity scores of recently seen crypto-valid extensions. Zero
+// until the first extension is scored.
+func (g *Gatekeeper) RecentSimilarity() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.emaScore
+}
+
+// SuggestThreshold proposes a forwarding threshold from recent
+// traffic: the decaying average minus one standard deviation, floored
+// at zero. A node in a sparse-interest region sees low scores and gets
+// a low suggestion; one flooded with relevant content gets a high one.
+func (g *Gatekeeper) SuggestThreshold() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	suggested := g.emaScore - math.Sqrt(g.emaVariance)
+	if suggested < 0 {
+		return 0
+	}
+	return suggested
+}
+
+// updateBlockRate recomputes the blocked fraction; callers must hold
+// the lock.
+func (g *Gatekeeper) updateBlockRate() {
+	if g.stats.TotalSeen == 0 {
+		g.stats.BlockRate = 0
+		return
+	}
+	blocked := g.stats.CryptoBlocked + g.stats.SemanticBlocked + g.stats.WindowBlocked
+	g.stats.BlockRate = float64(blocked) / float64(g.stats.TotalSeen)
+}
+
+// SetDecisionLog makes the gatekeeper write every fresh decision to w
+// as one JSON object per line. Pass nil to turn logging off.
+func (g *Gatekeeper) SetDecisionLog(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.decisionLog = w
+}
+
+// emitDecision writes one JSON line; callers must hold the lock.
+func (g *Gatekeeper) emitDecision(d *GatekeeperDecision) {
+	if g.decisionLog == nil {
+		return
+	}
+	line, err := json.Marshal(d)
+	if err != nil {
+		log.Printf("gatekeeper: marshal decision: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	if _, err := g.decisionLog.Write(line); err != nil {
+		log.Printf("gatekeeper: write decision log: %v", err)
+	}
+}
+
+// AuditEntry is one remembered gatekeeper decision: when it was made,
+// a short form of the extension's child hash, and the decision itself.
+type AuditEntry struct {
+	Time     time.Time          `json:"time"`
+	Hash     string             `json:"hash,omitempty"`
+	Decision GatekeeperDecision `json:"decision"`
+}
+
+// SetAuditSize keeps the most recent n fresh decisions for inspection
+// through RecentDecisions. Zero or negative turns auditing off;
+// resizing discards whatever the ring held.
+func (g *Gatekeeper) SetAuditSize(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n <= 0 {
+		n = 0
+		g.audit = nil
+	} else {
+		g.audit = make([]AuditEntry, 0, n)
+	}
+	g.auditNext = 0
+	g.auditCap = n
+}
+
+// recordAudit appends one decision to the ring, overwriting the oldest
+// entry once full; callers must hold the lock. Duplicate deliveries
+// never reach it, matching the decision log.
+func (g *Gatekeeper) recordAudit(d *GatekeeperDecision, child *crypto.Hash) {
+	if g.auditCap <= 0 {
+		return
+	}
+	entry := AuditEntry{Time: time.Now(), Decision: *d}
+	if child != nil {
+		entry.Hash = child.String()
+	}
+	if len(g.audit) < g.auditCap {
+		g.audit = append(g.audit, entry)
+		return
+	}
+	g.audit[g.auditNext] = entry
+	g.auditNext = (g.auditNext + 1) % g.auditCap
+}
+
+// RecentDecisions returns up to the configured number of most recent
+// decisions, oldest first.
+func (g *Gatekeeper) RecentDecisions() []AuditEntry {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make([]AuditEntry, 0, len(g.audit))
+	out = append(out, g.audit[g.auditNext:]...)
+	out = append(out, g.audit[:g.auditNext]...)
+	return out
+}
+
+// GetStats returns a snapshot of the counters.
+func (g *Gatekeeper) GetStats() GatekeeperStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
@@ -0,0 +1,378 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+func TestShouldForwardRelevant(t *testing.T) {
+	gk := NewGatekeeper()
+	query := NewQuery("machine learning and neural networks", 0.1)
+
+	root := NewContent([]byte("an introduction to machine learning"))
+	ext := root.Extend([]byte("neural networks are machine learning models"))
+
+	decision := gk.ShouldForward(ext, query)
+	if !decision.CryptoValid {
+		t.Fatal("valid extension failed crypto check")
+	}
+	if !decision.Forward {
+		t.Fatalf("relevant extension blocked: %s", decision.Reason)
+	}
+
+	stats := gk.GetStats()
+	if stats.TotalSeen != 1 || stats.Forwarded != 1 {
+		t.Fatalf("stats = %+v, want one seen and one forwarded", stats)
+	}
+}
+
+func TestShouldForwardBlocksBadCrypto(t *testing.T) {
+	gk := NewGatekeeper()
+	query := NewQuery("machine learning", 0.1)
+
+	root := NewContent([]byte("an introduction to machine learning"))
+	ext := root.Extend([]byte("more machine learning"))
+	ext.Child = crypto.HashElement([]byte("forged"))
+
+	decision := gk.ShouldForward(ext, query)
+	if decision.Forward || decision.CryptoValid {
+		t.Fatal("forged extension passed the gatekeeper")
+	}
+	if gk.GetStats().CryptoBlocked != 1 {
+		t.Fatal("CryptoBlocked not incremented")
+	}
+}
+
+func TestShouldForwardBlocksIrrelevant(t *testing.T) {
+	gk := NewGatekeeper()
+	query := NewQuery("machine learning and neural networks", 0.9)
+
+	root := NewContent([]byte("cooking"))
+	ext := root.Extend([]byte("sourdough bread recipes for beginners"))
+
+	decision := gk.ShouldForward(ext, query)
+	if decision.Forward {
+		t.Fatal("irrelevant extension forwarded")
+	}
+	if !decision.CryptoValid {
+		t.Fatal("crypto should pass for a well-formed extension")
+	}
+	if gk.GetStats().SemanticBlocked != 1 {
+		t.Fatal("SemanticBlocked not incremented")
+	}
+}
+
+func TestShouldForwardCachesDuplicates(t *testing.T) {
+	gk := NewGatekeeper()
+	query := NewQuery("machine learning", 0.05)
+
+	root := NewContent([]byte("machine learning notes"))
+	ext := root.Extend([]byte("more machine learning notes"))
+
+	first := gk.ShouldForward(ext, query)
+	second := gk.ShouldForward(ext, query)
+	if first.Forward != second.Forward {
+		t.Fatal("cached decision differs from original")
+	}
+
+	stats := gk.GetStats()
+	if stats.TotalSeen != 1 {
+		t.Fatalf("TotalSeen = %d, want 1 (duplicate must not double-count)", stats.TotalSeen)
+	}
+	if stats.Forwarded != 1 {
+		t.Fatalf("Forwarded = %d, want 1", stats.Forwarded)
+	}
+	if stats.SeenCacheHits != 1 {
+		t.Fatalf("SeenCacheHits = %d, want 1", stats.SeenCacheHits)
+	}
+}
+
+func TestShouldForwardCachesBlockedDecision(t *testing.T) {
+	gk := NewGatekeeper()
+	query := NewQuery("machine learning", 0.1)
+
+	root := NewContent([]byte("base"))
+	ext := root.Extend([]byte("delta"))
+	ext.Child = crypto.HashElement([]byte("forged"))
+
+	gk.ShouldForward(ext, query)
+	gk.ShouldForward(ext, query)
+
+	stats := gk.GetStats()
+	if stats.CryptoBlocked != 1 {
+		t.Fatalf("CryptoBlocked = %d, want 1", stats.CryptoBlocked)
+	}
+	if stats.SeenCacheHits != 1 {
+		t.Fatalf("SeenCacheHits = %d, want 1", stats.SeenCacheHits)
+	}
+}
+
+func TestSeenCacheEviction(t *testing.T) {
+	gk := NewGatekeeperWithCache(2)
+	query := NewQuery("anything at all", 0.0)
+
+	root := NewContent([]byte("base"))
+	first := root.Extend([]byte("one"))
+	gk.ShouldForward(first, query)
+	gk.ShouldForward(root.Extend([]byte("two")), query)
+	gk.ShouldForward(root.Extend([]byte("three")), query)
+
+	// "one" was evicted, so re-seeing it is a fresh decision.
+	gk.ShouldForward(first, query)
+	if hits := gk.GetStats().SeenCacheHits; hits != 0 {
+		t.Fatalf("SeenCacheHits = %d, want 0 after eviction", hits)
+	}
+	if seen := gk.GetStats().TotalSeen; seen != 4 {
+		t.Fatalf("TotalSeen = %d, want 4", seen)
+	}
+}
+
+func TestDecisionJSONLog(t *testing.T) {
+	gk := NewGatekeeper()
+	var buf bytes.Buffer
+	gk.SetDecisionLog(&buf)
+	query := NewQuery("machine learning", 0.05)
+
+	root := NewContent([]byte("machine learning"))
+	decision := gk.ShouldForward(root.Extend([]byte("more machine learning")), query)
+
+	line := bytes.TrimSpace(buf.Bytes())
+	var logged struct {
+		Forward          bool    `json:"forward"`
+		CryptoValid      bool    `json:"crypto_valid"`
+		SemanticRelevant bool    `json:"semantic_relevant"`
+		SimilarityScore  float64 `json:"similarity_score"`
+		Breakdown        struct {
+			Semantic   float64 `json:"semantic"`
+			Lexical    float64 `json:"lexical"`
+			Structural float64 `json:"structural"`
+		} `json:"similarity_breakdown"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(line, &logged); err != nil {
+		t.Fatalf("unmarshal logged decision: %v", err)
+	}
+	if logged.Forward != decision.Forward ||
+		logged.CryptoValid != decision.CryptoValid ||
+		logged.SemanticRelevant != decision.SemanticRelevant ||
+		logged.SimilarityScore != decision.SimilarityScore {
+		t.Fatalf("logged decision %+v does not match %+v", logged, decision)
+	}
+	if logged.Breakdown.Semantic != decision.Breakdown.Semantic ||
+		logged.Breakdown.Lexical != decision.Breakdown.Lexical ||
+		logged.Breakdown.Structural != decision.Breakdown.Structural {
+		t.Fatal("logged breakdown does not match decision")
+	}
+}
+
+func TestDecisionLogOnlyFreshDecisions(t *testing.T) {
+	gk := NewGatekeeper()
+	var buf bytes.Buffer
+	gk.SetDecisionLog(&buf)
+	query := NewQuery("anything", 0.0)
+
+	root := NewContent([]byte("base"))
+	ext := root.Extend([]byte("delta"))
+	gk.ShouldForward(ext, query)
+	gk.ShouldForward(ext, query) // cache hit, no new line
+
+	lines := bytes.Count(buf.Bytes(), []byte{'\n'})
+	if lines != 1 {
+		t.Fatalf("logged %d lines, want 1", lines)
+	}
+}
+
+func TestSimulatorPropagation(t *testing.T) {
+	sim := NewGossipSimulator()
+	sim.AddNode("ml-fan", "machine learning models", 0.1)
+	sim.AddNode("chef", "sourdough bread baking", 0.95)
+
+	root := NewContent([]byte("papers about machine learning"))
+	ext := root.Extend([]byte("a new machine learning architecture"))
+
+	forwarded := sim.PropagateExtension(ext)
+	if forwarded != 1 {
+		t.Fatalf("forwarded = %d, want 1", forwarded)
+	}
+	stats := sim.GetStats()
+	if stats["ml-fan"].Forwarded != 1 {
+		t.Fatal("interested node did not forward")
+	}
+	if stats["chef"].Forwarded != 0 {
+		t.Fatal("uninterested node forwarded")
+	}
+}
+
+func TestShouldForwardAnyMatchesSecondInterest(t *testing.T) {
+	gk := NewGatekeeper()
+	queries := []*Query{
+		NewQuery("sourdough bread baking", 0.9),
+		NewQuery("machine learning models", 0.1),
+	}
+
+	root := NewContent([]byte("papers about machine learning"))
+	ext := root.Extend([]byte("a new machine learning architecture"))
+
+	decision := gk.ShouldForwardAny(ext, queries)
+	if !decision.Forward {
+		t.Fatalf("extension matching the second interest blocked: %s", decision.Reason)
+	}
+	if gk.GetStats().TotalSeen != 1 {
+		t.Fatalf("TotalSeen = %d, want 1 for a multi-interest decision", gk.GetStats().TotalSeen)
+	}
+}
+
+func benchmarkShouldForwardAny(b *testing.B, numInterests int) {
+	gk := NewGatekeeperWithCache(0) // measure full decisions, not cache hits
+	queries := make([]*Query, numInterests)
+	for i := range queries {
+		queries[i] = NewQuery("completely unrelated interest text", 0.99)
+	}
+	root := NewContent([]byte("benchmark base"))
+	ext := root.Extend([]byte("benchmark delta payload for gatekeeping"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gk.ShouldForwardAny(ext, queries)
+	}
+}
+
+// Crypto verification dominates a blocked decision; its cost must not
+// scale with the interest count.
+func BenchmarkShouldForwardAny1(b *testing.B)  { benchmarkShouldForwardAny(b, 1) }
+func BenchmarkShouldForwardAny16(b *testing.B) { benchmarkShouldForwardAny(b, 16) }
+
+func TestRecentSimilarityConverges(t *testing.T) {
+	gk := NewGatekeeperWithCache(0)
+	query := NewQuery("machine learning models", 0.1)
+	ext := NewExtension(crypto.Zero(), []byte("training machine learning models on data"))
+	score := gk.ShouldForward(ext, query).SimilarityScore
+	if score <= 0 {
+		t.Fatalf("fixture extension scored %f, need a positive score", score)
+	}
+
+	// A steady stream of the same score should pull the average onto it
+	// and squeeze the deviation toward zero.
+	for i := 0; i < 200; i++ {
+		gk.ShouldForward(ext, query)
+	}
+	ema := gk.RecentSimilarity()
+	if diff := ema - score; diff > 0.01 || diff < -0.01 {
+		t.Fatalf("EMA %f did not converge to steady score %f", ema, score)
+	}
+	suggested := gk.SuggestThreshold()
+	if suggested > ema || suggested < ema-0.05 {
+		t.Fatalf("suggested threshold %f not just below EMA %f", suggested, ema)
+	}
+}
+
+func TestSuggestThresholdDropsWithVariance(t *testing.T) {
+	gk := NewGatekeeperWithCache(0)
+	query := NewQuery("machine learning models", 0.1)
+	high := NewExtension(crypto.Zero(), []byte("machine learning models"))
+	low := NewExtension(crypto.Zero(), []byte("completely unrelated gardening tips"))
+	for i := 0; i < 100; i++ {
+		gk.ShouldForward(high, query)
+		gk.ShouldForward(low, query)
+	}
+	ema := gk.RecentSimilarity()
+	suggested := gk.SuggestThreshold()
+	if suggested >= ema {
+		t.Fatalf("mixed traffic should suggest below the EMA: %f vs %f", suggested, ema)
+	}
+	if suggested < 0 {
+		t.Fatalf("suggested threshold %f below zero", suggested)
+	}
+}
+
+func TestRecentDecisionsRingRetention(t *testing.T) {
+	gk := NewGatekeeperWithCache(0)
+	gk.SetAuditSize(3)
+	query := NewQuery("machine learning", 0.1)
+
+	var children []string
+	for i := 0; i < 5; i++ {
+		ext := NewExtension(crypto.Zero(),
+			[]byte(fmt.Sprintf("machine learning batch %d", i)))
+		children = append(children, ext.Child.String())
+		gk.ShouldForward(ext, query)
+	}
+
+	recent := gk.RecentDecisions()
+	if len(recent) != 3 {
+		t.Fatalf("retained %d entries, want 3", len(recent))
+	}
+	for i, entry := range recent {
+		want := children[2+i] // decisions 2, 3, 4 — oldest first
+		if entry.Hash != want {
+			t.Fatalf("entry %d hash %s, want %s", i, entry.Hash, want)
+		}
+		if !entry.Decision.Forward {
+			t.Fatalf("entry %d lost the decision verdict", i)
+		}
+		if entry.Time.IsZero() {
+			t.Fatalf("entry %d has no timestamp", i)
+		}
+	}
+
+	gk.SetAuditSize(0)
+	if got := gk.RecentDecisions(); len(got) != 0 {
+		t.Fatalf("disabled audit still returns %d entries", len(got))
+	}
+}
+
+func TestValidityWindowEnforced(t *testing.T) {
+	gk := NewGatekeeper()
+	gk.SetClockSkew(time.Second)
+	query := NewQuery("machine learning", 0.1)
+	root := NewContent([]byte("an introduction to machine learning"))
+	now := time.Now().UnixNano()
+
+	inWindow := root.Extend([]byte("machine learning models in the window"))
+	inWindow.ValidFrom = now - int64(time.Hour)
+	inWindow.ValidUntil = now + int64(time.Hour)
+	if d := gk.ShouldForward(inWindow, query); !d.Forward {
+		t.Fatalf("extension inside its window blocked: %s", d.Reason)
+	}
+
+	early := root.Extend([]byte("machine learning released too early"))
+	early.ValidFrom = now + int64(time.Hour)
+	if d := gk.ShouldForward(early, query); d.Forward || !d.CryptoValid {
+		t.Fatalf("premature extension not window-blocked: %+v", d)
+	}
+
+	expired := root.Extend([]byte("machine learning replayed too late"))
+	expired.ValidUntil = now - int64(time.Hour)
+	if d := gk.ShouldForward(expired, query); d.Forward {
+		t.Fatal("expired extension forwarded")
+	}
+
+	stats := gk.GetStats()
+	if stats.WindowBlocked != 2 {
+		t.Fatalf("WindowBlocked = %d, want 2", stats.WindowBlocked)
+	}
+	if stats.CryptoBlocked != 0 || stats.SemanticBlocked != 0 {
+		t.Fatalf("window rejections miscounted: %+v", stats)
+	}
+
+	// Skew tolerance admits an extension whose window opens a moment
+	// from now, and a premature rejection is not replayed from cache
+	// once the window opens.
+	gk.SetClockSkew(time.Minute)
+	soon := root.Extend([]byte("machine learning opening shortly"))
+	soon.ValidFrom = now + int64(10*time.Second)
+	if d := gk.ShouldForward(soon, query); !d.Forward {
+		t.Fatalf("window within skew tolerance blocked: %s", d.Reason)
+	}
+	gk.SetClockSkew(time.Second)
+	if d := gk.ShouldForward(early, query); d.Forward {
+		t.Fatal("still-premature extension forwarded")
+	}
+	if hits := gk.GetStats().SeenCacheHits; hits != 0 {
+		t.Fatalf("premature rejection served from cache (%d hits)", hits)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+// WeightedInterest is one interest query with its relative
+// importance. Weights are relative to each other; 1.0 is a neutral
+// weight.
+type WeightedInterest struct {
+	Query  *Query
+	Weight float64
+}
+
+// InterestFilter scores content against a set of weighted interests:
+// Score is the best weighted similarity across them, and Matches is
+// the thresholded view of that score. A node can forward on Matches
+// or feed Score into a probabilistic forwarding policy.
+type InterestFilter struct {
+	// Threshold is the minimum Score for Matches.
+	Threshold float64
+	Interests []WeightedInterest
+}
+
+// NewInterestFilter returns an empty filter with the given match
+// threshold.
+func NewInterestFilter(threshold float64) *InterestFilter {
+	return &InterestFilter{Threshold: threshold}
+}
+
+// Add registers an interest built from example text. Weights at or
+// below zero are treated as neutral.
+func (f *InterestFilter) Add(text string, weight float64) {
+	if weight <= 0 {
+		weight = 1.0
+	}
+	f.Interests = append(f.Interests, WeightedInterest{
+		Query:  NewQuery(text, f.Threshold),
+		Weight: weight,
+	})
+}
+
+// Score returns the best weighted similarity of content across the
+// filter's interests: an interest weighted 2.0 pulls matching content
+// twice as high as one weighted 1.0.
+func (f *InterestFilter) Score(c *Content) float64 {
+	best := 0.0
+	for _, interest := range f.Interests {
+		if score := interest.Weight * interest.Query.Similarity(c); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// Matches reports whether content's Score clears the filter's
+// threshold.
+func (f *InterestFilter) Matches(c *Content) bool {
+	return f.Score(c) >= f.Threshold
+}
@@ -0,0 +1,61 @@
+package core
+
+import "testing"
+
+func TestInterestFilterWeightedScore(t *testing.T) {
+	content := NewContent([]byte("training machine learning models with neural networks"))
+
+	flat := NewInterestFilter(0.1)
+	flat.Add("machine learning models", 1.0)
+	boosted := NewInterestFilter(0.1)
+	boosted.Add("machine learning models", 2.0)
+
+	if flat.Score(content) >= boosted.Score(content) {
+		t.Fatalf("boosted score %f not above flat score %f",
+			boosted.Score(content), flat.Score(content))
+	}
+}
+
+func TestInterestFilterBestInterestWins(t *testing.T) {
+	f := NewInterestFilter(0.1)
+	f.Add("sourdough bread baking", 1.0)
+	f.Add("machine learning models", 3.0)
+
+	ml := NewContent([]byte("training machine learning models"))
+	cooking := NewContent([]byte("sourdough bread baking at home"))
+	if f.Score(ml) <= f.Score(cooking) {
+		t.Fatalf("weighted ML interest should dominate: ml %f, cooking %f",
+			f.Score(ml), f.Score(cooking))
+	}
+}
+
+func TestInterestFilterMatchesIsThresholdedScore(t *testing.T) {
+	f := NewInterestFilter(0.3)
+	f.Add("machine learning models", 1.0)
+
+	for _, text := range []string{
+		"training machine learning models with neural networks",
+		"completely unrelated gardening tips for spring",
+	} {
+		c := NewContent([]byte(text))
+		if got, want := f.Matches(c), f.Score(c) >= f.Threshold; got != want {
+			t.Fatalf("Matches(%q) = %v, but Score = %f against threshold %f",
+				text, got, f.Score(c), f.Threshold)
+		}
+	}
+
+	if !f.Matches(NewContent([]byte("training machine learning models with neural networks"))) {
+		t.Fatal("relevant content does not match")
+	}
+	if f.Matches(NewContent([]byte("completely unrelated gardening tips for spring"))) {
+		t.Fatal("irrelevant content matches")
+	}
+}
+
+func TestInterestFilterDefaultsNonPositiveWeight(t *testing.T) {
+	f := NewInterestFilter(0.1)
+	f.Add("machine learning", -1)
+	if f.Interests[0].Weight != 1.0 {
+		t.Fatalf("weight = %f, want neutral 1.0", f.Interests[0].Weight)
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/systemshift/tera/semantic"
+)
+
+// Query expresses an interest as example content: candidates are
+// matched by semantic similarity to the query's own features.
+type Query struct {
+	Content   *Content
+	Params    semantic.KernelParams
+	Threshold float64
+	// NegativeTerms express what the query wants to avoid: similarity
+	// against them is subtracted from the positive score before
+	// thresholding, scaled by NegativeWeight (zero means 1.0). A node
+	// interested in "machine learning" can penalize "cryptocurrency"
+	// without touching its positive interest.
+	NegativeTerms  [][]byte
+	NegativeWeight float64
+
+	// negatives caches the extracted features of NegativeTerms;
+	// rebuilt when the term count changes.
+	negatives []*Content
+}
+
+// NewQuery builds a query from example text with the default kernel
+// parameters.
+func NewQuery(text string, threshold float64) *Query {
+	return &Query{
+		Content:   NewContent([]byte(text)),
+		Params:    semantic.DefaultKernelParams(),
+		Threshold: threshold,
+	}
+}
+
+// queryJSON is the persisted form of a query: the example text, the
+// kernel parameters, and the thresholding knobs. Derived features are
+// not stored; they are re-extracted on load, so saved queries survive
+// changes to feature extraction.
+type queryJSON struct {
+	Content        []byte                `json:"content"`
+	Params         semantic.KernelParams `json:"params"`
+	Threshold      float64               `json:"threshold"`
+	NegativeTerms  [][]byte              `json:"negative_terms,omitempty"`
+	NegativeWeight float64               `json:"negative_weight,omitempty"`
+}
+
+// MarshalJSON persists the query for saved searches and CLI config
+// files; UnmarshalJSON restores it.
+func (q *Query) MarshalJSON() ([]byte, error) {
+	return json.Marshal(queryJSON{
+		Content:        q.Content.Data,
+		Params:         q.Params,
+		Threshold:      q.Threshold,
+		NegativeTerms:  q.NegativeTerms,
+		NegativeWeight: q.NegativeWeight,
+	})
+}
+
+// UnmarshalJSON restores a persisted query, re-extracting features
+// from the stored example text.
+func (q *Query) UnmarshalJSON(data []byte) error {
+	var enc queryJSON
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+	q.Content = NewContent(enc.Content)
+	q.Params = enc.Params
+	q.Threshold = enc.Threshold
+	q.NegativeTerms = enc.NegativeTerms
+	q.NegativeWeight = enc.NegativeWeight
+	q.negatives = nil
+	return nil
+}
+
+// Similarity scores candidate content against the query.
+func (q *Query) Similarity(c *Content) float64 {
+	return semantic.Similarity(q.Content.Features, c.Features, q.Params)
+}
+
+// AddNegativeTerm registers content the query wants to avoid.
+func (q *Query) AddNegativeTerm(term []byte) {
+	q.NegativeTerms = append(q.NegativeTerms, term)
+}
+
+// Penalty returns the weighted similarity of content against the
+// query's negative terms: the worst offender counts, scaled by
+// NegativeWeight.
+func (q *Query) Penalty(c *Content) float64 {
+	if len(q.NegativeTerms) == 0 {
+		return 0
+	}
+	if len(q.negatives) != len(q.NegativeTerms) {
+		q.negatives = make([]*Content, len(q.NegativeTerms))
+		for i, term := range q.NegativeTerms {
+			q.negatives[i] = NewContent(term)
+		}
+	}
+	weight := q.NegativeWeight
+	if weight == 0 {
+		weight = 1.0
+	}
+	worst := 0.0
+	for _, neg := range q.negatives {
+		if sim := semantic.Similarity(neg.Features, c.Features, q.Params); sim > worst {
+			worst = sim
+		}
+	}
+	return weight * worst
+}
+
+// Score is the similarity with the negative-term penalty applied,
+// floored at zero. This is what Matches thresholds.
+func (q *Query) Score(c *Content) float64 {
+	score := q.Similarity(c) - q.Penalty(c)
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// Matches reports whether candidate content clears the query's
+// threshold after any negative-term penalty.
+func (q *Query) Matches(c *Content) bool {
+	return q.Score(c) >= q.Threshold
+}
+
+// Refine returns a Rocchio-refined copy of the query: its term-weight
+// vector moves toward the centroid of content the user marked
+// relevant (scaled by alpha) and away from the centroid of content
+// marked irrelevant (scaled by beta). Terms pushed to zero or below
+// drop out. Only the semantic component changes — the n-grams and
+// structural measurements still describe the original example text —
+// and the refinement is not persisted by MarshalJSON, which stores
+// the example text alone.
+func (q *Query) Refine(relevant, irrelevant []*Content, alpha, beta float64) *Query {
+	tf := make(map[string]float64, len(q.Content.Features.TFIDF))
+	for term, w := range q.Content.Features.TFIDF {
+		tf[term] = w
+	}
+	addCentroid(tf, relevant, alpha)
+	addCentroid(tf, irrelevant, -beta)
+	for term, w := range tf {
+		if w <= 0 {
+			delete(tf, term)
+		}
+	}
+
+	content := *q.Content
+	content.Features = q.Content.Features.WithTFIDF(tf)
+	refined := *q
+	refined.Content = &content
+	refined.negatives = nil
+	return &refined
+}
+
+// addCentroid folds scale times the centroid of the contents'
+// term-weight vectors into tf.
+func addCentroid(tf map[string]float64, contents []*Content, scale float64) {
+	if len(contents) == 0 || scale == 0 {
+		return
+	}
+	scale /= float64(len(contents))
+	for _, c := range contents {
+		for term, w := range c.Features.TFIDF {
+			tf[term] += scale * w
+		}
+	}
+}
+
+// Rank orders contents by similarity to the query, best first.
+func (q *Query) Rank(contents []*Content) []semantic.RankedResult {
+	features := make([]*semantic.Features, len(contents))
+	for i, c := range contents {
+		features[i] = c.Features
+	}
+	return semantic.RankBySimilarity(q.Content.Features, features, q.Params)
+}
+
+// RankedExplanation is one entry of an explained ranking: the content,
+// its overall similarity, and the per-component breakdown behind it.
+type RankedExplanation struct {
+	Content    *Content                     `json:"content"`
+	Similarity float64                      `json:"similarity"`
+	Breakdown  semantic.SimilarityBreakdown `json:"breakdown"`
+}
+
+// RankExplained is Rank with the component breakdown kept per result,
+// so relevance-debugging tools can show why something ranked where it
+// did. Ordering matches Rank exactly.
+func (q *Query) RankExplained(contents []*Content) []RankedExplanation {
+	ranked := q.Rank(contents)
+	explained := make([]RankedExplanation, len(ranked))
+	for i, r := range ranked {
+		c := contents[r.Index]
+		explained[i] = RankedExplanation{
+			Content:    c,
+			Similarity: r.Score,
+			Breakdown:  semantic.Explain(q.Content.Features, c.Features, q.Params),
+		}
+	}
+	return explained
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+func TestRankExplainedMatchesRank(t *testing.T) {
+	q := NewQuery("machine learning", 0.1)
+	contents := []*Content{
+		NewContent([]byte("gardening tips for spring")),
+		NewContent([]byte("machine learning in production")),
+		NewContent([]byte("a history of sailing ships")),
+	}
+
+	ranked := q.Rank(contents)
+	explained := q.RankExplained(contents)
+	if len(explained) != len(ranked) {
+		t.Fatalf("got %d explained results, want %d", len(explained), len(ranked))
+	}
+
+	for i, e := range explained {
+		if e.Content != contents[ranked[i].Index] {
+			t.Fatalf("result %d carries the wrong content", i)
+		}
+		if e.Similarity != ranked[i].Score {
+			t.Fatalf("result %d similarity %f differs from Rank score %f",
+				i, e.Similarity, ranked[i].Score)
+		}
+		for name, v := range map[string]float64{
+			"semantic":   e.Breakdown.Semantic,
+			"lexical":    e.Breakdown.Lexical,
+			"structural": e.Breakdown.Structural,
+			"combined":   e.Breakdown.Combined,
+		} {
+			if v < 0 || v > 1 {
+				t.Fatalf("result %d %s component %f outside [0,1]", i, name, v)
+			}
+		}
+		weighted := q.Params.SemanticWeight*e.Breakdown.Semantic +
+			q.Params.LexicalWeight*e.Breakdown.Lexical +
+			q.Params.StructuralWeight*e.Breakdown.Structural
+		if math.Abs(weighted-e.Similarity) > 1e-9 {
+			t.Fatalf("result %d weighted components %f do not sum to similarity %f",
+				i, weighted, e.Similarity)
+		}
+	}
+}
+
+func TestNegativeTermsPenalizeScore(t *testing.T) {
+	q := NewQuery("machine learning models", 0.25)
+	q.AddNegativeTerm([]byte("cryptocurrency coin trading"))
+	q.NegativeWeight = 1.5
+
+	mixed := NewContent([]byte("machine learning models for cryptocurrency coin trading"))
+	pure := NewContent([]byte("machine learning models and neural networks"))
+
+	if q.Score(mixed) >= q.Similarity(mixed) {
+		t.Fatalf("penalty not applied: score %f, raw similarity %f",
+			q.Score(mixed), q.Similarity(mixed))
+	}
+	if q.Matches(mixed) {
+		t.Fatalf("content matching a negative term passed (score %f)", q.Score(mixed))
+	}
+	if !q.Matches(pure) {
+		t.Fatalf("pure positive content blocked (score %f)", q.Score(pure))
+	}
+
+	// The gatekeeper nets the same penalty before thresholding.
+	gk := NewGatekeeperWithCache(0)
+	blocked := gk.ShouldForward(NewExtension(crypto.Zero(), mixed.Data), q)
+	if blocked.Forward {
+		t.Fatal("gatekeeper forwarded penalized content")
+	}
+	passed := gk.ShouldForward(NewExtension(crypto.Zero(), pure.Data), q)
+	if !passed.Forward {
+		t.Fatalf("gatekeeper blocked pure positive content: %s", passed.Reason)
+	}
+}
+
+func TestQueryJSONRoundTrip(t *testing.T) {
+	q := NewQuery("machine learning models", 0.25)
+	q.Params.LanguageStrict = true
+	q.AddNegativeTerm([]byte("cryptocurrency coin trading"))
+	q.NegativeWeight = 1.5
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var restored Query
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if restored.Threshold != q.Threshold || restored.Params != q.Params {
+		t.Fatalf("restored query differs: %+v vs %+v", restored, q)
+	}
+	contents := []*Content{
+		NewContent([]byte("machine learning models and neural networks")),
+		NewContent([]byte("machine learning models for cryptocurrency coin trading")),
+		NewContent([]byte("a history of sailing ships")),
+	}
+	for i, c := range contents {
+		if restored.Matches(c) != q.Matches(c) {
+			t.Fatalf("content %d: Matches differs after round trip", i)
+		}
+		if restored.Score(c) != q.Score(c) {
+			t.Fatalf("content %d: Score %f differs from %f after round trip",
+				i, restored.Score(c), q.Score(c))
+		}
+	}
+}
+
+func TestRefineShiftsSimilarityTowardFeedback(t *testing.T) {
+	q := NewQuery("machine learning", 0.1)
+	relevant := NewContent([]byte("training deep neural networks for machine learning"))
+	irrelevant := NewContent([]byte("cryptocurrency mining rigs and exchanges"))
+	// Held-out docs sharing vocabulary with the feedback, not the query.
+	mlDoc := NewContent([]byte("deep neural networks generalize from training data"))
+	coinDoc := NewContent([]byte("exchanges list a new cryptocurrency"))
+
+	refined := q.Refine([]*Content{relevant}, []*Content{irrelevant}, 0.8, 0.4)
+
+	if got, want := refined.Similarity(mlDoc), q.Similarity(mlDoc); got <= want {
+		t.Fatalf("relevant-domain similarity %f did not rise above %f", got, want)
+	}
+	if got, want := refined.Similarity(coinDoc), q.Similarity(coinDoc); got > want {
+		t.Fatalf("irrelevant-domain similarity %f rose above %f", got, want)
+	}
+
+	// Refinement is a copy; the original query's vector is untouched.
+	if _, ok := q.Content.Features.TFIDF["neural"]; ok {
+		t.Fatal("Refine mutated the original query")
+	}
+	if _, ok := refined.Content.Features.TFIDF["cryptocurrency"]; ok {
+		t.Fatal("a purely negative term survived in the refined vector")
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+// SimNode is one simulated participant: an interest query, a
+// gatekeeper, and the extensions that made it through.
+type SimNode struct {
+	ID         string
+	Query      *Query
+	Gatekeeper *Gatekeeper
+	Received   []*Extension
+}
+
+// GossipSimulator runs extensions through a population of simulated
+// nodes so gatekeeping behavior can be studied without a network.
+type GossipSimulator struct {
+	nodes map[string]*SimNode
+	order []string
+	edges map[string][]string
+}
+
+// NewGossipSimulator returns an empty simulator.
+func NewGossipSimulator() *GossipSimulator {
+	return &GossipSimulator{
+		nodes: make(map[string]*SimNode),
+		edges: make(map[string][]string),
+	}
+}
+
+// AddNode registers a simulated node whose interest is the given
+// example text and threshold.
+func (s *GossipSimulator) AddNode(id, interest string, threshold float64) *SimNode {
+	node := &SimNode{
+		ID:         id,
+		Query:      NewQuery(interest, threshold),
+		Gatekeeper: NewGatekeeper(),
+	}
+	s.nodes[id] = node
+	s.order = append(s.order, id)
+	return node
+}
+
+// PropagateExtension offers ext to every node; each node's gatekeeper
+// independently decides whether to accept it. Returns how many nodes
+// forwarded.
+func (s *GossipSimulator) PropagateExtension(ext *Extension) int {
+	forwarded := 0
+	for _, id := range s.order {
+		node := s.nodes[id]
+		decision := node.Gatekeeper.ShouldForward(ext, node.Query)
+		if decision.Forward {
+			node.Received = append(node.Received, ext)
+			forwarded++
+		}
+	}
+	return forwarded
+}
+
+// AddEdge connects two nodes bidirectionally in the gossip topology
+// used by Propagate.
+func (s *GossipSimulator) AddEdge(a, b string) {
+	s.edges[a] = append(s.edges[a], b)
+	s.edges[b] = append(s.edges[b], a)
+}
+
+// PropagationResult describes how far an extension traveled.
+type PropagationResult struct {
+	// Reach is the number of nodes (excluding the origin) that
+	// received and forwarded the extension.
+	Reach int
+	// Hops maps each reached node to its distance from the origin.
+	Hops map[string]int
+}
+
+// Propagate walks the extension hop by hop from origin: a node only
+// sees the extension if a neighbor forwarded it, and only passes it on
+// if its own gatekeeper says to. This is how gatekeeping shapes reach
+// on a real topology, unlike PropagateExtension's flat broadcast.
+func (s *GossipSimulator) Propagate(ext *Extension, originID string) *PropagationResult {
+	result := &PropagationResult{Hops: make(map[string]int)}
+	visited := map[string]bool{originID: true}
+
+	type frontier struct {
+		id  string
+		hop int
+	}
+	queue := make([]frontier, 0, len(s.edges[originID]))
+	for _, neighbor := range s.edges[originID] {
+		queue = append(queue, frontier{neighbor, 1})
+	}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if visited[cur.id] {
+			continue
+		}
+		visited[cur.id] = true
+
+		node, ok := s.nodes[cur.id]
+		if !ok {
+			continue
+		}
+		decision := node.Gatekeeper.ShouldForward(ext, node.Query)
+		if !decision.Forward {
+			continue
+		}
+		node.Received = append(node.Received, ext)
+		result.Reach++
+		result.Hops[cur.id] = cur.hop
+		for _, neighbor := range s.edges[cur.id] {
+			if !visited[neighbor] {
+				queue = append(queue, frontier{neighbor, cur.hop + 1})
+			}
+		}
+	}
+	return result
+}
+
+// GetStats returns per-node gatekeeper counters keyed by node ID.
+func (s *GossipSimulator) GetStats() map[string]GatekeeperStats {
+	stats := make(map[string]GatekeeperStats, len(s.nodes))
+	for id, node := range s.nodes {
+		stats[id] = node.Gatekeeper.GetStats()
+	}
+	return stats
+}
+
+// AggregateStats sums the gatekeeper counters across every node and
+// recomputes the block rate over the whole population.
+func (s *GossipSimulator) AggregateStats() GatekeeperStats {
+	var agg GatekeeperStats
+	for _, node := range s.nodes {
+		stats := node.Gatekeeper.GetStats()
+		agg.TotalSeen += stats.TotalSeen
+		agg.Forwarded += stats.Forwarded
+		agg.CryptoBlocked += stats.CryptoBlocked
+		agg.SemanticBlocked += stats.SemanticBlocked
+		agg.SeenCacheHits += stats.SeenCacheHits
+	}
+	if agg.TotalSeen > 0 {
+		agg.BlockRate = float64(agg.CryptoBlocked+agg.SemanticBlocked) / float64(agg.TotalSeen)
+	}
+	return agg
+}
+
+// Reset gives every node a fresh gatekeeper and clears what it has
+// received, so the same topology can be reused across experiments.
+func (s *GossipSimulator) Reset() {
+	for _, node := range s.nodes {
+		node.Gatekeeper = NewGatekeeper()
+		node.Received = nil
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import "testing"
+
+func TestPropagateLineTopology(t *testing.T) {
+	sim := NewGossipSimulator()
+	sim.AddNode("a", "machine learning models", 0.05)
+	sim.AddNode("b", "machine learning models", 0.05)
+	sim.AddNode("c", "machine learning models", 0.05)
+	sim.AddEdge("a", "b")
+	sim.AddEdge("b", "c")
+
+	root := NewContent([]byte("papers about machine learning"))
+	ext := root.Extend([]byte("a new machine learning architecture"))
+
+	result := sim.Propagate(ext, "a")
+	if result.Reach != 2 {
+		t.Fatalf("reach = %d, want 2", result.Reach)
+	}
+	if result.Hops["b"] != 1 || result.Hops["c"] != 2 {
+		t.Fatalf("hops = %v, want b:1 c:2", result.Hops)
+	}
+}
+
+func TestPropagateBlockedMidChain(t *testing.T) {
+	sim := NewGossipSimulator()
+	sim.AddNode("a", "machine learning models", 0.05)
+	sim.AddNode("b", "sourdough bread baking", 0.95) // blocks ML content
+	sim.AddNode("c", "machine learning models", 0.05)
+	sim.AddEdge("a", "b")
+	sim.AddEdge("b", "c")
+
+	root := NewContent([]byte("papers about machine learning"))
+	ext := root.Extend([]byte("a new machine learning architecture"))
+
+	result := sim.Propagate(ext, "a")
+	if result.Reach != 0 {
+		t.Fatalf("reach = %d, want 0 (b blocks, so c is unreachable)", result.Reach)
+	}
+	if len(sim.nodes["c"].Received) != 0 {
+		t.Fatal("c received an extension its only neighbor blocked")
+	}
+	if got := sim.GetStats()["c"].TotalSeen; got != 0 {
+		t.Fatalf("c saw %d extensions, want 0", got)
+	}
+}
+
+func TestPropagateDoesNotRevisitNodes(t *testing.T) {
+	sim := NewGossipSimulator()
+	sim.AddNode("a", "machine learning", 0.0)
+	sim.AddNode("b", "machine learning", 0.0)
+	sim.AddNode("c", "machine learning", 0.0)
+	sim.AddEdge("a", "b")
+	sim.AddEdge("b", "c")
+	sim.AddEdge("c", "a") // cycle
+
+	root := NewContent([]byte("machine learning"))
+	ext := root.Extend([]byte("more machine learning"))
+
+	result := sim.Propagate(ext, "a")
+	if result.Reach != 2 {
+		t.Fatalf("reach = %d, want 2", result.Reach)
+	}
+	for _, id := range []string{"b", "c"} {
+		if n := len(sim.nodes[id].Received); n != 1 {
+			t.Fatalf("node %s received %d copies, want 1", id, n)
+		}
+	}
+}
+
+func TestAggregateStatsAndReset(t *testing.T) {
+	sim := NewGossipSimulator()
+	sim.AddNode("ml", "machine learning models", 0.05)
+	sim.AddNode("chef", "sourdough bread baking", 0.95)
+
+	root := NewContent([]byte("machine learning papers"))
+	sim.PropagateExtension(root.Extend([]byte("a machine learning result")))
+	sim.PropagateExtension(root.Extend([]byte("another machine learning result")))
+
+	agg := sim.AggregateStats()
+	if agg.TotalSeen != 4 {
+		t.Fatalf("TotalSeen = %d, want 4 (2 extensions x 2 nodes)", agg.TotalSeen)
+	}
+	if agg.Forwarded != 2 || agg.SemanticBlocked != 2 {
+		t.Fatalf("aggregate = %+v, want 2 forwarded and 2 blocked", agg)
+	}
+	if agg.BlockRate != 0.5 {
+		t.Fatalf("BlockRate = %f, want 0.5", agg.BlockRate)
+	}
+
+	sim.Reset()
+	if agg := sim.AggregateStats(); agg != (GatekeeperStats{}) {
+		t.Fatalf("stats after Reset = %+v, want zeroes", agg)
+	}
+	for id, node := range sim.nodes {
+		if len(node.Received) != 0 {
+			t.Fatalf("node %s kept %d received extensions after Reset", id, len(node.Received))
+		}
+	}
+}
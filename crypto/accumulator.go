@@ -0,0 +1,36 @@
+package crypto
+
+import "math/big"
+
+// Accumulator builds a set hash from a stream of elements. It keeps
+// one running sum and reduces it modulo Prime lazily, so folding in an
+// element costs a single big.Int addition rather than the
+// allocate-and-mod of repeated Extend. The zero value is not usable;
+// construct with NewAccumulator.
+type Accumulator struct {
+	sum *big.Int
+}
+
+// NewAccumulator returns an accumulator holding the empty set.
+func NewAccumulator() *Accumulator {
+	return &Accumulator{sum: big.NewInt(0)}
+}
+
+// Add folds one element into the set.
+func (a *Accumulator) Add(element []byte) {
+	a.AddHash(HashElement(element))
+}
+
+// AddHash folds in an already-hashed element, for callers that hold
+// precomputed element hashes.
+func (a *Accumulator) AddHash(h *Hash) {
+	a.sum.Add(a.sum, h.value)
+}
+
+// Sum returns the hash of everything added so far, equal to HashSet
+// over the same elements. The accumulator remains usable; later Adds
+// do not affect previously returned sums.
+func (a *Accumulator) Sum() *Hash {
+	v := new(big.Int).Mod(a.sum, Prime)
+	return &Hash{value: v}
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func randomElements(tb testing.TB, n, size int) [][]byte {
+	tb.Helper()
+	elements := make([][]byte, n)
+	for i := range elements {
+		elements[i] = make([]byte, size)
+		if _, err := rand.Read(elements[i]); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return elements
+}
+
+func TestAccumulatorMatchesHashSet(t *testing.T) {
+	elements := randomElements(t, 1000, 64)
+	want := HashSet(elements)
+
+	acc := NewAccumulator()
+	for _, el := range elements {
+		acc.Add(el)
+	}
+	if !acc.Sum().Equal(want) {
+		t.Fatalf("Accumulator sum %s != HashSet %s", acc.Sum(), want)
+	}
+
+	// Precomputed element hashes fold to the same result.
+	byHash := NewAccumulator()
+	for _, el := range elements {
+		byHash.AddHash(HashElement(el))
+	}
+	if !byHash.Sum().Equal(want) {
+		t.Fatalf("AddHash sum %s != HashSet %s", byHash.Sum(), want)
+	}
+
+	// Sum is a snapshot: adding afterwards must not disturb it.
+	mid := acc.Sum()
+	acc.Add([]byte("one more"))
+	if !mid.Equal(want) {
+		t.Fatal("later Add mutated a previously returned Sum")
+	}
+	if acc.Sum().Equal(want) {
+		t.Fatal("Sum ignored an element added after the snapshot")
+	}
+
+	if !NewAccumulator().Sum().Equal(Zero()) {
+		t.Fatal("empty accumulator is not the additive identity")
+	}
+}
+
+func BenchmarkAccumulate(b *testing.B) {
+	elements := randomElements(b, 1000, 64)
+	b.Run("accumulator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			acc := NewAccumulator()
+			for _, el := range elements {
+				acc.Add(el)
+			}
+			acc.Sum()
+		}
+	})
+	b.Run("repeated-extend", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			h := Zero()
+			for _, el := range elements {
+				h = Extend(h, el)
+			}
+		}
+	})
+}
@@ -0,0 +1,146 @@
+// Package crypto implements the homomorphic set hash that content
+// addressing in TERA is built on. Content is treated as a set of
+// elements; each element is hashed to an integer modulo a fixed prime,
+// and a set's hash is the sum of its element hashes. Appending an
+// element to existing content is therefore a cheap modular addition on
+// the hash side, which is what makes verifiable incremental extension
+// possible without re-hashing the whole history.
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// Prime is the modulus for the additive group the hashes live in.
+// 2^256 - 189 is the largest 256-bit prime, so element hashes derived
+// from SHA-256 fit with negligible bias.
+var Prime, _ = new(big.Int).SetString(
+	"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffd43", 16)
+
+// Hash is an element of the additive group modulo Prime. The zero
+// value is not usable; construct hashes with HashElement, HashSet,
+// Zero or FromHex.
+type Hash struct {
+	value *big.Int
+}
+
+// Zero returns the additive identity. Extending Zero with elements is
+// equivalent to hashing the set of those elements directly.
+func Zero() *Hash {
+	return &Hash{value: big.NewInt(0)}
+}
+
+// HashElement hashes a single element into the group.
+func HashElement(data []byte) *Hash {
+	sum := sha256.Sum256(data)
+	v := new(big.Int).SetBytes(sum[:])
+	v.Mod(v, Prime)
+	return &Hash{value: v}
+}
+
+// HashSet hashes a set of elements. The result is independent of
+// element order and equals the Combine of the individual element
+// hashes.
+func HashSet(elements [][]byte) *Hash {
+	acc := Zero()
+	for _, el := range elements {
+		acc = Combine(acc, HashElement(el))
+	}
+	return acc
+}
+
+// Combine adds two hashes in the group. It is commutative and
+// associative, so combining in any order yields the same hash.
+func Combine(a, b *Hash) *Hash {
+	// Adding the identity is a copy, not a modular addition — the
+	// common case for the first element folded into an accumulator.
+	if a.IsZero() {
+		return &Hash{value: new(big.Int).Set(b.value)}
+	}
+	if b.IsZero() {
+		return &Hash{value: new(big.Int).Set(a.value)}
+	}
+	v := new(big.Int).Add(a.value, b.value)
+	v.Mod(v, Prime)
+	return &Hash{value: v}
+}
+
+// Extend returns the hash of the parent set with delta added as a new
+// element. This is the core operation behind content extension.
+func Extend(parent *Hash, delta []byte) *Hash {
+	return Combine(parent, HashElement(delta))
+}
+
+// Subtract inverts Combine: it returns a - b in the group, the hash of
+// whatever elements a holds beyond b. Subtracting a set from a
+// superset leaves the hash of the difference, which makes two chains
+// sharing a common prefix comparable without replaying either.
+func Subtract(a, b *Hash) *Hash {
+	v := new(big.Int).Sub(a.value, b.value)
+	v.Mod(v, Prime)
+	return &Hash{value: v}
+}
+
+// IsSuperset reports whether child is exactly parent plus the given
+// elements. It generalizes VerifyExtension to multi-element additions:
+// the order of addedElements does not matter, only that their combined
+// hash accounts for the full difference.
+func IsSuperset(parent, child *Hash, addedElements [][]byte) bool {
+	return Subtract(child, parent).Equal(HashSet(addedElements))
+}
+
+// IsZero reports whether h is the additive identity, without the
+// allocation that comparing against a fresh Zero() costs.
+func (h *Hash) IsZero() bool {
+	return h != nil && h.value.Sign() == 0
+}
+
+// Equal reports whether two hashes are the same group element.
+func (h *Hash) Equal(other *Hash) bool {
+	if h == nil || other == nil {
+		return h == other
+	}
+	return h.value.Cmp(other.value) == 0
+}
+
+// Bytes returns the hash as a fixed-width 32-byte big-endian value.
+func (h *Hash) Bytes() []byte {
+	b := h.value.Bytes()
+	out := make([]byte, 32)
+	copy(out[32-len(b):], b)
+	return out
+}
+
+// Hex returns the lowercase hex encoding of Bytes.
+func (h *Hash) Hex() string {
+	return hex.EncodeToString(h.Bytes())
+}
+
+// String implements fmt.Stringer with a short prefix for logs.
+func (h *Hash) String() string {
+	return h.Hex()[:12]
+}
+
+// FromHex parses a hash previously produced by Hex.
+func FromHex(s string) (*Hash, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash hex: %w", err)
+	}
+	return FromBytes(b)
+}
+
+// FromBytes parses a fixed-width 32-byte value produced by Bytes.
+func FromBytes(b []byte) (*Hash, error) {
+	if len(b) != 32 {
+		return nil, fmt.Errorf("invalid hash length %d, want 32", len(b))
+	}
+	v := new(big.Int).SetBytes(b)
+	if v.Cmp(Prime) >= 0 {
+		return nil, fmt.Errorf("hash value out of range")
+	}
+	return &Hash{value: v}, nil
+}
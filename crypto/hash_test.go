@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHashElementDeterministic(t *testing.T) {
+	a := HashElement([]byte("hello"))
+	b := HashElement([]byte("hello"))
+	if !a.Equal(b) {
+		t.Fatal("same input produced different hashes")
+	}
+	c := HashElement([]byte("world"))
+	if a.Equal(c) {
+		t.Fatal("different inputs produced the same hash")
+	}
+}
+
+func TestHashSetOrderIndependent(t *testing.T) {
+	forward := HashSet([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	reverse := HashSet([][]byte{[]byte("c"), []byte("b"), []byte("a")})
+	if !forward.Equal(reverse) {
+		t.Fatal("set hash depends on element order")
+	}
+}
+
+func TestExtendMatchesHashSet(t *testing.T) {
+	elements := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	direct := HashSet(elements)
+	acc := Zero()
+	for _, el := range elements {
+		acc = Extend(acc, el)
+	}
+	if !acc.Equal(direct) {
+		t.Fatal("repeated Extend does not match HashSet")
+	}
+}
+
+func TestVerifyExtension(t *testing.T) {
+	parent := HashElement([]byte("root content"))
+	delta := []byte("appended text")
+	child := Extend(parent, delta)
+
+	if !VerifyExtension(parent, child, delta) {
+		t.Fatal("valid extension failed verification")
+	}
+	if VerifyExtension(parent, child, []byte("tampered")) {
+		t.Fatal("tampered delta passed verification")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	root := HashElement([]byte("genesis"))
+	deltas := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+	target := root
+	for _, d := range deltas {
+		target = Extend(target, d)
+	}
+
+	res := VerifyChain(root, deltas, target)
+	if !res.Valid {
+		t.Fatalf("valid chain rejected: %s", res.Reason)
+	}
+	if res.ChainLength != 3 {
+		t.Fatalf("ChainLength = %d, want 3", res.ChainLength)
+	}
+
+	bad := VerifyChain(root, [][]byte{[]byte("first"), []byte("wrong")}, target)
+	if bad.Valid {
+		t.Fatal("broken chain accepted")
+	}
+	if bad.Reason == "" {
+		t.Fatal("broken chain has no reason")
+	}
+	if bad.Code != FailureTargetNotReached {
+		t.Fatalf("broken chain code = %q, want %q", bad.Code, FailureTargetNotReached)
+	}
+	if res.Code != FailureNone {
+		t.Fatalf("valid chain code = %q, want none", res.Code)
+	}
+}
+
+func TestHexRoundTrip(t *testing.T) {
+	h := HashElement([]byte("round trip"))
+	parsed, err := FromHex(h.Hex())
+	if err != nil {
+		t.Fatalf("FromHex: %v", err)
+	}
+	if !parsed.Equal(h) {
+		t.Fatal("hex round trip changed the hash")
+	}
+	if !bytes.Equal(parsed.Bytes(), h.Bytes()) {
+		t.Fatal("byte encodings differ")
+	}
+	if _, err := FromHex("zz"); err == nil {
+		t.Fatal("invalid hex accepted")
+	}
+}
+
+func TestSubtractInvertsCombine(t *testing.T) {
+	a := HashElement([]byte("alpha"))
+	b := HashElement([]byte("beta"))
+	if !Subtract(Combine(a, b), b).Equal(a) {
+		t.Fatal("Subtract did not invert Combine")
+	}
+	if !Subtract(a, a).Equal(Zero()) {
+		t.Fatal("subtracting a hash from itself is not zero")
+	}
+	// Mod-Prime arithmetic: a - b + b wraps back to a even when a < b.
+	if !Combine(Subtract(a, b), b).Equal(a) {
+		t.Fatal("subtraction does not round-trip through the modulus")
+	}
+}
+
+func TestIsSupersetMultiElement(t *testing.T) {
+	added := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	parent := HashElement([]byte("base"))
+	child := parent
+	for _, el := range added {
+		child = Extend(child, el)
+	}
+
+	if !IsSuperset(parent, child, added) {
+		t.Fatal("multi-element superset check failed on a valid addition")
+	}
+	// Order independence: the same elements in another order verify too.
+	reordered := [][]byte{added[2], added[0], added[1]}
+	if !IsSuperset(parent, child, reordered) {
+		t.Fatal("superset check is order-sensitive")
+	}
+
+	wrong := [][]byte{added[0], added[1], []byte("forged")}
+	if IsSuperset(parent, child, wrong) {
+		t.Fatal("superset check passed with a wrong element")
+	}
+	if IsSuperset(parent, child, added[:2]) {
+		t.Fatal("superset check passed with a missing element")
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	if !Zero().IsZero() {
+		t.Fatal("Zero() is not IsZero")
+	}
+	if !HashSet(nil).IsZero() {
+		t.Fatal("hash of the empty set is not IsZero")
+	}
+	if HashElement([]byte("anything")).IsZero() {
+		t.Fatal("nonzero hash reported IsZero")
+	}
+	var h *Hash
+	if h.IsZero() {
+		t.Fatal("nil hash reported IsZero")
+	}
+
+	// The Combine fast paths must still return the right element.
+	a := HashElement([]byte("a"))
+	if !Combine(Zero(), a).Equal(a) || !Combine(a, Zero()).Equal(a) {
+		t.Fatal("combining with the identity changed the hash")
+	}
+}
+
+func BenchmarkCombine(b *testing.B) {
+	x := HashElement([]byte("left operand"))
+	y := HashElement([]byte("right operand"))
+	b.Run("nonzero", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Combine(x, y)
+		}
+	})
+	b.Run("zero", func(b *testing.B) {
+		zero := Zero()
+		for i := 0; i < b.N; i++ {
+			Combine(zero, y)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// Proof demonstrates that one element belongs to a set without
+// revealing the other elements. It pairs the set's homomorphic hash
+// with a Merkle path over the sorted element leaves: the homomorphic
+// root binds the proof to a known set, the path binds the target to
+// the Merkle root built from that same set.
+type Proof struct {
+	// SetHash is the homomorphic hash of the full element set.
+	SetHash *Hash
+	// MerkleRoot is the root of the sorted Merkle tree over the
+	// element leaf hashes.
+	MerkleRoot []byte
+	// Siblings are the sibling hashes along the path from the target
+	// leaf to the root, leaf-side first.
+	Siblings [][]byte
+	// Lefts[i] reports whether Siblings[i] sits to the left of the
+	// running hash at that level.
+	Lefts []bool
+}
+
+// merkleLeaf hashes an element into a leaf. The prefix byte separates
+// the leaf and interior domains so an interior node cannot be
+// reinterpreted as a leaf.
+func merkleLeaf(data []byte) []byte {
+	sum := sha256.Sum256(append([]byte{0x00}, data...))
+	return sum[:]
+}
+
+// merkleNode hashes two children into an interior node.
+func merkleNode(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, 0x01)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	sum := sha256.Sum256(buf)
+	return sum[:]
+}
+
+// sortedLeaves hashes every element and sorts the leaves so the tree
+// shape is independent of element order, matching the order
+// independence of the homomorphic hash.
+func sortedLeaves(elements [][]byte) [][]byte {
+	leaves := make([][]byte, len(elements))
+	for i, el := range elements {
+		leaves[i] = merkleLeaf(el)
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return bytes.Compare(leaves[i], leaves[j]) < 0
+	})
+	return leaves
+}
+
+// MerkleRoot computes the root of the sorted Merkle tree over the
+// elements. An odd node at any level is promoted unpaired.
+func MerkleRoot(elements [][]byte) []byte {
+	if len(elements) == 0 {
+		return nil
+	}
+	level := sortedLeaves(elements)
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// ProveMembership builds an inclusion proof that target is one of
+// elements. It fails when target is not in the set.
+func ProveMembership(elements [][]byte, target []byte) (*Proof, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("cannot prove membership in an empty set")
+	}
+	targetLeaf := merkleLeaf(target)
+	level := sortedLeaves(elements)
+	pos := -1
+	for i, leaf := range level {
+		if bytes.Equal(leaf, targetLeaf) {
+			pos = i
+			break
+		}
+	}
+	if pos == -1 {
+		return nil, fmt.Errorf("target is not an element of the set")
+	}
+
+	proof := &Proof{SetHash: HashSet(elements)}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			if i == pos || i+1 == pos {
+				sibling := i
+				if i == pos {
+					sibling = i + 1
+				}
+				proof.Siblings = append(proof.Siblings, level[sibling])
+				proof.Lefts = append(proof.Lefts, sibling == i)
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		pos /= 2
+		level = next
+	}
+	proof.MerkleRoot = level[0]
+	return proof, nil
+}
+
+// VerifyMembership checks an inclusion proof for target against the
+// homomorphic hash of the set. The verifier must already trust that
+// proof.MerkleRoot was built from the set rootHomomorphic names —
+// publish the two roots together.
+func VerifyMembership(rootHomomorphic *Hash, proof *Proof, target []byte) bool {
+	if proof == nil || !proof.SetHash.Equal(rootHomomorphic) {
+		return false
+	}
+	if len(proof.Siblings) != len(proof.Lefts) {
+		return false
+	}
+	current := merkleLeaf(target)
+	for i, sibling := range proof.Siblings {
+		if proof.Lefts[i] {
+			current = merkleNode(sibling, current)
+		} else {
+			current = merkleNode(current, sibling)
+		}
+	}
+	return bytes.Equal(current, proof.MerkleRoot)
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testElements(n int) [][]byte {
+	elements := make([][]byte, n)
+	for i := range elements {
+		elements[i] = []byte(fmt.Sprintf("element-%d", i))
+	}
+	return elements
+}
+
+func TestProveMembershipValid(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8} {
+		elements := testElements(n)
+		root := HashSet(elements)
+		for _, target := range elements {
+			proof, err := ProveMembership(elements, target)
+			if err != nil {
+				t.Fatalf("ProveMembership(n=%d, %q): %v", n, target, err)
+			}
+			if !VerifyMembership(root, proof, target) {
+				t.Fatalf("valid proof rejected for %q in set of %d", target, n)
+			}
+		}
+	}
+}
+
+func TestProveMembershipNonMember(t *testing.T) {
+	elements := testElements(5)
+	if _, err := ProveMembership(elements, []byte("intruder")); err == nil {
+		t.Fatal("proof produced for a non-member")
+	}
+	if _, err := ProveMembership(nil, []byte("anything")); err == nil {
+		t.Fatal("proof produced for the empty set")
+	}
+}
+
+func TestVerifyMembershipRejectsTampering(t *testing.T) {
+	elements := testElements(5)
+	root := HashSet(elements)
+	target := elements[2]
+	proof, err := ProveMembership(elements, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A proof for one element does not certify another.
+	if VerifyMembership(root, proof, elements[3]) {
+		t.Fatal("proof accepted for the wrong target")
+	}
+	// A proof bound to a different set hash is rejected.
+	if VerifyMembership(HashElement([]byte("other set")), proof, target) {
+		t.Fatal("proof accepted against the wrong set hash")
+	}
+	// Flipping a byte anywhere in the path breaks the root.
+	proof.Siblings[0][0] ^= 0xff
+	if VerifyMembership(root, proof, target) {
+		t.Fatal("tampered proof accepted")
+	}
+}
+
+func TestMerkleRootOrderIndependent(t *testing.T) {
+	a := [][]byte{[]byte("x"), []byte("y"), []byte("z")}
+	b := [][]byte{[]byte("z"), []byte("x"), []byte("y")}
+	if string(MerkleRoot(a)) != string(MerkleRoot(b)) {
+		t.Fatal("merkle root depends on element order")
+	}
+	if MerkleRoot(nil) != nil {
+		t.Fatal("empty set has a non-nil root")
+	}
+}
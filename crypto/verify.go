@@ -0,0 +1,65 @@
+package crypto
+
+import "fmt"
+
+// FailureCode classifies why a verification failed, so network code
+// can react programmatically: a bad hash warrants distrusting the
+// sender, while a missing record just means more data is needed.
+type FailureCode string
+
+const (
+	// FailureNone is the code on a valid result.
+	FailureNone FailureCode = ""
+	// FailureChainBreak means a link's parent is not the previous
+	// link's child: the records do not form a chain at all.
+	FailureChainBreak FailureCode = "chain_break"
+	// FailureInvalidExtension means a link's delta does not produce
+	// its claimed child hash.
+	FailureInvalidExtension FailureCode = "invalid_extension"
+	// FailureTargetNotReached means every link checked out but the
+	// chain ends somewhere other than the requested target.
+	FailureTargetNotReached FailureCode = "target_not_reached"
+	// FailureRecordNotFound means a delta or record needed for
+	// verification is not available locally.
+	FailureRecordNotFound FailureCode = "record_not_found"
+)
+
+// VerificationResult describes the outcome of verifying a single
+// extension or a whole chain. Reason is empty when Valid is true; Code
+// carries the same verdict in machine-readable form.
+type VerificationResult struct {
+	Valid       bool
+	ChainLength int
+	// FailedAt is the zero-based index of the first bad link when a
+	// chain verification fails, and -1 otherwise.
+	FailedAt int
+	Reason   string
+	Code     FailureCode
+	// RootHash is the chain's root when the verification discovered
+	// it rather than being handed it; nil otherwise.
+	RootHash *Hash
+}
+
+// VerifyExtension checks that child is parent extended by delta.
+func VerifyExtension(parent, child *Hash, delta []byte) bool {
+	return Extend(parent, delta).Equal(child)
+}
+
+// VerifyChain checks that applying each delta in order to root yields
+// target. It returns a result rather than a bare bool so callers can
+// report how far a broken chain got.
+func VerifyChain(root *Hash, deltas [][]byte, target *Hash) *VerificationResult {
+	res := &VerificationResult{ChainLength: len(deltas), FailedAt: -1}
+	current := root
+	for _, delta := range deltas {
+		current = Extend(current, delta)
+	}
+	if !current.Equal(target) {
+		res.Reason = fmt.Sprintf("chain of %d deltas does not reach target %s", len(deltas), target)
+		res.Code = FailureTargetNotReached
+		res.FailedAt = len(deltas)
+		return res
+	}
+	res.Valid = true
+	return res
+}
@@ -0,0 +1,76 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/peer"
+	p2pprotocol "github.com/libp2p/go-libp2p/core/protocol"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// dhtProtocolPrefix keeps the TERA DHT separate from other kad-dht
+// networks.
+const dhtProtocolPrefix = p2pprotocol.ID("/tera")
+
+// startDHT joins the kad-DHT, in server mode when configured and
+// client mode otherwise, and bootstraps its routing table.
+func (n *Node) startDHT() error {
+	mode := dht.ModeClient
+	if n.cfg.DHTServer {
+		mode = dht.ModeServer
+	}
+	d, err := dht.New(n.ctx, n.host,
+		dht.Mode(mode),
+		dht.ProtocolPrefix(dhtProtocolPrefix),
+	)
+	if err != nil {
+		return fmt.Errorf("create dht: %w", err)
+	}
+	n.dht = d
+	return d.Bootstrap(n.ctx)
+}
+
+// hashToCID wraps a content hash in the CID form the DHT provider
+// records use.
+func hashToCID(h *crypto.Hash) (cid.Cid, error) {
+	encoded, err := mh.Encode(h.Bytes(), mh.SHA2_256)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("encode multihash: %w", err)
+	}
+	return cid.NewCidV1(cid.Raw, encoded), nil
+}
+
+// Provide announces on the DHT that this node holds the content root
+// with the given hash.
+func (n *Node) Provide(h *crypto.Hash) error {
+	if n.dht == nil {
+		return fmt.Errorf("dht not enabled")
+	}
+	c, err := hashToCID(h)
+	if err != nil {
+		return err
+	}
+	return n.dht.Provide(n.ctx, c, true)
+}
+
+// FindProviders looks up which peers have announced the given content
+// root on the DHT.
+func (n *Node) FindProviders(ctx context.Context, h *crypto.Hash) ([]peer.AddrInfo, error) {
+	if n.dht == nil {
+		return nil, fmt.Errorf("dht not enabled")
+	}
+	c, err := hashToCID(h)
+	if err != nil {
+		return nil, err
+	}
+	var providers []peer.AddrInfo
+	for info := range n.dht.FindProvidersAsync(ctx, c, 20) {
+		providers = append(providers, info)
+	}
+	return providers, nil
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// startDHTNode brings up a node participating in the DHT as a server.
+func startDHTNode(t *testing.T, bootstrap string) *Node {
+	t.Helper()
+	n, err := NewNode(context.Background(), NodeConfig{
+		ListenPort:    0,
+		BootstrapPeer: bootstrap,
+		Interests:     []string{"machine learning"},
+		Threshold:     0.1,
+		EnableDHT:     true,
+		DHTServer:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestFindProvidersAcrossHops(t *testing.T) {
+	a := startDHTNode(t, "")
+	b := startDHTNode(t, a.AddrStrings()[0])
+	c := startDHTNode(t, b.AddrStrings()[0])
+
+	hash, err := a.Publish([]byte("machine learning survey paper"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := a.Provide(hash); err != nil {
+		t.Fatalf("Provide: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	providers, err := c.FindProviders(ctx, hash)
+	if err != nil {
+		t.Fatalf("FindProviders: %v", err)
+	}
+	for _, p := range providers {
+		if p.ID == a.ID() {
+			return
+		}
+	}
+	t.Fatalf("node A not among %d providers", len(providers))
+}
+
+func TestProvideWithoutDHT(t *testing.T) {
+	n := startNode(t, "")
+	hash, err := n.Publish([]byte("content without dht"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Provide(hash); err == nil {
+		t.Fatal("Provide succeeded with DHT disabled")
+	}
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"context"
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+)
+
+// MDNSServiceName tags TERA nodes in mDNS announcements so unrelated
+// libp2p applications on the same LAN are ignored.
+const MDNSServiceName = "tera-mdns"
+
+// discoveryNotifee connects to peers found via local discovery.
+type discoveryNotifee struct {
+	h   host.Host
+	ctx context.Context
+}
+
+// HandlePeerFound dials a newly discovered peer unless it is us or we
+// are already connected.
+func (d *discoveryNotifee) HandlePeerFound(info peer.AddrInfo) {
+	if info.ID == d.h.ID() {
+		return
+	}
+	if d.h.Network().Connectedness(info.ID) == libp2pnetwork.Connected {
+		return
+	}
+	if err := d.h.Connect(d.ctx, info); err != nil {
+		log.Printf("mdns: connect to %s: %v", info.ID, err)
+		return
+	}
+	// Local peers stay protected from connection-manager trimming.
+	d.h.ConnManager().Protect(info.ID, connTagMDNS)
+}
+
+// startMDNS begins announcing and browsing for TERA peers on the
+// local network.
+func (n *Node) startMDNS() error {
+	svc := mdns.NewMdnsService(n.host, MDNSServiceName, &discoveryNotifee{h: n.host, ctx: n.ctx})
+	return svc.Start()
+}
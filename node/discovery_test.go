@@ -0,0 +1,37 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDiscoveryNotifeeConnects(t *testing.T) {
+	a := startNode(t, "")
+	b := startNode(t, "")
+
+	notifee := &discoveryNotifee{h: a.host, ctx: context.Background()}
+	info := peer.AddrInfo{ID: b.host.ID(), Addrs: b.host.Addrs()}
+	notifee.HandlePeerFound(info)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for a.host.Network().Connectedness(b.host.ID()) != libp2pnetwork.Connected {
+		if time.Now().After(deadline) {
+			t.Fatal("notifee did not connect to the discovered peer")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestDiscoveryNotifeeIgnoresSelf(t *testing.T) {
+	a := startNode(t, "")
+	notifee := &discoveryNotifee{h: a.host, ctx: context.Background()}
+	// Must not panic or dial ourselves.
+	notifee.HandlePeerFound(peer.AddrInfo{ID: a.host.ID(), Addrs: a.host.Addrs()})
+	if len(a.Peers()) != 0 {
+		t.Fatal("node connected to itself")
+	}
+}
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"github.com/systemshift/tera/core"
+)
+
+// Interests returns the node's current interest texts.
+func (n *Node) Interests() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.cfg.Interests...)
+}
+
+// SetInterests replaces the node's interest set while it is running.
+// Queries are rebuilt with the node's configured threshold, and when
+// extension sharding is enabled any buckets the new interests hash
+// into are subscribed. Extensions already in flight are judged against
+// whichever interest set was current when they arrived.
+func (n *Node) SetInterests(interests []string) error {
+	queries := make([]*core.Query, 0, len(interests))
+	for _, interest := range interests {
+		queries = append(queries, core.NewQuery(interest, n.cfg.Threshold))
+	}
+	n.mu.Lock()
+	n.cfg.Interests = append([]string(nil), interests...)
+	n.interests = queries
+	n.mu.Unlock()
+
+	if n.cfg.ExtensionBuckets <= 0 {
+		return nil
+	}
+	added, err := n.refreshShardSubscriptions()
+	if err != nil {
+		return err
+	}
+	for _, sub := range added {
+		go n.listenLoop(sub)
+	}
+	return nil
+}
+
+// AddInterest appends one interest to the current set.
+func (n *Node) AddInterest(interest string) error {
+	return n.SetInterests(append(n.Interests(), interest))
+}
+
+// RemoveInterest drops every occurrence of interest from the current
+// set. Removing an interest the node does not hold is a no-op.
+func (n *Node) RemoveInterest(interest string) error {
+	var kept []string
+	for _, have := range n.Interests() {
+		if have != interest {
+			kept = append(kept, have)
+		}
+	}
+	return n.SetInterests(kept)
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// startMetrics serves Prometheus metrics at addr on a dedicated mux.
+// All collectors read live node state on scrape rather than keeping
+// parallel counters.
+func (n *Node) startMetrics(addr string) error {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tera_gatekeeper_seen_total",
+			Help: "Extensions the gatekeeper has decided on.",
+		}, func() float64 { return float64(n.gatekeeper.GetStats().TotalSeen) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tera_gatekeeper_forwarded_total",
+			Help: "Extensions forwarded by the gatekeeper.",
+		}, func() float64 { return float64(n.gatekeeper.GetStats().Forwarded) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tera_gatekeeper_crypto_blocked_total",
+			Help: "Extensions blocked for failing crypto verification.",
+		}, func() float64 { return float64(n.gatekeeper.GetStats().CryptoBlocked) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tera_gatekeeper_semantic_blocked_total",
+			Help: "Extensions blocked for semantic irrelevance.",
+		}, func() float64 { return float64(n.gatekeeper.GetStats().SemanticBlocked) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tera_messages_received_total",
+			Help: "Raw gossip messages received.",
+		}, func() float64 {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			return float64(n.msgsReceived)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tera_published_total",
+			Help: "Extensions published by this node.",
+		}, func() float64 {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			return float64(n.published)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tera_connected_peers",
+			Help: "Peers currently connected.",
+		}, func() float64 { return float64(len(n.host.Network().Peers())) }),
+	)
+
+	// Listen synchronously so a bad address fails NewNode instead of
+	// a background goroutine.
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	n.metricsListener = ln
+	n.metricsServer = &http.Server{Handler: mux}
+	go n.metricsServer.Serve(ln)
+	return nil
+}
+
+// MetricsAddr returns the address the metrics endpoint is serving on,
+// or empty when metrics are disabled.
+func (n *Node) MetricsAddr() string {
+	if n.metricsListener == nil {
+		return ""
+	}
+	return n.metricsListener.Addr().String()
+}
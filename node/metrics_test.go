@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+)
+
+func scrapeMetrics(t *testing.T, addr string) string {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", addr))
+	if err != nil {
+		t.Fatalf("scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read metrics body: %v", err)
+	}
+	return string(body)
+}
+
+func TestMetricsEndpointTracksGatekeeper(t *testing.T) {
+	n, err := NewNode(context.Background(), NodeConfig{
+		Interests:   []string{"machine learning"},
+		Threshold:   0.1,
+		MetricsAddr: "127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+
+	body := scrapeMetrics(t, n.MetricsAddr())
+	for _, metric := range []string{
+		"tera_gatekeeper_seen_total 0",
+		"tera_gatekeeper_forwarded_total 0",
+		"tera_connected_peers 0",
+	} {
+		if !strings.Contains(body, metric) {
+			t.Fatalf("metrics output missing %q:\n%s", metric, body)
+		}
+	}
+
+	ext := core.NewExtension(crypto.Zero(), []byte("neural networks are a machine learning technique"))
+	n.gatekeeper.ShouldForwardAny(ext, n.interests)
+
+	body = scrapeMetrics(t, n.MetricsAddr())
+	if !strings.Contains(body, "tera_gatekeeper_seen_total 1") {
+		t.Fatalf("seen counter did not increment:\n%s", body)
+	}
+	if !strings.Contains(body, "tera_gatekeeper_forwarded_total 1") {
+		t.Fatalf("forwarded counter did not increment:\n%s", body)
+	}
+}
+
+func TestMetricsDisabledByDefault(t *testing.T) {
+	n := startNode(t, "")
+	if addr := n.MetricsAddr(); addr != "" {
+		t.Fatalf("metrics unexpectedly serving on %s", addr)
+	}
+}
@@ -0,0 +1,912 @@
+// Package node runs a TERA participant on libp2p: it gossips
+// extensions over pubsub, gates everything it hears through the
+// crypto+semantic gatekeeper, stores what it forwards, and answers
+// content queries from other peers.
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/protocol"
+	"github.com/systemshift/tera/storage"
+)
+
+// ExtensionTopic is the pubsub topic all extensions gossip on.
+const ExtensionTopic = "tera/extensions/v1"
+
+// QueryResponseProtocol is the direct-stream protocol a responding
+// node dials the requester on. Responses go point to point rather than
+// back through the gossip mesh.
+const QueryResponseProtocol = "/tera/query-response/1.0.0"
+
+// NodeConfig configures a Node.
+type NodeConfig struct {
+	// ListenPort is the TCP port to listen on; 0 picks a free port.
+	ListenPort int
+	// BootstrapPeer is a full multiaddr (including /p2p/<id>) to dial
+	// at startup. Empty means start isolated.
+	BootstrapPeer string
+	// Interests are example texts describing what this node wants to
+	// store and forward.
+	Interests []string
+	// Threshold is the minimum similarity for an extension to be
+	// considered relevant to an interest.
+	Threshold float64
+	// DataDir is the badger directory for the node's store; empty
+	// keeps the store in memory.
+	DataDir string
+	// EnableMDNS announces this node over mDNS and auto-connects any
+	// TERA peers found on the local network.
+	EnableMDNS bool
+	// EnableDHT joins the TERA kad-DHT for peer discovery and content
+	// provider records.
+	EnableDHT bool
+	// DHTServer serves DHT records for others; leave false on
+	// resource-constrained or NATed nodes to run as a client.
+	DHTServer bool
+	// ExtensionRate and ExtensionBurst bound how many extensions per
+	// second a single publisher may send through this node before the
+	// rest are dropped unprocessed. Zero values take the defaults
+	// (10/sec, burst 20).
+	ExtensionRate  float64
+	ExtensionBurst int
+	// ExtensionBuckets shards extension gossip into this many
+	// semantic sub-topics; the node subscribes only to the buckets
+	// its interests hash into. Zero keeps everything on the base
+	// topic.
+	ExtensionBuckets int
+	// MetricsAddr serves Prometheus metrics over HTTP when non-empty,
+	// e.g. "127.0.0.1:9090" or ":0" for a free port.
+	MetricsAddr string
+	// MaxMessageSize bounds inbound gossip messages in bytes; larger
+	// payloads are dropped before unmarshaling. Zero takes the
+	// default of 1 MiB.
+	MaxMessageSize int
+	// ConnLowWater and ConnHighWater bound the connection count: once
+	// connections exceed the high watermark the connection manager
+	// trims back to the low one. Zero values take the defaults
+	// (100/400).
+	ConnLowWater  int
+	ConnHighWater int
+	// ConnGracePeriod shields new connections from trimming; zero
+	// takes the default of one minute.
+	ConnGracePeriod time.Duration
+	// SwarmKey is a 32-byte pre-shared key that closes the swarm: only
+	// nodes holding the same key can complete a connection handshake.
+	// Nil joins the open network. Generate one with GenerateSwarmKey.
+	SwarmKey []byte
+	// SeenCache selects duplicate suppression in the gatekeeper: "" or
+	// "lru" keeps the per-entry decision cache, "bloom" trades it for a
+	// constant-memory bloom filter that occasionally skips a fresh
+	// extension at BloomFPRate.
+	SeenCache string
+	// BloomExpected and BloomFPRate size the bloom dedup. Zero values
+	// take the defaults (100000 extensions at a 1% false-positive
+	// rate). Ignored unless SeenCache is "bloom".
+	BloomExpected int
+	BloomFPRate   float64
+	// BootstrapRetryInterval is how often the node checks that the
+	// bootstrap peer is still connected, redialing when it is not;
+	// zero takes the default of 5 seconds. Failed redials back off
+	// exponentially up to BootstrapMaxBackoff (default one minute).
+	BootstrapRetryInterval time.Duration
+	BootstrapMaxBackoff    time.Duration
+	// QueryTTL is the hop budget put on outgoing queries; zero takes
+	// the default of 3.
+	QueryTTL int
+	// BanScore is the bad-score at which a peer delivering forged
+	// extensions is disconnected; zero takes the default of 5.
+	BanScore float64
+	// SecurityTransports pins which security transports the host
+	// offers, in preference order; recognized values are "noise" and
+	// "tls". Pinning replaces the libp2p defaults entirely, so nodes
+	// with disjoint lists cannot complete a handshake. Empty keeps
+	// the defaults.
+	SecurityTransports []string
+	// GossipSubParams overrides the gossipsub mesh parameters (degree,
+	// gossip factor, heartbeat, ...); the defaults suit mid-sized
+	// swarms, but small meshes want a lower degree and very large ones
+	// a higher. Start from pubsub.DefaultGossipSubParams and adjust.
+	// Nil keeps the defaults.
+	GossipSubParams *pubsub.GossipSubParams
+}
+
+// seenQueryCap bounds the request-ID dedup set that stops query
+// re-processing loops.
+const seenQueryCap = 1024
+
+// GenerateSwarmKey returns a fresh pre-shared key for a private swarm.
+// Distribute it out of band to every node that should be allowed in.
+func GenerateSwarmKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		// crypto/rand failing is unrecoverable; matching the stdlib's
+		// own stance, treat it as fatal rather than returning a weak key.
+		panic(fmt.Sprintf("generate swarm key: %v", err))
+	}
+	return key
+}
+
+// Connection-manager tags marking peers the trimmer must never drop:
+// losing the bootstrap peer can partition a small mesh, and mDNS peers
+// are the local network we explicitly want to stay close to.
+const (
+	connTagBootstrap = "tera-bootstrap"
+	connTagMDNS      = "tera-mdns"
+)
+
+// Node is a running TERA participant.
+type Node struct {
+	cfg       NodeConfig
+	host      host.Host
+	ps        *pubsub.PubSub
+	topic     *pubsub.Topic
+	sub       *pubsub.Subscription
+	shards    map[int]*pubsub.Topic
+	shardSubs []*pubsub.Subscription
+	// shardBuckets remembers which buckets shardSubs covers so
+	// interest updates only subscribe what is missing.
+	shardBuckets map[int]bool
+	store        *storage.Store
+	gatekeeper   *core.Gatekeeper
+	// interests holds one pre-built query per configured interest;
+	// features are extracted once at startup, not per message.
+	interests []*core.Query
+	dht       *dht.IpfsDHT
+	limiter   *rateLimiter
+	scorer    *PeerScorer
+
+	metricsServer   *http.Server
+	metricsListener net.Listener
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending map[string]chan []protocol.QueryMatch
+
+	// seenQueries remembers recently processed query request IDs so a
+	// re-broadcast loop cannot make the node answer twice; seenOrder
+	// evicts the oldest entries at seenQueryCap.
+	seenQueries map[string]bool
+	seenOrder   []string
+
+	msgsReceived  int
+	published     int
+	sigInvalid    int
+	rateLimited   int
+	oversized     int
+	redials       int
+	queryForwards int
+}
+
+// NewNode starts a node: libp2p host, gossipsub subscription, store,
+// and the query-response stream handler.
+func NewNode(ctx context.Context, cfg NodeConfig) (*Node, error) {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = 0.3
+	}
+	if cfg.ExtensionRate == 0 {
+		cfg.ExtensionRate = 10
+	}
+	if cfg.ExtensionBurst == 0 {
+		cfg.ExtensionBurst = 20
+	}
+	if cfg.MaxMessageSize == 0 {
+		cfg.MaxMessageSize = 1 << 20
+	}
+	if cfg.ConnLowWater == 0 {
+		cfg.ConnLowWater = 100
+	}
+	if cfg.ConnHighWater == 0 {
+		cfg.ConnHighWater = 400
+	}
+	if cfg.ConnGracePeriod == 0 {
+		cfg.ConnGracePeriod = time.Minute
+	}
+	if cfg.BootstrapRetryInterval == 0 {
+		cfg.BootstrapRetryInterval = 5 * time.Second
+	}
+	if cfg.BootstrapMaxBackoff == 0 {
+		cfg.BootstrapMaxBackoff = time.Minute
+	}
+	if cfg.QueryTTL == 0 {
+		cfg.QueryTTL = 3
+	}
+	cm, err := connmgr.NewConnManager(cfg.ConnLowWater, cfg.ConnHighWater,
+		connmgr.WithGracePeriod(cfg.ConnGracePeriod))
+	if err != nil {
+		return nil, fmt.Errorf("create connection manager: %w", err)
+	}
+	hostOpts := []libp2p.Option{
+		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.ListenPort)),
+		libp2p.ConnectionManager(cm),
+	}
+	if cfg.SwarmKey != nil {
+		if len(cfg.SwarmKey) != 32 {
+			return nil, fmt.Errorf("swarm key must be 32 bytes, got %d", len(cfg.SwarmKey))
+		}
+		hostOpts = append(hostOpts, libp2p.PrivateNetwork(pnet.PSK(cfg.SwarmKey)))
+	}
+	for _, st := range cfg.SecurityTransports {
+		switch st {
+		case "noise":
+			hostOpts = append(hostOpts, libp2p.Security(noise.ID, noise.New))
+		case "tls":
+			hostOpts = append(hostOpts, libp2p.Security(libp2ptls.ID, libp2ptls.New))
+		default:
+			return nil, fmt.Errorf("unknown security transport %q", st)
+		}
+	}
+	h, err := libp2p.New(hostOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("create host: %w", err)
+	}
+	// Peer exchange lets pruned peers carry addresses through the
+	// mesh, so a swarm keeps knitting itself together beyond the
+	// bootstrap peer.
+	psOpts := []pubsub.Option{pubsub.WithPeerExchange(true)}
+	if cfg.GossipSubParams != nil {
+		psOpts = append(psOpts, pubsub.WithGossipSubParams(*cfg.GossipSubParams))
+	}
+	ps, err := pubsub.NewGossipSub(ctx, h, psOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("create gossipsub: %w", err)
+	}
+	topic, err := ps.Join(ExtensionTopic)
+	if err != nil {
+		return nil, fmt.Errorf("join topic: %w", err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+	store, err := storage.NewStore(storage.Config{Path: cfg.DataDir, InMemory: cfg.DataDir == ""})
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+
+	var gatekeeper *core.Gatekeeper
+	switch cfg.SeenCache {
+	case "", "lru":
+		gatekeeper = core.NewGatekeeper()
+	case "bloom":
+		if cfg.BloomExpected == 0 {
+			cfg.BloomExpected = 100000
+		}
+		if cfg.BloomFPRate == 0 {
+			cfg.BloomFPRate = 0.01
+		}
+		gatekeeper = core.NewGatekeeperWithBloom(cfg.BloomExpected, cfg.BloomFPRate)
+	default:
+		store.Close()
+		h.Close()
+		return nil, fmt.Errorf("unknown seen-cache mode %q", cfg.SeenCache)
+	}
+
+	nodeCtx, cancel := context.WithCancel(ctx)
+	n := &Node{
+		cfg:          cfg,
+		host:         h,
+		ps:           ps,
+		topic:        topic,
+		sub:          sub,
+		store:        store,
+		gatekeeper:   gatekeeper,
+		ctx:          nodeCtx,
+		cancel:       cancel,
+		limiter:      newRateLimiter(cfg.ExtensionRate, cfg.ExtensionBurst),
+		scorer:       NewPeerScorer(cfg.BanScore),
+		shards:       make(map[int]*pubsub.Topic),
+		shardBuckets: make(map[int]bool),
+		pending:      make(map[string]chan []protocol.QueryMatch),
+		seenQueries:  make(map[string]bool),
+	}
+	for _, interest := range cfg.Interests {
+		n.interests = append(n.interests, core.NewQuery(interest, cfg.Threshold))
+	}
+	h.SetStreamHandler(QueryResponseProtocol, n.handleQueryResponseStream)
+
+	// Reject provably invalid extensions at the pubsub layer so this
+	// node never relays them; shard topics register the same validator
+	// as they are joined.
+	if err := ps.RegisterTopicValidator(ExtensionTopic, n.validateExtensionMessage); err != nil {
+		n.Close()
+		return nil, fmt.Errorf("register validator: %w", err)
+	}
+
+	if cfg.ExtensionBuckets > 0 {
+		if err := n.subscribeInterestShards(); err != nil {
+			n.Close()
+			return nil, fmt.Errorf("subscribe shards: %w", err)
+		}
+	}
+
+	if cfg.BootstrapPeer != "" {
+		if err := n.connectToPeer(cfg.BootstrapPeer); err != nil {
+			n.Close()
+			return nil, fmt.Errorf("bootstrap: %w", err)
+		}
+		go n.reconnectLoop()
+	}
+	if cfg.EnableMDNS {
+		if err := n.startMDNS(); err != nil {
+			n.Close()
+			return nil, fmt.Errorf("start mdns: %w", err)
+		}
+	}
+	if cfg.EnableDHT {
+		if err := n.startDHT(); err != nil {
+			n.Close()
+			return nil, fmt.Errorf("start dht: %w", err)
+		}
+	}
+	if cfg.MetricsAddr != "" {
+		if err := n.startMetrics(cfg.MetricsAddr); err != nil {
+			n.Close()
+			return nil, fmt.Errorf("start metrics: %w", err)
+		}
+	}
+
+	go n.listenLoop(n.sub)
+	for _, sub := range n.shardSubs {
+		go n.listenLoop(sub)
+	}
+	return n, nil
+}
+
+// ID returns the node's peer ID.
+func (n *Node) ID() peer.ID {
+	return n.host.ID()
+}
+
+// AddrStrings returns the node's full dialable multiaddrs, suitable
+// for another node's BootstrapPeer.
+func (n *Node) AddrStrings() []string {
+	var out []string
+	for _, a := range n.host.Addrs() {
+		out = append(out, fmt.Sprintf("%s/p2p/%s", a, n.host.ID()))
+	}
+	return out
+}
+
+// Peers returns the currently connected peers.
+func (n *Node) Peers() []peer.ID {
+	return n.host.Network().Peers()
+}
+
+// Store exposes the node's backing store.
+func (n *Node) Store() *storage.Store {
+	return n.store
+}
+
+// Threshold is the node's configured minimum similarity to forward.
+func (n *Node) Threshold() float64 {
+	return n.cfg.Threshold
+}
+
+// GetStats returns the gatekeeper counters.
+func (n *Node) GetStats() core.GatekeeperStats {
+	return n.gatekeeper.GetStats()
+}
+
+// Close shuts the node down and releases its store.
+func (n *Node) Close() error {
+	n.cancel()
+	n.sub.Cancel()
+	n.mu.Lock()
+	shardSubs := n.shardSubs
+	n.mu.Unlock()
+	for _, sub := range shardSubs {
+		sub.Cancel()
+	}
+	if n.metricsServer != nil {
+		n.metricsServer.Close()
+	}
+	if err := n.host.Close(); err != nil {
+		return err
+	}
+	return n.store.Close()
+}
+
+// connectToPeer dials a peer given its full multiaddr.
+func (n *Node) connectToPeer(addr string) error {
+	ma, err := multiaddr.NewMultiaddr(addr)
+	if err != nil {
+		return fmt.Errorf("parse multiaddr %q: %w", addr, err)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(ma)
+	if err != nil {
+		return fmt.Errorf("peer info from %q: %w", addr, err)
+	}
+	if err := n.host.Connect(n.ctx, *info); err != nil {
+		return err
+	}
+	n.host.ConnManager().Protect(info.ID, connTagBootstrap)
+	return nil
+}
+
+// Publish stores new root content and announces it to the network as
+// an extension of the zero hash (Extend(Zero, data) == HashElement(data),
+// so root publishes and appends share one message type).
+func (n *Node) Publish(data []byte) (*crypto.Hash, error) {
+	return n.PublishContext(n.ctx, data)
+}
+
+// PublishContext is Publish bounded by a caller-supplied context, so
+// one publish can carry its own deadline or be cancelled without
+// touching the node.
+func (n *Node) PublishContext(ctx context.Context, data []byte) (*crypto.Hash, error) {
+	return n.publishExtension(ctx, core.NewExtension(crypto.Zero(), data))
+}
+
+// PublishExtension appends delta to an existing chain tip and
+// announces the extension.
+func (n *Node) PublishExtension(parent *crypto.Hash, delta []byte) (*crypto.Hash, error) {
+	return n.PublishExtensionContext(n.ctx, parent, delta)
+}
+
+// PublishExtensionContext is PublishExtension bounded by a
+// caller-supplied context.
+func (n *Node) PublishExtensionContext(ctx context.Context, parent *crypto.Hash, delta []byte) (*crypto.Hash, error) {
+	return n.publishExtension(ctx, core.NewExtension(parent, delta))
+}
+
+func (n *Node) publishExtension(ctx context.Context, ext *core.Extension) (*crypto.Hash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ext.Publisher = n.host.ID().String()
+	if err := n.store.PutExtension(ext); err != nil {
+		return nil, err
+	}
+	payload := &protocol.ExtensionPayload{
+		ParentCrypto: ext.Parent.Hex(),
+		NewCrypto:    ext.Child.Hex(),
+		NewData:      ext.Delta,
+		Features:     ext.Content.Features,
+		Publisher:    ext.Publisher,
+		Timestamp:    ext.Timestamp,
+	}
+	priv := n.host.Peerstore().PrivKey(n.host.ID())
+	if err := signExtensionPayload(priv, payload); err != nil {
+		return nil, err
+	}
+	msg, err := protocol.NewExtensionMessageFromPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := n.extensionTopics(ext.Content.Features)
+	if err != nil {
+		return nil, err
+	}
+	for _, topic := range topics {
+		if err := topic.Publish(ctx, wire); err != nil {
+			return nil, fmt.Errorf("publish: %w", err)
+		}
+	}
+	n.mu.Lock()
+	n.published++
+	n.mu.Unlock()
+	return ext.Child, nil
+}
+
+// Query broadcasts a query and collects matches delivered back over
+// direct streams until the timeout elapses.
+func (n *Node) Query(text string, threshold float64, timeout time.Duration) ([]protocol.QueryMatch, error) {
+	requestID, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []protocol.QueryMatch, 16)
+	n.mu.Lock()
+	n.pending[requestID] = ch
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.pending, requestID)
+		n.mu.Unlock()
+	}()
+
+	q := core.NewQuery(text, threshold)
+	msg, err := protocol.NewQueryMessage(requestID, n.host.ID().String(), q, n.cfg.QueryTTL)
+	if err != nil {
+		return nil, err
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.topic.Publish(n.ctx, wire); err != nil {
+		return nil, fmt.Errorf("publish query: %w", err)
+	}
+
+	// Several peers can hold the same content, so batches are merged
+	// keyed on content hash: each hash appears once, at the highest
+	// similarity any responder reported.
+	var matches []protocol.QueryMatch
+	index := make(map[string]int)
+	merge := func(batch []protocol.QueryMatch) {
+		for _, m := range batch {
+			if at, ok := index[m.NewCrypto]; ok {
+				if m.Similarity > matches[at].Similarity {
+					matches[at] = m
+				}
+				continue
+			}
+			index[m.NewCrypto] = len(matches)
+			matches = append(matches, m)
+		}
+	}
+	deadline := time.After(timeout)
+	for {
+		select {
+		case batch := <-ch:
+			merge(batch)
+		case <-deadline:
+			return matches, nil
+		case <-n.ctx.Done():
+			return matches, n.ctx.Err()
+		}
+	}
+}
+
+// listenLoop drains one pubsub subscription until the node stops.
+func (n *Node) listenLoop(sub *pubsub.Subscription) {
+	for {
+		msg, err := sub.Next(n.ctx)
+		if err != nil {
+			return
+		}
+		if msg.ReceivedFrom == n.host.ID() {
+			continue
+		}
+		n.handleMessage(msg.ReceivedFrom, msg.Data)
+	}
+}
+
+// handleMessage dispatches one raw gossip message from a peer.
+func (n *Node) handleMessage(from peer.ID, data []byte) {
+	n.mu.Lock()
+	n.msgsReceived++
+	n.mu.Unlock()
+
+	if len(data) > n.cfg.MaxMessageSize {
+		n.mu.Lock()
+		n.oversized++
+		n.mu.Unlock()
+		log.Printf("drop oversize message: %d bytes exceeds limit %d", len(data), n.cfg.MaxMessageSize)
+		return
+	}
+
+	msg, err := protocol.UnmarshalMessage(data)
+	if err != nil {
+		log.Printf("drop malformed message: %v", err)
+		return
+	}
+	switch msg.Type {
+	case protocol.MessageTypeExtension:
+		n.handleExtension(from, msg)
+	case protocol.MessageTypeQuery:
+		n.handleQuery(msg)
+	default:
+		log.Printf("drop message with unknown type %q", msg.Type)
+	}
+}
+
+// handleExtension runs an incoming extension through the gatekeeper
+// against all interests at once and stores it on a match. The crypto
+// verdict feeds the peer scorer against the delivering peer, and a
+// peer that crosses the ban line is disconnected.
+func (n *Node) handleExtension(from peer.ID, msg *protocol.Message) {
+	payload, err := protocol.DecodeExtensionPayload(msg)
+	if err != nil {
+		log.Printf("drop extension: %v", err)
+		return
+	}
+	if err := verifyExtensionPayload(payload); err != nil {
+		n.mu.Lock()
+		n.sigInvalid++
+		n.mu.Unlock()
+		log.Printf("drop extension with bad signature: %v", err)
+		return
+	}
+	// Rate-limit per verified publisher before doing any more work;
+	// extensions over the limit never reach the gatekeeper.
+	if !n.limiter.allow(payload.Publisher) {
+		n.mu.Lock()
+		n.rateLimited++
+		n.mu.Unlock()
+		return
+	}
+	ext, err := payload.ToExtension()
+	if err != nil {
+		log.Printf("drop extension: %v", err)
+		return
+	}
+	if ext.Deleted {
+		// Tombstones carry no content for the semantic gate to judge;
+		// they apply on crypto validity and publisher authority alone.
+		if !ext.Verify() {
+			log.Printf("drop tombstone %s: crypto verification failed", ext.Child)
+			n.recordDelivery(from, false)
+			return
+		}
+		if !n.mayTombstone(ext) {
+			log.Printf("drop tombstone %s: publisher %s did not publish the content", ext.Child, ext.Publisher)
+			return
+		}
+		if err := n.store.PutExtension(ext); err != nil {
+			log.Printf("store tombstone %s: %v", ext.Child, err)
+		}
+		return
+	}
+	// Snapshot the interest queries under the lock: SetInterests
+	// replaces the slice wholesale, so a snapshot stays coherent for
+	// the rest of this message.
+	n.mu.Lock()
+	interests := n.interests
+	n.mu.Unlock()
+	decision := n.gatekeeper.ShouldForwardAny(ext, interests)
+	if !decision.CryptoValid {
+		n.recordDelivery(from, false)
+	} else if decision.Forward {
+		n.recordDelivery(from, true)
+	}
+	if decision.Forward {
+		if err := n.store.PutExtension(ext); err != nil {
+			log.Printf("store extension %s: %v", ext.Child, err)
+		}
+	}
+}
+
+// recordDelivery feeds one extension outcome from a peer into the
+// scorer and disconnects the peer once it crosses the ban line.
+func (n *Node) recordDelivery(from peer.ID, valid bool) {
+	if from == "" {
+		return
+	}
+	if valid {
+		n.scorer.RecordValid(from)
+		return
+	}
+	n.scorer.RecordInvalid(from)
+	if n.scorer.ShouldBan(from) {
+		log.Printf("disconnect peer %s: bad-extension score %.1f", from, n.scorer.Score(from))
+		if err := n.host.Network().ClosePeer(from); err != nil {
+			log.Printf("disconnect peer %s: %v", from, err)
+		}
+	}
+}
+
+// mayTombstone reports whether the tombstone's (signature-verified)
+// publisher matches the recorded publisher of the content it
+// retracts. Content with no recorded publisher cannot be tombstoned
+// remotely.
+func (n *Node) mayTombstone(ext *core.Extension) bool {
+	rec, err := n.store.Graph.GetExtension(ext.Parent)
+	if err != nil {
+		return false
+	}
+	return rec.Publisher != "" && rec.Publisher == ext.Publisher
+}
+
+// handleQuery searches local storage and, on any match, dials the
+// requester directly with a query response.
+func (n *Node) handleQuery(msg *protocol.Message) {
+	payload, err := protocol.DecodeQueryPayload(msg)
+	if err != nil {
+		log.Printf("drop query: %v", err)
+		return
+	}
+	if !n.markQuerySeen(payload.RequestID) {
+		return
+	}
+	n.forwardQuery(payload)
+	q := core.NewQuery(payload.QueryText, payload.Threshold)
+	matches, err := n.searchLocal(q)
+	if err != nil {
+		log.Printf("query %s: local search: %v", payload.RequestID, err)
+		return
+	}
+	if len(matches) == 0 {
+		return
+	}
+	requester, err := peer.Decode(payload.RequesterID)
+	if err != nil {
+		log.Printf("query %s: bad requester id %q: %v", payload.RequestID, payload.RequesterID, err)
+		return
+	}
+	if err := n.sendQueryResponse(requester, payload.RequestID, matches); err != nil {
+		log.Printf("query %s: respond to %s: %v", payload.RequestID, requester, err)
+	}
+}
+
+// markQuerySeen records a query request ID, reporting false when it
+// was already processed; the dedup set is bounded at seenQueryCap.
+func (n *Node) markQuerySeen(requestID string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.seenQueries[requestID] {
+		return false
+	}
+	n.seenQueries[requestID] = true
+	n.seenOrder = append(n.seenOrder, requestID)
+	if len(n.seenOrder) > seenQueryCap {
+		delete(n.seenQueries, n.seenOrder[0])
+		n.seenOrder = n.seenOrder[1:]
+	}
+	return true
+}
+
+// forwardQuery re-broadcasts a query with one hop spent, unless its
+// TTL budget is exhausted. TTL 1 means "answer but do not forward";
+// zero (including queries from nodes predating the field) never
+// forwards.
+func (n *Node) forwardQuery(payload *protocol.QueryPayload) {
+	if payload.TTL <= 1 {
+		return
+	}
+	msg, err := protocol.NewQueryForwardMessage(payload)
+	if err != nil {
+		log.Printf("forward query %s: %v", payload.RequestID, err)
+		return
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		log.Printf("forward query %s: %v", payload.RequestID, err)
+		return
+	}
+	if err := n.topic.Publish(n.ctx, wire); err != nil {
+		log.Printf("forward query %s: %v", payload.RequestID, err)
+		return
+	}
+	n.mu.Lock()
+	n.queryForwards++
+	n.mu.Unlock()
+}
+
+// searchLocal scores every stored block against the query.
+func (n *Node) searchLocal(q *core.Query) ([]protocol.QueryMatch, error) {
+	hashes, err := n.store.Blocks.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []protocol.QueryMatch
+	for _, h := range hashes {
+		block, err := n.store.Blocks.Get(h)
+		if err != nil {
+			continue
+		}
+		c := core.NewContent(block.Data)
+		if sim := q.Similarity(c); sim >= q.Threshold {
+			matches = append(matches, protocol.QueryMatch{
+				NewCrypto:  h.Hex(),
+				Similarity: sim,
+			})
+		}
+	}
+	return matches, nil
+}
+
+// sendQueryResponse dials the requester on the response protocol and
+// writes a single response message.
+func (n *Node) sendQueryResponse(requester peer.ID, requestID string, matches []protocol.QueryMatch) error {
+	msg, err := protocol.NewQueryResponseMessage(requestID, matches)
+	if err != nil {
+		return err
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, 10*time.Second)
+	defer cancel()
+	stream, err := n.host.NewStream(ctx, requester, QueryResponseProtocol)
+	if err != nil {
+		return fmt.Errorf("open stream: %w", err)
+	}
+	defer stream.Close()
+	if _, err := stream.Write(wire); err != nil {
+		return fmt.Errorf("write response: %w", err)
+	}
+	return nil
+}
+
+// handleQueryResponseStream reads one response message off an inbound
+// stream and routes it to the waiting query.
+func (n *Node) handleQueryResponseStream(s libp2pnetwork.Stream) {
+	defer s.Close()
+	wire, err := io.ReadAll(s)
+	if err != nil {
+		log.Printf("read query response: %v", err)
+		return
+	}
+	msg, err := protocol.UnmarshalMessage(wire)
+	if err != nil {
+		log.Printf("drop query response: %v", err)
+		return
+	}
+	payload, err := protocol.DecodeQueryResponsePayload(msg)
+	if err != nil {
+		log.Printf("drop query response: %v", err)
+		return
+	}
+	n.handleQueryResponse(payload)
+}
+
+// handleQueryResponse delivers matches to the query that requested
+// them; responses for unknown or expired requests are dropped. A
+// payload listing the same content hash twice is deduplicated here,
+// keeping the best-scoring entry; duplicates across payloads are
+// merged by the collecting query.
+func (n *Node) handleQueryResponse(payload *protocol.QueryResponsePayload) {
+	n.mu.Lock()
+	ch, ok := n.pending[payload.RequestID]
+	n.mu.Unlock()
+	if !ok {
+		return
+	}
+	matches := dedupeMatches(payload.Matches)
+	select {
+	case ch <- matches:
+	default:
+		log.Printf("query %s: response buffer full, dropping %d matches",
+			payload.RequestID, len(matches))
+	}
+}
+
+// dedupeMatches collapses matches sharing a content hash to the one
+// with the highest similarity, preserving first-seen order.
+func dedupeMatches(matches []protocol.QueryMatch) []protocol.QueryMatch {
+	deduped := matches[:0:0]
+	index := make(map[string]int, len(matches))
+	for _, m := range matches {
+		if at, ok := index[m.NewCrypto]; ok {
+			if m.Similarity > deduped[at].Similarity {
+				deduped[at] = m
+			}
+			continue
+		}
+		index[m.NewCrypto] = len(deduped)
+		deduped = append(deduped, m)
+	}
+	return deduped
+}
+
+// newRequestID returns a random identifier for correlating query
+// responses.
+func newRequestID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
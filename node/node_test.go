@@ -0,0 +1,423 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/protocol"
+)
+
+// startNode brings up an in-process node on a random port.
+func startNode(t *testing.T, bootstrap string) *Node {
+	t.Helper()
+	n, err := NewNode(context.Background(), NodeConfig{
+		ListenPort:    0,
+		BootstrapPeer: bootstrap,
+		Interests:     []string{"machine learning"},
+		Threshold:     0.1,
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestQueryResponseDelivery(t *testing.T) {
+	a := startNode(t, "")
+	b := startNode(t, a.AddrStrings()[0])
+
+	// B holds content A will ask for.
+	if _, err := b.Publish([]byte("neural networks are a machine learning technique")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// Give gossipsub a moment to build the mesh before querying.
+	time.Sleep(2 * time.Second)
+
+	matches, err := a.Query("machine learning", 0.1, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("no matches delivered back over the response stream")
+	}
+	for _, m := range matches {
+		if m.Similarity < 0.1 {
+			t.Fatalf("match %s below threshold: %f", m.NewCrypto, m.Similarity)
+		}
+	}
+}
+
+func TestHandleQueryResponseRoutesToPendingQuery(t *testing.T) {
+	n := startNode(t, "")
+
+	ch := make(chan []protocol.QueryMatch, 1)
+	n.mu.Lock()
+	n.pending["req-test"] = ch
+	n.mu.Unlock()
+
+	n.handleQueryResponse(&protocol.QueryResponsePayload{
+		RequestID: "req-test",
+		Matches:   []protocol.QueryMatch{{NewCrypto: "abc", Similarity: 0.9}},
+	})
+
+	select {
+	case matches := <-ch:
+		if len(matches) != 1 || matches[0].Similarity != 0.9 {
+			t.Fatalf("matches = %+v", matches)
+		}
+	default:
+		t.Fatal("response not routed to the pending query")
+	}
+
+	// Unknown request IDs are dropped silently.
+	n.handleQueryResponse(&protocol.QueryResponsePayload{RequestID: "unknown"})
+}
+
+func TestPublishContextCancelled(t *testing.T) {
+	n := startNode(t, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err := n.PublishContext(ctx, []byte("should not go out"))
+	if err == nil {
+		t.Fatal("publish with cancelled context succeeded")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancelled publish took %s, want a prompt return", elapsed)
+	}
+}
+
+func TestHandleMessageDropsOversize(t *testing.T) {
+	n, err := NewNode(context.Background(), NodeConfig{
+		Interests:      []string{"machine learning"},
+		Threshold:      0.1,
+		MaxMessageSize: 128,
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+
+	n.handleMessage("", make([]byte, 256))
+	n.mu.Lock()
+	oversized, received := n.oversized, n.msgsReceived
+	n.mu.Unlock()
+	if oversized != 1 {
+		t.Fatalf("oversized = %d, want 1", oversized)
+	}
+	if received != 1 {
+		t.Fatalf("msgsReceived = %d, want 1", received)
+	}
+
+	// A small but malformed message passes the size gate and is
+	// dropped later, without counting as oversize.
+	n.handleMessage("", []byte("{"))
+	n.mu.Lock()
+	oversized = n.oversized
+	n.mu.Unlock()
+	if oversized != 1 {
+		t.Fatalf("oversized = %d after small message, want 1", oversized)
+	}
+}
+
+func TestConnManagerProtectsBootstrapPeer(t *testing.T) {
+	a := startNode(t, "")
+	b := startNode(t, a.AddrStrings()[0])
+
+	if b.host.ConnManager() == nil {
+		t.Fatal("host created without a connection manager")
+	}
+	if !b.host.ConnManager().IsProtected(a.ID(), connTagBootstrap) {
+		t.Fatal("bootstrap peer not protected from trimming")
+	}
+	if a.host.ConnManager().IsProtected(b.ID(), connTagBootstrap) {
+		t.Fatal("inbound peer unexpectedly carries the bootstrap tag")
+	}
+}
+
+func TestSwarmKeyClosesTheNetwork(t *testing.T) {
+	key := GenerateSwarmKey()
+
+	newKeyedNode := func(bootstrap string, swarmKey []byte) (*Node, error) {
+		n, err := NewNode(context.Background(), NodeConfig{
+			ListenPort:    0,
+			BootstrapPeer: bootstrap,
+			Interests:     []string{"machine learning"},
+			Threshold:     0.1,
+			SwarmKey:      swarmKey,
+		})
+		if err == nil {
+			t.Cleanup(func() { n.Close() })
+		}
+		return n, err
+	}
+
+	a, err := newKeyedNode("", key)
+	if err != nil {
+		t.Fatalf("NewNode with swarm key: %v", err)
+	}
+	if _, err := newKeyedNode(a.AddrStrings()[0], key); err != nil {
+		t.Fatalf("matching keys failed to connect: %v", err)
+	}
+	if _, err := newKeyedNode(a.AddrStrings()[0], GenerateSwarmKey()); err == nil {
+		t.Fatal("node with a different swarm key connected")
+	}
+}
+
+func TestSwarmKeyLengthValidated(t *testing.T) {
+	if _, err := NewNode(context.Background(), NodeConfig{SwarmKey: []byte("short")}); err == nil {
+		t.Fatal("NewNode accepted a malformed swarm key")
+	}
+}
+
+func TestBootstrapReconnects(t *testing.T) {
+	a := startNode(t, "")
+	b, err := NewNode(context.Background(), NodeConfig{
+		ListenPort:             0,
+		BootstrapPeer:          a.AddrStrings()[0],
+		Interests:              []string{"machine learning"},
+		Threshold:              0.1,
+		BootstrapRetryInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	// Sever the connection from both ends; the watchdog should redial.
+	b.host.ConnManager().Unprotect(a.ID(), connTagBootstrap)
+	if err := b.host.Network().ClosePeer(a.ID()); err != nil {
+		t.Fatalf("ClosePeer: %v", err)
+	}
+	if err := a.host.Network().ClosePeer(b.ID()); err != nil {
+		t.Fatalf("ClosePeer: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		redials := b.redials
+		b.mu.Unlock()
+		if redials > 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("no redial attempt after dropping the bootstrap connection")
+}
+
+func TestQueryTTLBoundsForwarding(t *testing.T) {
+	n := startNode(t, "")
+
+	inject := func(requestID string, ttl int) {
+		q := core.NewQuery("machine learning", 0.1)
+		msg, err := protocol.NewQueryMessage(requestID, n.ID().String(), q, ttl)
+		if err != nil {
+			t.Fatalf("NewQueryMessage: %v", err)
+		}
+		wire, err := protocol.MarshalMessage(msg)
+		if err != nil {
+			t.Fatalf("MarshalMessage: %v", err)
+		}
+		n.handleMessage("", wire)
+	}
+	forwards := func() int {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		return n.queryForwards
+	}
+
+	// TTL 1: answer locally, spend the last hop, forward nowhere.
+	inject("req-ttl-1", 1)
+	if forwards() != 0 {
+		t.Fatal("TTL=1 query was re-broadcast")
+	}
+
+	// A fresh ID with budget left is forwarded once...
+	inject("req-ttl-3", 3)
+	if forwards() != 1 {
+		t.Fatalf("TTL=3 query forwarded %d times, want 1", forwards())
+	}
+	// ...and a looped-back duplicate is not processed again.
+	inject("req-ttl-3", 3)
+	if forwards() != 1 {
+		t.Fatal("duplicate request ID was re-processed")
+	}
+}
+
+func TestSecurityTransportPinning(t *testing.T) {
+	newPinnedNode := func(transports ...string) *Node {
+		t.Helper()
+		n, err := NewNode(context.Background(), NodeConfig{
+			ListenPort:         0,
+			Interests:          []string{"machine learning"},
+			Threshold:          0.1,
+			SecurityTransports: transports,
+		})
+		if err != nil {
+			t.Fatalf("NewNode(%v): %v", transports, err)
+		}
+		t.Cleanup(func() { n.Close() })
+		return n
+	}
+
+	a := newPinnedNode("noise")
+	b := newPinnedNode("noise")
+	if err := b.connectToPeer(a.AddrStrings()[0]); err != nil {
+		t.Fatalf("noise-only nodes failed to connect: %v", err)
+	}
+
+	c := newPinnedNode("tls")
+	if err := c.connectToPeer(a.AddrStrings()[0]); err == nil {
+		t.Fatal("tls-only node completed a handshake with a noise-only node")
+	}
+
+	if _, err := NewNode(context.Background(), NodeConfig{
+		SecurityTransports: []string{"plaintext"},
+	}); err == nil {
+		t.Fatal("NewNode accepted an unknown security transport")
+	}
+}
+
+func TestAddInterestForwardsNewTopic(t *testing.T) {
+	n := startNode(t, "")
+
+	priv, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Gardening matches nothing the node is configured for, so the
+	// gatekeeper blocks it and nothing is stored.
+	before := []byte("watering schedule for tomato seedlings on the balcony")
+	injectExtension(t, n, priv, from, before, false)
+	if _, err := n.store.GetContent(core.NewExtension(crypto.Zero(), before).Child); err == nil {
+		t.Fatal("extension outside every interest was stored")
+	}
+
+	if err := n.AddInterest("gardening tips for spring"); err != nil {
+		t.Fatalf("AddInterest: %v", err)
+	}
+
+	// Distinct data so the gatekeeper's dedup cache cannot replay the
+	// earlier decision.
+	after := []byte("gardening tips: watering tomato seedlings in spring")
+	injectExtension(t, n, priv, from, after, false)
+	data, err := n.store.GetContent(core.NewExtension(crypto.Zero(), after).Child)
+	if err != nil {
+		t.Fatalf("extension matching the added interest was not stored: %v", err)
+	}
+	if string(data) != string(after) {
+		t.Fatalf("stored %q, want %q", data, after)
+	}
+
+	if err := n.RemoveInterest("gardening tips for spring"); err != nil {
+		t.Fatalf("RemoveInterest: %v", err)
+	}
+	if got := n.Interests(); len(got) != 1 || got[0] != "machine learning" {
+		t.Fatalf("interests after removal = %v", got)
+	}
+}
+
+func TestCustomGossipSubParamsDeliver(t *testing.T) {
+	// A tiny mesh degree fitting a two-node swarm; delivery working at
+	// all under it shows the custom parameters reached the router.
+	params := pubsub.DefaultGossipSubParams()
+	params.D = 2
+	params.Dlo = 1
+	params.Dhi = 3
+
+	start := func(bootstrap string) *Node {
+		n, err := NewNode(context.Background(), NodeConfig{
+			ListenPort:      0,
+			BootstrapPeer:   bootstrap,
+			Interests:       []string{"machine learning"},
+			Threshold:       0.1,
+			GossipSubParams: &params,
+		})
+		if err != nil {
+			t.Fatalf("NewNode with custom gossipsub params: %v", err)
+		}
+		t.Cleanup(func() { n.Close() })
+		return n
+	}
+	a := start("")
+	b := start(a.AddrStrings()[0])
+
+	// Give the reduced mesh a moment to form before publishing.
+	time.Sleep(2 * time.Second)
+
+	data := []byte("machine learning models for image recognition")
+	hash, err := b.Publish(data)
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, err := a.Store().GetContent(hash); err == nil {
+			if !bytes.Equal(got, data) {
+				t.Fatalf("delivered content = %q, want %q", got, data)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("extension never delivered over the custom-parameter mesh")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestHandleQueryResponseDeduplicatesMatches(t *testing.T) {
+	n := startNode(t, "")
+
+	ch := make(chan []protocol.QueryMatch, 1)
+	n.mu.Lock()
+	n.pending["req-dedup"] = ch
+	n.mu.Unlock()
+
+	n.handleQueryResponse(&protocol.QueryResponsePayload{
+		RequestID: "req-dedup",
+		Matches: []protocol.QueryMatch{
+			{NewCrypto: "aaa", Similarity: 0.4},
+			{NewCrypto: "bbb", Similarity: 0.7},
+			{NewCrypto: "aaa", Similarity: 0.9},
+			{NewCrypto: "aaa", Similarity: 0.5},
+		},
+	})
+
+	select {
+	case matches := <-ch:
+		if len(matches) != 2 {
+			t.Fatalf("got %d matches after dedup, want 2: %+v", len(matches), matches)
+		}
+		if matches[0].NewCrypto != "aaa" || matches[0].Similarity != 0.9 {
+			t.Fatalf("duplicate hash kept %+v, want the 0.9 entry", matches[0])
+		}
+		if matches[1].NewCrypto != "bbb" {
+			t.Fatalf("distinct match lost: %+v", matches)
+		}
+	default:
+		t.Fatal("deduped response not delivered")
+	}
+}
@@ -0,0 +1,51 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterFloodOnePublisher(t *testing.T) {
+	rl := newRateLimiter(10, 20)
+	now := time.Now()
+	rl.now = func() time.Time { return now } // all arrivals in one instant
+
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if rl.allow("spammer") {
+			allowed++
+		}
+	}
+	if allowed != 20 {
+		t.Fatalf("allowed = %d, want exactly the burst of 20", allowed)
+	}
+
+	// A second publisher has its own bucket and is unaffected.
+	if !rl.allow("honest") {
+		t.Fatal("second publisher limited by first publisher's flood")
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	rl := newRateLimiter(10, 10)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		rl.allow("pub")
+	}
+	if rl.allow("pub") {
+		t.Fatal("empty bucket allowed a send")
+	}
+
+	now = now.Add(500 * time.Millisecond) // refills 5 tokens at 10/sec
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if rl.allow("pub") {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Fatalf("allowed = %d after refill, want 5", allowed)
+	}
+}
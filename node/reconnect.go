@@ -0,0 +1,52 @@
+package node
+
+import (
+	"log"
+	"time"
+
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// reconnectLoop keeps the bootstrap connection alive: every retry
+// interval it checks connectivity and redials when the peer has
+// dropped. Failed redials back off exponentially up to the configured
+// maximum, resetting once a dial succeeds. The loop stops when the
+// node's context is cancelled.
+func (n *Node) reconnectLoop() {
+	ma, err := multiaddr.NewMultiaddr(n.cfg.BootstrapPeer)
+	if err != nil {
+		return // already rejected by the startup dial
+	}
+	info, err := peer.AddrInfoFromP2pAddr(ma)
+	if err != nil {
+		return
+	}
+
+	delay := n.cfg.BootstrapRetryInterval
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if n.host.Network().Connectedness(info.ID) == libp2pnetwork.Connected {
+			delay = n.cfg.BootstrapRetryInterval
+			continue
+		}
+		n.mu.Lock()
+		n.redials++
+		n.mu.Unlock()
+		if err := n.connectToPeer(n.cfg.BootstrapPeer); err != nil {
+			log.Printf("bootstrap redial %s: %v", info.ID, err)
+			delay *= 2
+			if delay > n.cfg.BootstrapMaxBackoff {
+				delay = n.cfg.BootstrapMaxBackoff
+			}
+			continue
+		}
+		log.Printf("bootstrap reconnected to %s", info.ID)
+		delay = n.cfg.BootstrapRetryInterval
+	}
+}
@@ -0,0 +1,76 @@
+package node
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// invalidCost and validReward shape a peer's bad-score: a forged
+// extension costs four good forwards to work off, so a peer mixing
+// spam into legitimate traffic still climbs toward the ban line.
+const (
+	invalidCost = 1.0
+	validReward = 0.25
+)
+
+// defaultBanScore is the bad-score at which a peer is banned.
+const defaultBanScore = 5.0
+
+// PeerScorer remembers which peers send spam. Each peer carries a
+// bad-score that rises when an extension it delivered fails crypto
+// verification and falls when one is forwarded; the score never goes
+// below zero, so a long good history cannot bank credit for a later
+// spam burst.
+type PeerScorer struct {
+	mu       sync.Mutex
+	scores   map[peer.ID]float64
+	banScore float64
+}
+
+// NewPeerScorer returns a scorer that bans at the given bad-score;
+// banScore <= 0 takes the default.
+func NewPeerScorer(banScore float64) *PeerScorer {
+	if banScore <= 0 {
+		banScore = defaultBanScore
+	}
+	return &PeerScorer{
+		scores:   make(map[peer.ID]float64),
+		banScore: banScore,
+	}
+}
+
+// RecordInvalid charges p for delivering an extension that failed
+// crypto verification.
+func (s *PeerScorer) RecordInvalid(p peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scores[p] += invalidCost
+}
+
+// RecordValid credits p for delivering an extension that was
+// forwarded.
+func (s *PeerScorer) RecordValid(p peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if score := s.scores[p] - validReward; score > 0 {
+		s.scores[p] = score
+	} else {
+		delete(s.scores, p)
+	}
+}
+
+// Score returns p's current bad-score; zero for an unknown or
+// well-behaved peer.
+func (s *PeerScorer) Score(p peer.ID) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scores[p]
+}
+
+// ShouldBan reports whether p's bad-score has reached the ban line.
+func (s *PeerScorer) ShouldBan(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scores[p] >= s.banScore
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/protocol"
+)
+
+func TestPeerScorerGoodHistoryBanksNoCredit(t *testing.T) {
+	s := NewPeerScorer(2)
+	p := peer.ID("well-behaved-then-not")
+
+	for i := 0; i < 20; i++ {
+		s.RecordValid(p)
+	}
+	if got := s.Score(p); got != 0 {
+		t.Fatalf("score after good history = %f, want 0", got)
+	}
+	s.RecordInvalid(p)
+	s.RecordInvalid(p)
+	if !s.ShouldBan(p) {
+		t.Fatalf("score %f did not cross ban line 2", s.Score(p))
+	}
+}
+
+// injectExtension signs an extension payload with priv and delivers it
+// to n as if gossip had received it from peer from. With forge set the
+// child hash is replaced, so the signature still verifies but crypto
+// verification of the extension fails.
+func injectExtension(t *testing.T, n *Node, priv p2pcrypto.PrivKey, from peer.ID, data []byte, forge bool) {
+	t.Helper()
+	ext := core.NewExtension(crypto.Zero(), data)
+	payload := &protocol.ExtensionPayload{
+		ParentCrypto: ext.Parent.Hex(),
+		NewCrypto:    ext.Child.Hex(),
+		NewData:      ext.Delta,
+		Features:     ext.Content.Features,
+		Publisher:    from.String(),
+		Timestamp:    ext.Timestamp,
+	}
+	if forge {
+		payload.NewCrypto = crypto.HashElement(append([]byte("forged"), data...)).Hex()
+	}
+	if err := signExtensionPayload(priv, payload); err != nil {
+		t.Fatalf("signExtensionPayload: %v", err)
+	}
+	msg, err := protocol.NewExtensionMessageFromPayload(payload)
+	if err != nil {
+		t.Fatalf("NewExtensionMessageFromPayload: %v", err)
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		t.Fatalf("MarshalMessage: %v", err)
+	}
+	n.handleMessage(from, wire)
+}
+
+func TestSpammerCrossesBanLine(t *testing.T) {
+	n := startNode(t, "")
+
+	newPeer := func() (p2pcrypto.PrivKey, peer.ID) {
+		priv, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return priv, id
+	}
+	honestPriv, honest := newPeer()
+	spamPriv, spammer := newPeer()
+
+	// Distinct data per delivery so the gatekeeper's dedup cache
+	// never short-circuits a decision.
+	for i := 0; i < 6; i++ {
+		injectExtension(t, n, honestPriv,
+			honest, []byte(fmt.Sprintf("machine learning models, batch %d", i)), false)
+		injectExtension(t, n, spamPriv,
+			spammer, []byte(fmt.Sprintf("machine learning models, batch %d (forged)", i)), true)
+	}
+
+	if !n.scorer.ShouldBan(spammer) {
+		t.Fatalf("spammer score %f did not cross the ban line", n.scorer.Score(spammer))
+	}
+	if n.scorer.ShouldBan(honest) {
+		t.Fatalf("honest peer banned with score %f", n.scorer.Score(honest))
+	}
+	if got := n.scorer.Score(honest); got != 0 {
+		t.Fatalf("honest peer score = %f, want 0", got)
+	}
+}
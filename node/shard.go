@@ -0,0 +1,102 @@
+package node
+
+import (
+	"fmt"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+
+	"github.com/systemshift/tera/semantic"
+)
+
+// shardTopicName returns the pubsub topic of one semantic bucket.
+func shardTopicName(bucket int) string {
+	return fmt.Sprintf("%s/%d", ExtensionTopic, bucket)
+}
+
+// subscribeInterestShards joins and subscribes the union of the
+// buckets the node's interests hash into, so the node only receives
+// extension gossip that can plausibly match an interest.
+func (n *Node) subscribeInterestShards() error {
+	_, err := n.refreshShardSubscriptions()
+	return err
+}
+
+// refreshShardSubscriptions subscribes any interest buckets the node
+// is not yet in, returning the new subscriptions for the caller to
+// drive. Buckets that no longer match any interest stay subscribed:
+// the gatekeeper discards irrelevant extensions anyway, and mesh churn
+// costs more than the stray gossip.
+func (n *Node) refreshShardSubscriptions() ([]*pubsub.Subscription, error) {
+	buckets := make(map[int]bool)
+	for _, interest := range n.Interests() {
+		features := semantic.ExtractFeatures([]byte(interest))
+		for _, b := range semantic.SemanticBucket(features, n.cfg.ExtensionBuckets) {
+			buckets[b] = true
+		}
+	}
+	var added []*pubsub.Subscription
+	for b := range buckets {
+		n.mu.Lock()
+		subscribed := n.shardBuckets[b]
+		n.mu.Unlock()
+		if subscribed {
+			continue
+		}
+		topic, err := n.shardTopic(b)
+		if err != nil {
+			return added, err
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			return added, fmt.Errorf("subscribe bucket %d: %w", b, err)
+		}
+		n.mu.Lock()
+		n.shardBuckets[b] = true
+		n.shardSubs = append(n.shardSubs, sub)
+		n.mu.Unlock()
+		added = append(added, sub)
+	}
+	return added, nil
+}
+
+// shardTopic returns the joined topic for a bucket, joining it on
+// first use. Publishing to a bucket the node does not subscribe to is
+// normal: publishers follow the content, subscribers their interests.
+func (n *Node) shardTopic(bucket int) (*pubsub.Topic, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if topic, ok := n.shards[bucket]; ok {
+		return topic, nil
+	}
+	if err := n.ps.RegisterTopicValidator(shardTopicName(bucket), n.validateExtensionMessage); err != nil {
+		return nil, fmt.Errorf("register validator for bucket %d: %w", bucket, err)
+	}
+	topic, err := n.ps.Join(shardTopicName(bucket))
+	if err != nil {
+		return nil, fmt.Errorf("join bucket %d: %w", bucket, err)
+	}
+	n.shards[bucket] = topic
+	return topic, nil
+}
+
+// extensionTopics returns the topics an extension should be announced
+// on: its content's semantic buckets when sharding is enabled, or the
+// base topic otherwise (also the fallback for keyword-free content).
+func (n *Node) extensionTopics(features *semantic.Features) ([]*pubsub.Topic, error) {
+	if n.cfg.ExtensionBuckets <= 0 {
+		return []*pubsub.Topic{n.topic}, nil
+	}
+	buckets := semantic.SemanticBucket(features, n.cfg.ExtensionBuckets)
+	if len(buckets) == 0 {
+		return []*pubsub.Topic{n.topic}, nil
+	}
+	topics := make([]*pubsub.Topic, 0, len(buckets))
+	for _, b := range buckets {
+		topic, err := n.shardTopic(b)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
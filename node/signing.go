@@ -0,0 +1,67 @@
+package node
+
+import (
+	"fmt"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/systemshift/tera/protocol"
+)
+
+// signExtensionPayload signs the payload's canonical bytes with the
+// publisher's private key and attaches the signature and serialized
+// public key. The payload's Publisher must already be set.
+func signExtensionPayload(priv p2pcrypto.PrivKey, p *protocol.ExtensionPayload) error {
+	data, err := p.SigningBytes()
+	if err != nil {
+		return err
+	}
+	sig, err := priv.Sign(data)
+	if err != nil {
+		return fmt.Errorf("sign extension: %w", err)
+	}
+	pub, err := p2pcrypto.MarshalPublicKey(priv.GetPublic())
+	if err != nil {
+		return fmt.Errorf("marshal pubkey: %w", err)
+	}
+	p.Signature = sig
+	p.PublisherPubKey = pub
+	return nil
+}
+
+// verifyExtensionPayload checks that the payload carries a signature,
+// that the attached key actually belongs to the claimed Publisher peer
+// ID, and that the signature covers the canonical bytes.
+func verifyExtensionPayload(p *protocol.ExtensionPayload) error {
+	if len(p.Signature) == 0 || len(p.PublisherPubKey) == 0 {
+		return fmt.Errorf("extension is unsigned")
+	}
+	pub, err := p2pcrypto.UnmarshalPublicKey(p.PublisherPubKey)
+	if err != nil {
+		return fmt.Errorf("unmarshal pubkey: %w", err)
+	}
+	claimed, err := peer.Decode(p.Publisher)
+	if err != nil {
+		return fmt.Errorf("decode publisher %q: %w", p.Publisher, err)
+	}
+	derived, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return fmt.Errorf("derive peer id: %w", err)
+	}
+	if derived != claimed {
+		return fmt.Errorf("pubkey belongs to %s, not claimed publisher %s", derived, claimed)
+	}
+	data, err := p.SigningBytes()
+	if err != nil {
+		return err
+	}
+	ok, err := pub.Verify(data, p.Signature)
+	if err != nil {
+		return fmt.Errorf("verify signature: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("signature does not match payload")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package node
+
+import (
+	"crypto/rand"
+	"testing"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/protocol"
+)
+
+func signedTestPayload(t *testing.T) (*protocol.ExtensionPayload, p2pcrypto.PrivKey) {
+	t.Helper()
+	priv, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := core.NewContent([]byte("signed root"))
+	ext := root.Extend([]byte("signed delta"))
+	payload := &protocol.ExtensionPayload{
+		ParentCrypto: ext.Parent.Hex(),
+		NewCrypto:    ext.Child.Hex(),
+		NewData:      ext.Delta,
+		Publisher:    id.String(),
+		Timestamp:    ext.Timestamp,
+	}
+	if err := signExtensionPayload(priv, payload); err != nil {
+		t.Fatalf("signExtensionPayload: %v", err)
+	}
+	return payload, priv
+}
+
+func TestSignAndVerifyExtension(t *testing.T) {
+	payload, _ := signedTestPayload(t)
+	if err := verifyExtensionPayload(payload); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+}
+
+func TestVerifyRejectsTamperedData(t *testing.T) {
+	payload, _ := signedTestPayload(t)
+	payload.NewData = append(payload.NewData, []byte(" tampered")...)
+	if err := verifyExtensionPayload(payload); err == nil {
+		t.Fatal("tampered NewData passed verification")
+	}
+}
+
+func TestVerifyRejectsWrongPublisher(t *testing.T) {
+	payload, _ := signedTestPayload(t)
+	otherPriv, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, _ := peer.IDFromPrivateKey(otherPriv)
+	payload.Publisher = otherID.String()
+	if err := verifyExtensionPayload(payload); err == nil {
+		t.Fatal("signature accepted for a different claimed publisher")
+	}
+}
+
+func TestVerifyRejectsUnsigned(t *testing.T) {
+	payload, _ := signedTestPayload(t)
+	payload.Signature = nil
+	payload.PublisherPubKey = nil
+	if err := verifyExtensionPayload(payload); err == nil {
+		t.Fatal("unsigned extension accepted")
+	}
+}
+
+func TestSignatureSurvivesWire(t *testing.T) {
+	payload, _ := signedTestPayload(t)
+	msg, err := protocol.NewExtensionMessageFromPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := protocol.UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := protocol.DecodeExtensionPayload(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyExtensionPayload(got); err != nil {
+		t.Fatalf("signature broken by wire round trip: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/storage"
+)
+
+// NodeSnapshot is a point-in-time view of a node's health: network
+// reach, message throughput, the gatekeeper counters, and what the
+// store holds.
+type NodeSnapshot struct {
+	// ConnectedPeers counts peers with a live connection.
+	ConnectedPeers int `json:"connected_peers"`
+	// TopicPeers counts peers known on the extension topic, the mesh
+	// the node actually gossips with.
+	TopicPeers int `json:"topic_peers"`
+	// MsgsReceived counts raw gossip messages delivered to this node.
+	MsgsReceived int `json:"msgs_received"`
+	// Published counts extensions this node has published.
+	Published int `json:"published"`
+	// Gatekeeper holds the forwarding-decision counters.
+	Gatekeeper core.GatekeeperStats `json:"gatekeeper"`
+	// Storage summarizes the backing store; nil when the stats read
+	// failed.
+	Storage *storage.StoreStats `json:"storage,omitempty"`
+}
+
+// Snapshot aggregates the node's operational metrics into one read.
+// The message counters come from a single critical section, so they
+// are consistent with each other; the peer counts and store stats are
+// each read at their own instant, which is as consistent as the
+// underlying layers allow.
+func (n *Node) Snapshot() NodeSnapshot {
+	n.mu.Lock()
+	snap := NodeSnapshot{
+		MsgsReceived: n.msgsReceived,
+		Published:    n.published,
+	}
+	n.mu.Unlock()
+
+	snap.ConnectedPeers = len(n.host.Network().Peers())
+	snap.TopicPeers = len(n.topic.ListPeers())
+	snap.Gatekeeper = n.gatekeeper.GetStats()
+	if stats, err := n.store.GetStats(); err == nil {
+		snap.Storage = stats
+	}
+	return snap
+}
@@ -0,0 +1,64 @@
+package node
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSnapshotReflectsActivity(t *testing.T) {
+	a := startNode(t, "")
+
+	empty := a.Snapshot()
+	if empty.MsgsReceived != 0 || empty.Published != 0 {
+		t.Fatalf("fresh node snapshot not empty: %+v", empty)
+	}
+	if empty.Storage == nil {
+		t.Fatal("snapshot missing storage stats")
+	}
+
+	// One published extension, one delivered over (simulated) gossip.
+	if _, err := a.Publish([]byte("machine learning models in production")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	priv, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	injectExtension(t, a, priv, from, []byte("training machine learning models"), false)
+
+	startNode(t, a.AddrStrings()[0])
+
+	snap := a.Snapshot()
+	if snap.Published != 1 {
+		t.Fatalf("Published = %d, want 1", snap.Published)
+	}
+	if snap.MsgsReceived != 1 {
+		t.Fatalf("MsgsReceived = %d, want 1", snap.MsgsReceived)
+	}
+	if snap.ConnectedPeers == 0 {
+		t.Fatal("ConnectedPeers = 0 after a bootstrap connection")
+	}
+	if snap.Gatekeeper.TotalSeen == 0 {
+		t.Fatal("gatekeeper counters missing from snapshot")
+	}
+	if snap.Storage == nil || snap.Storage.BlockCount == 0 {
+		t.Fatalf("storage stats = %+v, want stored blocks counted", snap.Storage)
+	}
+
+	// The topic peer count fills in once gossipsub builds the mesh.
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Snapshot().TopicPeers == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("TopicPeers still 0 after mesh formation window")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"context"
+	"log"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/systemshift/tera/protocol"
+)
+
+// validateExtensionMessage is the pubsub topic validator for extension
+// topics. It runs before a message is accepted into the mesh, so an
+// extension whose crypto claim does not verify is dropped here and
+// never relayed onward — the node refuses to amplify spam it can prove
+// invalid. Relevance stays out of it: semantic gating is a per-node
+// judgment, not a validity one, and queries ride the same topics
+// untouched.
+func (n *Node) validateExtensionMessage(ctx context.Context, from peer.ID, msg *pubsub.Message) bool {
+	if len(msg.Data) > n.cfg.MaxMessageSize {
+		return false
+	}
+	m, err := protocol.UnmarshalMessage(msg.Data)
+	if err != nil {
+		return false
+	}
+	if m.Type != protocol.MessageTypeExtension {
+		return true
+	}
+	payload, err := protocol.DecodeExtensionPayload(m)
+	if err != nil {
+		return false
+	}
+	ext, err := payload.ToExtension()
+	if err != nil {
+		return false
+	}
+	if !ext.Verify() {
+		log.Printf("validator: reject extension %s from %s: crypto verification failed", ext.Child, from)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/protocol"
+)
+
+// extensionWire marshals a signed extension message, optionally with a
+// forged child hash so crypto verification fails.
+func extensionWire(t *testing.T, data []byte, forge bool) []byte {
+	t.Helper()
+	priv, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := core.NewExtension(crypto.Zero(), data)
+	payload := &protocol.ExtensionPayload{
+		ParentCrypto: ext.Parent.Hex(),
+		NewCrypto:    ext.Child.Hex(),
+		NewData:      ext.Delta,
+		Features:     ext.Content.Features,
+		Publisher:    id.String(),
+		Timestamp:    ext.Timestamp,
+	}
+	if forge {
+		payload.NewCrypto = crypto.HashElement(append([]byte("forged"), data...)).Hex()
+	}
+	if err := signExtensionPayload(priv, payload); err != nil {
+		t.Fatalf("signExtensionPayload: %v", err)
+	}
+	msg, err := protocol.NewExtensionMessageFromPayload(payload)
+	if err != nil {
+		t.Fatalf("NewExtensionMessageFromPayload: %v", err)
+	}
+	wire, err := protocol.MarshalMessage(msg)
+	if err != nil {
+		t.Fatalf("MarshalMessage: %v", err)
+	}
+	return wire
+}
+
+func TestValidatorRejectsForgedExtension(t *testing.T) {
+	n := startNode(t, "")
+	validate := func(wire []byte) bool {
+		return n.validateExtensionMessage(context.Background(), "",
+			&pubsub.Message{Message: &pubsub_pb.Message{Data: wire}})
+	}
+
+	if !validate(extensionWire(t, []byte("a perfectly ordinary extension"), false)) {
+		t.Fatal("validator rejected a valid extension")
+	}
+	if validate(extensionWire(t, []byte("a perfectly ordinary extension"), true)) {
+		t.Fatal("validator accepted an extension with a forged child hash")
+	}
+	if validate([]byte("not a protocol message")) {
+		t.Fatal("validator accepted a malformed message")
+	}
+
+	// Non-extension messages are not the validator's business.
+	query, err := protocol.NewQueryMessage("req-validate", n.ID().String(),
+		core.NewQuery("machine learning", 0.1), 1)
+	if err != nil {
+		t.Fatalf("NewQueryMessage: %v", err)
+	}
+	wire, err := protocol.MarshalMessage(query)
+	if err != nil {
+		t.Fatalf("MarshalMessage: %v", err)
+	}
+	if !validate(wire) {
+		t.Fatal("validator rejected a query message")
+	}
+}
+
+func TestValidatorStopsForgedExtensionBeforeDelivery(t *testing.T) {
+	a := startNode(t, "")
+	b := startNode(t, a.AddrStrings()[0])
+	time.Sleep(2 * time.Second)
+
+	data := []byte("machine learning models, forged in transit")
+	forged := extensionWire(t, data, true)
+	// Local publishes run through the same validator, so the spam is
+	// refused before it ever reaches the mesh.
+	if err := b.topic.Publish(b.ctx, forged); err == nil {
+		t.Error("publishing a forged extension passed local validation")
+	}
+
+	time.Sleep(time.Second)
+	child := core.NewExtension(crypto.Zero(), data).Child
+	if _, err := a.store.GetContent(child); err == nil {
+		t.Fatal("forged extension was delivered and stored")
+	}
+	a.mu.Lock()
+	received := a.msgsReceived
+	a.mu.Unlock()
+	if received != 0 {
+		t.Fatalf("forged extension reached the subscription: %d messages received", received)
+	}
+}
@@ -0,0 +1,238 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// binaryFormatVersion is the first byte of every binary-encoded
+// message. Legacy JSON messages start with '{', so the two formats
+// are distinguishable from the first byte.
+const binaryFormatVersion = 0x01
+
+// marshalBinary encodes an envelope in the compact binary format.
+// Extension payloads get a dedicated field-level encoding (raw hash
+// bytes, no base64 for NewData); other payload types embed their JSON
+// bytes as-is.
+func marshalBinary(m *Message) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(binaryFormatVersion)
+	writeString(buf, m.Version)
+	writeString(buf, string(m.Type))
+	writeInt64(buf, m.Timestamp)
+
+	if m.Type == MessageTypeExtension {
+		p, err := DecodeExtensionPayload(m)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeExtensionPayload(buf, p); err != nil {
+			return nil, err
+		}
+	} else {
+		writeBytes(buf, m.Payload)
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalBinary decodes a binary envelope back into the same
+// Message form the JSON path produces.
+func unmarshalBinary(data []byte) (*Message, error) {
+	r := bytes.NewReader(data[1:])
+	version, err := readString(r)
+	if err != nil {
+		return nil, fmt.Errorf("read version: %w", err)
+	}
+	msgType, err := readString(r)
+	if err != nil {
+		return nil, fmt.Errorf("read type: %w", err)
+	}
+	timestamp, err := readInt64(r)
+	if err != nil {
+		return nil, fmt.Errorf("read timestamp: %w", err)
+	}
+	m := &Message{
+		Version:   version,
+		Type:      MessageType(msgType),
+		Timestamp: timestamp,
+	}
+
+	if m.Type == MessageTypeExtension {
+		p, err := readExtensionPayload(r)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		m.Payload = raw
+	} else {
+		payload, err := readBytes(r)
+		if err != nil {
+			return nil, fmt.Errorf("read payload: %w", err)
+		}
+		m.Payload = payload
+	}
+	return m, nil
+}
+
+// writeExtensionPayload encodes payload fields directly: hashes as
+// their raw 32 bytes, NewData without base64, and Features as an
+// optional JSON blob the receiver can skip entirely.
+func writeExtensionPayload(buf *bytes.Buffer, p *ExtensionPayload) error {
+	parent, err := crypto.FromHex(p.ParentCrypto)
+	if err != nil {
+		return fmt.Errorf("parent hash: %w", err)
+	}
+	child, err := crypto.FromHex(p.NewCrypto)
+	if err != nil {
+		return fmt.Errorf("new hash: %w", err)
+	}
+	buf.Write(parent.Bytes())
+	buf.Write(child.Bytes())
+	writeBytes(buf, p.NewData)
+	writeString(buf, p.Publisher)
+	writeInt64(buf, p.Timestamp)
+
+	writeBytes(buf, p.Signature)
+	writeBytes(buf, p.PublisherPubKey)
+
+	var flags byte
+	if p.Features != nil {
+		flags |= extFlagFeatures
+	}
+	if p.Deleted {
+		flags |= extFlagDeleted
+	}
+	windowed := p.ValidFrom != 0 || p.ValidUntil != 0
+	if windowed {
+		flags |= extFlagWindow
+	}
+	buf.WriteByte(flags)
+	if p.Features != nil {
+		features, err := json.Marshal(p.Features)
+		if err != nil {
+			return err
+		}
+		writeBytes(buf, features)
+	}
+	if windowed {
+		writeInt64(buf, p.ValidFrom)
+		writeInt64(buf, p.ValidUntil)
+	}
+	return nil
+}
+
+// Extension payload flag bits. extFlagFeatures keeps the value the
+// old boolean has-features byte used, so pre-flag messages decode
+// unchanged.
+const (
+	extFlagFeatures = 1 << 0
+	extFlagDeleted  = 1 << 1
+	// extFlagWindow marks a payload carrying a validity window; layouts
+	// written before the bit existed decode with an open window.
+	extFlagWindow = 1 << 2
+)
+
+func readExtensionPayload(r *bytes.Reader) (*ExtensionPayload, error) {
+	parentRaw := make([]byte, 32)
+	if _, err := io.ReadFull(r, parentRaw); err != nil {
+		return nil, fmt.Errorf("read parent hash: %w", err)
+	}
+	childRaw := make([]byte, 32)
+	if _, err := io.ReadFull(r, childRaw); err != nil {
+		return nil, fmt.Errorf("read new hash: %w", err)
+	}
+	p := &ExtensionPayload{
+		ParentCrypto: fmt.Sprintf("%x", parentRaw),
+		NewCrypto:    fmt.Sprintf("%x", childRaw),
+	}
+	var err error
+	if p.NewData, err = readBytes(r); err != nil {
+		return nil, fmt.Errorf("read new data: %w", err)
+	}
+	if p.Publisher, err = readString(r); err != nil {
+		return nil, fmt.Errorf("read publisher: %w", err)
+	}
+	if p.Timestamp, err = readInt64(r); err != nil {
+		return nil, fmt.Errorf("read timestamp: %w", err)
+	}
+	if p.Signature, err = readBytes(r); err != nil {
+		return nil, fmt.Errorf("read signature: %w", err)
+	}
+	if p.PublisherPubKey, err = readBytes(r); err != nil {
+		return nil, fmt.Errorf("read publisher pubkey: %w", err)
+	}
+	if len(p.Signature) == 0 {
+		p.Signature = nil
+	}
+	if len(p.PublisherPubKey) == 0 {
+		p.PublisherPubKey = nil
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("read payload flags: %w", err)
+	}
+	p.Deleted = flags&extFlagDeleted != 0
+	if flags&extFlagFeatures != 0 {
+		raw, err := readBytes(r)
+		if err != nil {
+			return nil, fmt.Errorf("read features: %w", err)
+		}
+		if err := json.Unmarshal(raw, &p.Features); err != nil {
+			return nil, fmt.Errorf("decode features: %w", err)
+		}
+	}
+	if flags&extFlagWindow != 0 {
+		if p.ValidFrom, err = readInt64(r); err != nil {
+			return nil, fmt.Errorf("read valid from: %w", err)
+		}
+		if p.ValidUntil, err = readInt64(r); err != nil {
+			return nil, fmt.Errorf("read valid until: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	buf.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	buf.Write(b)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeBytes(buf, []byte(s))
+}
+
+func writeInt64(buf *bytes.Buffer, v int64) {
+	buf.Write(binary.AppendVarint(nil, v))
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, fmt.Errorf("declared length %d exceeds remaining %d bytes", n, r.Len())
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	b, err := readBytes(r)
+	return string(b), err
+}
+
+func readInt64(r *bytes.Reader) (int64, error) {
+	return binary.ReadVarint(r)
+}
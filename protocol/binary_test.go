@@ -0,0 +1,145 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/systemshift/tera/core"
+)
+
+func TestBinaryRoundTripFidelity(t *testing.T) {
+	root := core.NewContent([]byte("some root content for the chain"))
+	ext := root.Extend([]byte("a delta with some words in it"))
+	ext.Publisher = "node-x"
+
+	msg, err := NewExtensionMessage(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := MarshalMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wire[0] != binaryFormatVersion {
+		t.Fatalf("wire format byte = 0x%02x, want 0x%02x", wire[0], binaryFormatVersion)
+	}
+
+	decoded, err := UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := DecodeExtensionPayload(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.ToExtension()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Parent.Equal(ext.Parent) || !got.Child.Equal(ext.Child) {
+		t.Fatal("hashes changed through binary round trip")
+	}
+	if !bytes.Equal(got.Delta, ext.Delta) {
+		t.Fatal("delta changed through binary round trip")
+	}
+	if got.Publisher != ext.Publisher || got.Timestamp != ext.Timestamp {
+		t.Fatal("metadata changed through binary round trip")
+	}
+	if p.Features == nil {
+		t.Fatal("features dropped from a full extension message")
+	}
+}
+
+func TestBinarySmallerThanJSON(t *testing.T) {
+	root := core.NewContent([]byte("original content with a reasonable amount of text"))
+	ext := root.Extend(bytes.Repeat([]byte("binary data \x00\x01\x02 "), 64))
+
+	msg, err := NewExtensionMessage(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaryWire, err := MarshalMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonWire, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(binaryWire) >= len(jsonWire) {
+		t.Fatalf("binary (%d bytes) not smaller than JSON (%d bytes)",
+			len(binaryWire), len(jsonWire))
+	}
+
+	compact, err := NewCompactExtensionMessage(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactWire, err := MarshalMessage(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compactWire) >= len(binaryWire) {
+		t.Fatalf("compact message (%d bytes) not smaller than full (%d bytes)",
+			len(compactWire), len(binaryWire))
+	}
+}
+
+func TestCompactMessageRecomputesFeatures(t *testing.T) {
+	root := core.NewContent([]byte("root"))
+	ext := root.Extend([]byte("machine learning delta"))
+
+	msg, err := NewCompactExtensionMessage(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, _ := MarshalMessage(msg)
+	decoded, err := UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := DecodeExtensionPayload(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Features != nil {
+		t.Fatal("compact message carried features")
+	}
+	got, err := p.ToExtension()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content.Features == nil || got.Content.Features.WordCount != 3 {
+		t.Fatalf("features not recomputed from NewData: %+v", got.Content.Features)
+	}
+}
+
+func TestLegacyJSONStillAccepted(t *testing.T) {
+	root := core.NewContent([]byte("legacy"))
+	ext := root.Extend([]byte("old format delta"))
+	msg, err := NewExtensionMessage(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonWire, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalMessage(jsonWire)
+	if err != nil {
+		t.Fatalf("legacy JSON rejected: %v", err)
+	}
+	if _, err := DecodeExtensionPayload(decoded); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnknownFormatByte(t *testing.T) {
+	if _, err := UnmarshalMessage([]byte{0x7f, 0x00}); err == nil {
+		t.Fatal("unknown format byte accepted")
+	}
+	if _, err := UnmarshalMessage(nil); err == nil {
+		t.Fatal("empty message accepted")
+	}
+}
@@ -0,0 +1,315 @@
+// Package protocol defines the wire format TERA nodes gossip with.
+// Every message is a versioned envelope with a typed JSON payload;
+// payload types carry hashes as hex so they survive any transport.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/semantic"
+)
+
+// Version tags every message envelope. Receivers accept any version
+// within a supported major line, so minor and patch bumps roll out
+// without partitioning the network.
+const Version = "tera/1.0.0"
+
+// MaxNewDataSize bounds the delta carried in an extension payload.
+// NewData is attacker-controlled, so ToExtension rejects anything
+// larger before feature extraction allocates for it.
+const MaxNewDataSize = 1 << 20
+
+// MessageType discriminates the envelope payload.
+type MessageType string
+
+const (
+	MessageTypeExtension     MessageType = "extension"
+	MessageTypeQuery         MessageType = "query"
+	MessageTypeQueryResponse MessageType = "query_response"
+)
+
+// Message is the envelope for all gossip traffic.
+type Message struct {
+	Version   string          `json:"version"`
+	Type      MessageType     `json:"type"`
+	Timestamp int64           `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// ExtensionPayload announces an append to existing content. Hashes
+// travel as hex; NewData is the delta itself so receivers can verify
+// and re-extract features locally.
+type ExtensionPayload struct {
+	ParentCrypto string             `json:"parent_crypto"`
+	NewCrypto    string             `json:"new_crypto"`
+	NewData      []byte             `json:"new_data"`
+	Features     *semantic.Features `json:"features,omitempty"`
+	Publisher    string             `json:"publisher,omitempty"`
+	Timestamp    int64              `json:"timestamp"`
+	// ValidFrom and ValidUntil bound when receivers may accept the
+	// extension (UnixNano); zero leaves that side of the window open.
+	ValidFrom  int64 `json:"valid_from,omitempty"`
+	ValidUntil int64 `json:"valid_until,omitempty"`
+	// Deleted marks a tombstone retracting the parent content;
+	// NewData is empty on such payloads.
+	Deleted bool `json:"deleted,omitempty"`
+	// Signature is the publisher's signature over SigningBytes, and
+	// PublisherPubKey the serialized key to verify it with. The key
+	// must correspond to the Publisher peer ID.
+	Signature       []byte `json:"signature,omitempty"`
+	PublisherPubKey []byte `json:"publisher_pub_key,omitempty"`
+}
+
+// SigningBytes returns the canonical bytes a publisher signs: both
+// hashes, the delta, the publisher identity, the timestamp, and the
+// validity window when one is set. Features are excluded (receivers
+// may recompute or strip them) and so are the signature fields
+// themselves.
+func (p *ExtensionPayload) SigningBytes() ([]byte, error) {
+	parent, err := crypto.FromHex(p.ParentCrypto)
+	if err != nil {
+		return nil, fmt.Errorf("parent hash: %w", err)
+	}
+	child, err := crypto.FromHex(p.NewCrypto)
+	if err != nil {
+		return nil, fmt.Errorf("new hash: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	buf.Write(parent.Bytes())
+	buf.Write(child.Bytes())
+	writeBytes(buf, p.NewData)
+	writeString(buf, p.Publisher)
+	writeInt64(buf, p.Timestamp)
+	if p.Deleted {
+		// Appended only for tombstones so signatures over ordinary
+		// payloads keep their pre-tombstone byte layout.
+		buf.WriteByte(1)
+	}
+	if p.ValidFrom != 0 || p.ValidUntil != 0 {
+		// The window is signed so a relay cannot strip or stretch it;
+		// appended only when set, keeping windowless signatures on
+		// their original layout.
+		writeInt64(buf, p.ValidFrom)
+		writeInt64(buf, p.ValidUntil)
+	}
+	return buf.Bytes(), nil
+}
+
+// QueryPayload asks the network for content similar to the query
+// text. RequesterID carries the asking peer's ID so responders can
+// dial it back directly instead of broadcasting.
+type QueryPayload struct {
+	RequestID   string  `json:"request_id"`
+	RequesterID string  `json:"requester_id"`
+	QueryText   string  `json:"query_text"`
+	Threshold   float64 `json:"threshold"`
+	// TTL is the remaining hop budget: each node that re-broadcasts
+	// the query decrements it and drops the query at zero, bounding
+	// amplification. Zero (also the value from nodes predating the
+	// field) means answer locally but never re-broadcast.
+	TTL int `json:"ttl,omitempty"`
+}
+
+// QueryMatch is one result in a query response.
+type QueryMatch struct {
+	NewCrypto  string  `json:"new_crypto"`
+	Similarity float64 `json:"similarity"`
+}
+
+// QueryResponsePayload carries a responder's matches for a query.
+type QueryResponsePayload struct {
+	RequestID string       `json:"request_id"`
+	Matches   []QueryMatch `json:"matches"`
+}
+
+// newMessage wraps a payload in a versioned envelope.
+func newMessage(t MessageType, payload interface{}) (*Message, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", t, err)
+	}
+	return &Message{
+		Version:   Version,
+		Type:      t,
+		Timestamp: time.Now().UnixNano(),
+		Payload:   raw,
+	}, nil
+}
+
+// NewExtensionMessage builds an extension announcement from a core
+// extension.
+func NewExtensionMessage(ext *core.Extension) (*Message, error) {
+	payload := &ExtensionPayload{
+		ParentCrypto: ext.Parent.Hex(),
+		NewCrypto:    ext.Child.Hex(),
+		NewData:      ext.Delta,
+		Features:     ext.Content.Features,
+		Publisher:    ext.Publisher,
+		Timestamp:    ext.Timestamp,
+		ValidFrom:    ext.ValidFrom,
+		ValidUntil:   ext.ValidUntil,
+		Deleted:      ext.Deleted,
+	}
+	return newMessage(MessageTypeExtension, payload)
+}
+
+// NewCompactExtensionMessage is NewExtensionMessage without the
+// Features blob; receivers recompute features from NewData, trading a
+// little CPU for a much smaller gossip message.
+func NewCompactExtensionMessage(ext *core.Extension) (*Message, error) {
+	payload := &ExtensionPayload{
+		ParentCrypto: ext.Parent.Hex(),
+		NewCrypto:    ext.Child.Hex(),
+		NewData:      ext.Delta,
+		Publisher:    ext.Publisher,
+		Timestamp:    ext.Timestamp,
+		ValidFrom:    ext.ValidFrom,
+		ValidUntil:   ext.ValidUntil,
+		Deleted:      ext.Deleted,
+	}
+	return newMessage(MessageTypeExtension, payload)
+}
+
+// NewExtensionMessageFromPayload wraps an already-built (typically
+// signed) extension payload in an envelope.
+func NewExtensionMessageFromPayload(p *ExtensionPayload) (*Message, error) {
+	return newMessage(MessageTypeExtension, p)
+}
+
+// NewQueryMessage builds a network query from a core query.
+// requesterID is the peer ID responses should be streamed back to and
+// ttl the hop budget for re-broadcasts.
+func NewQueryMessage(requestID, requesterID string, q *core.Query, ttl int) (*Message, error) {
+	payload := &QueryPayload{
+		RequestID:   requestID,
+		RequesterID: requesterID,
+		QueryText:   string(q.Content.Data),
+		Threshold:   q.Threshold,
+		TTL:         ttl,
+	}
+	return newMessage(MessageTypeQuery, payload)
+}
+
+// NewQueryForwardMessage re-wraps an in-flight query with its TTL
+// decremented, for the forwarding hop.
+func NewQueryForwardMessage(p *QueryPayload) (*Message, error) {
+	forwarded := *p
+	forwarded.TTL--
+	return newMessage(MessageTypeQuery, &forwarded)
+}
+
+// NewQueryResponseMessage builds a response for a previously received
+// query.
+func NewQueryResponseMessage(requestID string, matches []QueryMatch) (*Message, error) {
+	payload := &QueryResponsePayload{RequestID: requestID, Matches: matches}
+	return newMessage(MessageTypeQueryResponse, payload)
+}
+
+// MarshalMessage serializes an envelope for the wire using the
+// compact binary format.
+func MarshalMessage(m *Message) ([]byte, error) {
+	return marshalBinary(m)
+}
+
+// UnmarshalMessage parses an envelope, accepting both the binary
+// format and the legacy JSON format (which always starts with '{'),
+// and rejects versions outside the supported major lines.
+func UnmarshalMessage(data []byte) (*Message, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty message")
+	}
+	var m *Message
+	switch data[0] {
+	case binaryFormatVersion:
+		var err error
+		m, err = unmarshalBinary(data)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal binary message: %w", err)
+		}
+	case '{':
+		m = &Message{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("unmarshal message: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown message format byte 0x%02x", data[0])
+	}
+	if !VersionCompatible(m.Version) {
+		return nil, fmt.Errorf("unsupported protocol version %q, this build speaks %s",
+			m.Version, strings.Join(SupportedVersions(), ", "))
+	}
+	return m, nil
+}
+
+// DecodeExtensionPayload parses the payload of an extension message.
+func DecodeExtensionPayload(m *Message) (*ExtensionPayload, error) {
+	if m.Type != MessageTypeExtension {
+		return nil, fmt.Errorf("message type %s is not an extension", m.Type)
+	}
+	var p ExtensionPayload
+	if err := json.Unmarshal(m.Payload, &p); err != nil {
+		return nil, fmt.Errorf("unmarshal extension payload: %w", err)
+	}
+	return &p, nil
+}
+
+// DecodeQueryPayload parses the payload of a query message.
+func DecodeQueryPayload(m *Message) (*QueryPayload, error) {
+	if m.Type != MessageTypeQuery {
+		return nil, fmt.Errorf("message type %s is not a query", m.Type)
+	}
+	var p QueryPayload
+	if err := json.Unmarshal(m.Payload, &p); err != nil {
+		return nil, fmt.Errorf("unmarshal query payload: %w", err)
+	}
+	return &p, nil
+}
+
+// DecodeQueryResponsePayload parses the payload of a query response.
+func DecodeQueryResponsePayload(m *Message) (*QueryResponsePayload, error) {
+	if m.Type != MessageTypeQueryResponse {
+		return nil, fmt.Errorf("message type %s is not a query response", m.Type)
+	}
+	var p QueryResponsePayload
+	if err := json.Unmarshal(m.Payload, &p); err != nil {
+		return nil, fmt.Errorf("unmarshal query response payload: %w", err)
+	}
+	return &p, nil
+}
+
+// ToExtension converts a received payload back into a core extension,
+// re-deriving features from NewData when the sender omitted them.
+func (p *ExtensionPayload) ToExtension() (*core.Extension, error) {
+	if len(p.NewData) > MaxNewDataSize {
+		return nil, fmt.Errorf("delta of %d bytes exceeds limit %d", len(p.NewData), MaxNewDataSize)
+	}
+	parent, err := crypto.FromHex(p.ParentCrypto)
+	if err != nil {
+		return nil, fmt.Errorf("parent hash: %w", err)
+	}
+	child, err := crypto.FromHex(p.NewCrypto)
+	if err != nil {
+		return nil, fmt.Errorf("new hash: %w", err)
+	}
+	content := core.NewContent(p.NewData)
+	if p.Features != nil {
+		content.Features = p.Features
+	}
+	return &core.Extension{
+		Parent:     parent,
+		Child:      child,
+		Delta:      p.NewData,
+		Content:    content,
+		Publisher:  p.Publisher,
+		Timestamp:  p.Timestamp,
+		ValidFrom:  p.ValidFrom,
+		ValidUntil: p.ValidUntil,
+		Deleted:    p.Deleted,
+	}, nil
+}
@@ -0,0 +1,249 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/systemshift/tera/core"
+)
+
+func TestExtensionMessageRoundTrip(t *testing.T) {
+	root := core.NewContent([]byte("original content"))
+	ext := root.Extend([]byte("appended delta"))
+	ext.Publisher = "node-a"
+
+	msg, err := NewExtensionMessage(ext)
+	if err != nil {
+		t.Fatalf("NewExtensionMessage: %v", err)
+	}
+	wire, err := MarshalMessage(msg)
+	if err != nil {
+		t.Fatalf("MarshalMessage: %v", err)
+	}
+
+	decoded, err := UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatalf("UnmarshalMessage: %v", err)
+	}
+	payload, err := DecodeExtensionPayload(decoded)
+	if err != nil {
+		t.Fatalf("DecodeExtensionPayload: %v", err)
+	}
+
+	got, err := payload.ToExtension()
+	if err != nil {
+		t.Fatalf("ToExtension: %v", err)
+	}
+	if !got.Parent.Equal(ext.Parent) || !got.Child.Equal(ext.Child) {
+		t.Fatal("hashes changed in transit")
+	}
+	if !bytes.Equal(got.Delta, ext.Delta) {
+		t.Fatal("delta changed in transit")
+	}
+	if got.Publisher != "node-a" {
+		t.Fatalf("publisher = %q, want node-a", got.Publisher)
+	}
+	if !got.Verify() {
+		t.Fatal("round-tripped extension fails verification")
+	}
+}
+
+func TestQueryMessageRoundTrip(t *testing.T) {
+	q := core.NewQuery("machine learning", 0.4)
+	msg, err := NewQueryMessage("req-1", "peer-a", q, 0)
+	if err != nil {
+		t.Fatalf("NewQueryMessage: %v", err)
+	}
+	wire, _ := MarshalMessage(msg)
+	decoded, err := UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatalf("UnmarshalMessage: %v", err)
+	}
+	payload, err := DecodeQueryPayload(decoded)
+	if err != nil {
+		t.Fatalf("DecodeQueryPayload: %v", err)
+	}
+	if payload.RequestID != "req-1" || payload.QueryText != "machine learning" {
+		t.Fatalf("payload = %+v", payload)
+	}
+	if payload.RequesterID != "peer-a" {
+		t.Fatalf("RequesterID = %q, want peer-a", payload.RequesterID)
+	}
+	if payload.Threshold != 0.4 {
+		t.Fatalf("threshold = %f, want 0.4", payload.Threshold)
+	}
+}
+
+func TestUnmarshalRejectsWrongVersion(t *testing.T) {
+	wire := []byte(`{"version":"tera/9.0.0","type":"query","payload":{}}`)
+	if _, err := UnmarshalMessage(wire); err == nil {
+		t.Fatal("wrong version accepted")
+	}
+}
+
+func TestDecodeWrongType(t *testing.T) {
+	q := core.NewQuery("anything", 0.5)
+	msg, _ := NewQueryMessage("req-2", "peer-b", q, 0)
+	if _, err := DecodeExtensionPayload(msg); err == nil {
+		t.Fatal("query decoded as extension")
+	}
+}
+
+func TestToExtensionRejectsOversizeDelta(t *testing.T) {
+	root := core.NewContent([]byte("original content"))
+	ext := root.Extend([]byte("small delta"))
+	msg, err := NewExtensionMessage(ext)
+	if err != nil {
+		t.Fatalf("NewExtensionMessage: %v", err)
+	}
+	payload, err := DecodeExtensionPayload(msg)
+	if err != nil {
+		t.Fatalf("DecodeExtensionPayload: %v", err)
+	}
+
+	if _, err := payload.ToExtension(); err != nil {
+		t.Fatalf("small delta rejected: %v", err)
+	}
+	payload.NewData = make([]byte, MaxNewDataSize+1)
+	if _, err := payload.ToExtension(); err == nil {
+		t.Fatal("oversize delta accepted")
+	}
+}
+
+func TestUnmarshalAcceptsCompatibleMinorBump(t *testing.T) {
+	wire := []byte(`{"version":"tera/1.7.3","type":"query","payload":{}}`)
+	if _, err := UnmarshalMessage(wire); err != nil {
+		t.Fatalf("compatible minor bump rejected: %v", err)
+	}
+}
+
+func TestVersionCompatible(t *testing.T) {
+	for v, want := range map[string]bool{
+		Version:      true,
+		"tera/1.9.0": true,
+		"tera/2.0.0": false,
+		"tera/1.0":   false,
+		"v1.0.0":     false,
+		"":           false,
+	} {
+		if got := VersionCompatible(v); got != want {
+			t.Fatalf("VersionCompatible(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestTombstoneRoundTrip(t *testing.T) {
+	root := core.NewContent([]byte("content to retract"))
+	tomb := core.NewTombstone(root.Hash)
+	tomb.Publisher = "node-a"
+
+	msg, err := NewExtensionMessage(tomb)
+	if err != nil {
+		t.Fatalf("NewExtensionMessage: %v", err)
+	}
+	wire, err := MarshalMessage(msg)
+	if err != nil {
+		t.Fatalf("MarshalMessage: %v", err)
+	}
+	decoded, err := UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatalf("UnmarshalMessage: %v", err)
+	}
+	payload, err := DecodeExtensionPayload(decoded)
+	if err != nil {
+		t.Fatalf("DecodeExtensionPayload: %v", err)
+	}
+	if !payload.Deleted {
+		t.Fatal("deleted flag lost in transit")
+	}
+	got, err := payload.ToExtension()
+	if err != nil {
+		t.Fatalf("ToExtension: %v", err)
+	}
+	if !got.Deleted || !got.Verify() {
+		t.Fatal("round-tripped tombstone invalid")
+	}
+
+	// The flag is covered by the signature: flipping it changes the
+	// signing bytes.
+	signed, err := payload.SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload.Deleted = false
+	unsigned, err := payload.SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(signed, unsigned) {
+		t.Fatal("deleted flag not covered by signing bytes")
+	}
+}
+
+func TestValidityWindowRoundTripAndSigned(t *testing.T) {
+	root := core.NewContent([]byte("original content"))
+	ext := root.Extend([]byte("windowed delta"))
+	ext.ValidFrom = ext.Timestamp
+	ext.ValidUntil = ext.Timestamp + int64(time.Hour)
+
+	msg, err := NewExtensionMessage(ext)
+	if err != nil {
+		t.Fatalf("NewExtensionMessage: %v", err)
+	}
+	payload, err := DecodeExtensionPayload(msg)
+	if err != nil {
+		t.Fatalf("DecodeExtensionPayload: %v", err)
+	}
+	got, err := payload.ToExtension()
+	if err != nil {
+		t.Fatalf("ToExtension: %v", err)
+	}
+	if got.ValidFrom != ext.ValidFrom || got.ValidUntil != ext.ValidUntil {
+		t.Fatalf("window lost in transit: got [%d, %d], want [%d, %d]",
+			got.ValidFrom, got.ValidUntil, ext.ValidFrom, ext.ValidUntil)
+	}
+
+	// The window must also survive the wire codec, whose extension
+	// payloads are encoded field by field rather than as JSON.
+	wire, err := MarshalMessage(msg)
+	if err != nil {
+		t.Fatalf("MarshalMessage: %v", err)
+	}
+	decoded, err := UnmarshalMessage(wire)
+	if err != nil {
+		t.Fatalf("UnmarshalMessage: %v", err)
+	}
+	wired, err := DecodeExtensionPayload(decoded)
+	if err != nil {
+		t.Fatalf("DecodeExtensionPayload: %v", err)
+	}
+	if wired.ValidFrom != ext.ValidFrom || wired.ValidUntil != ext.ValidUntil {
+		t.Fatalf("window lost on the wire: got [%d, %d], want [%d, %d]",
+			wired.ValidFrom, wired.ValidUntil, ext.ValidFrom, ext.ValidUntil)
+	}
+
+	// The window is part of the signed bytes, so a relay cannot
+	// stretch it without invalidating the signature; a windowless
+	// payload keeps its original layout.
+	signed, err := payload.SigningBytes()
+	if err != nil {
+		t.Fatalf("SigningBytes: %v", err)
+	}
+	payload.ValidUntil += int64(time.Hour)
+	stretched, err := payload.SigningBytes()
+	if err != nil {
+		t.Fatalf("SigningBytes: %v", err)
+	}
+	if bytes.Equal(signed, stretched) {
+		t.Fatal("stretching the window left the signed bytes unchanged")
+	}
+	payload.ValidFrom, payload.ValidUntil = 0, 0
+	windowless, err := payload.SigningBytes()
+	if err != nil {
+		t.Fatalf("SigningBytes: %v", err)
+	}
+	if len(windowless) >= len(signed) {
+		t.Fatal("windowless payload did not shed the window bytes")
+	}
+}
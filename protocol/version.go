@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// supportedMajors registers the protocol majors this build can
+// process. Minor and patch bumps within a registered major are
+// compatible by contract, so rolling upgrades only break across an
+// unregistered major.
+var supportedMajors = map[int]bool{1: true}
+
+// parseVersion splits a "tera/MAJOR.MINOR.PATCH" tag into its parts.
+func parseVersion(v string) (major, minor, patch int, err error) {
+	rest, ok := strings.CutPrefix(v, "tera/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("version %q does not start with tera/", v)
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("version %q is not MAJOR.MINOR.PATCH", v)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		if nums[i], err = strconv.Atoi(part); err != nil || nums[i] < 0 {
+			return 0, 0, 0, fmt.Errorf("version %q has non-numeric component %q", v, part)
+		}
+	}
+	return nums[0], nums[1], nums[2], nil
+}
+
+// VersionCompatible reports whether a message tagged v can be
+// processed by this build: it must parse and carry a registered
+// major.
+func VersionCompatible(v string) bool {
+	major, _, _, err := parseVersion(v)
+	if err != nil {
+		return false
+	}
+	return supportedMajors[major]
+}
+
+// SupportedVersions lists the version lines this build accepts, in
+// ascending major order.
+func SupportedVersions() []string {
+	majors := make([]int, 0, len(supportedMajors))
+	for major := range supportedMajors {
+		majors = append(majors, major)
+	}
+	sort.Ints(majors)
+	lines := make([]string, len(majors))
+	for i, major := range majors {
+		lines[i] = fmt.Sprintf("tera/%d.x.y", major)
+	}
+	return lines
+}
@@ -0,0 +1,30 @@
+package semantic
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// SemanticBucket maps a feature set to locality-sensitive bucket
+// numbers in [0, numBuckets): each top keyword hashes to one bucket,
+// so content and interests that share a keyword land in at least one
+// common bucket. Nodes can use the buckets as pubsub sub-shards and
+// subscribe only where their interests live. Content with no keywords
+// maps to no buckets.
+func SemanticBucket(f *Features, numBuckets int) []int {
+	if numBuckets <= 0 {
+		return nil
+	}
+	seen := make(map[int]bool)
+	for _, keyword := range f.TopKeywords {
+		h := fnv.New64a()
+		h.Write([]byte(keyword))
+		seen[int(h.Sum64()%uint64(numBuckets))] = true
+	}
+	buckets := make([]int, 0, len(seen))
+	for b := range seen {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+	return buckets
+}
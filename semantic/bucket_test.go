@@ -0,0 +1,49 @@
+package semantic
+
+import "testing"
+
+func overlap(a, b []int) bool {
+	set := make(map[int]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+	for _, v := range b {
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSemanticBucketOverlap(t *testing.T) {
+	const numBuckets = 64
+	interest := SemanticBucket(ExtractFeatures([]byte("machine learning and neural networks")), numBuckets)
+	mlContent := SemanticBucket(ExtractFeatures([]byte("training neural networks is core to machine learning")), numBuckets)
+	cooking := SemanticBucket(ExtractFeatures([]byte("sourdough starter fermentation schedule")), numBuckets)
+
+	if !overlap(interest, mlContent) {
+		t.Fatalf("ML interest %v and ML content %v share no bucket", interest, mlContent)
+	}
+	if overlap(interest, cooking) {
+		t.Fatalf("ML interest %v and cooking content %v collide", interest, cooking)
+	}
+}
+
+func TestSemanticBucketEdgeCases(t *testing.T) {
+	if got := SemanticBucket(ExtractFeatures([]byte("anything")), 0); got != nil {
+		t.Fatalf("numBuckets=0 returned %v, want nil", got)
+	}
+	if got := SemanticBucket(ExtractFeatures(nil), 16); len(got) != 0 {
+		t.Fatalf("empty content mapped to buckets %v", got)
+	}
+	a := SemanticBucket(ExtractFeatures([]byte("stable deterministic hashing")), 16)
+	b := SemanticBucket(ExtractFeatures([]byte("stable deterministic hashing")), 16)
+	if len(a) != len(b) {
+		t.Fatal("bucket assignment not deterministic")
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("bucket assignment not deterministic")
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+)
+
+// Embedder produces a dense vector for content, typically backed by a
+// real embedding model outside this package. Vectors from the same
+// embedder are comparable; mixing embedders is the caller's mistake to
+// avoid, just like mixing n-gram widths.
+type Embedder interface {
+	Embed(content []byte) ([]float32, error)
+}
+
+// ExtractFeaturesEmbedded is ExtractFeatures plus a dense embedding of
+// the content from e, for kernels weighting the embedding component.
+func ExtractFeaturesEmbedded(data []byte, e Embedder) (*Features, error) {
+	vec, err := e.Embed(data)
+	if err != nil {
+		return nil, fmt.Errorf("embed content: %w", err)
+	}
+	f := ExtractFeatures(data)
+	f.Embedding = vec
+	return f, nil
+}
+
+// DenseCosine is the cosine of two dense vectors. Mismatched or
+// missing vectors score 0.
+func DenseCosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
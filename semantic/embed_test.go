@@ -0,0 +1,70 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// mockEmbedder maps content verbatim to preset vectors.
+type mockEmbedder struct {
+	vectors map[string][]float32
+}
+
+func (m *mockEmbedder) Embed(content []byte) ([]float32, error) {
+	vec, ok := m.vectors[string(content)]
+	if !ok {
+		return nil, fmt.Errorf("no vector for %q", content)
+	}
+	return vec, nil
+}
+
+func TestEmbeddingSimilarityTracksCosine(t *testing.T) {
+	emb := &mockEmbedder{vectors: map[string][]float32{
+		"query":      {1, 0},
+		"aligned":    {2, 0},
+		"orthogonal": {0, 1},
+		"angled":     {0.6, 0.8},
+	}}
+	extract := func(text string) *Features {
+		f, err := ExtractFeaturesEmbedded([]byte(text), emb)
+		if err != nil {
+			t.Fatalf("ExtractFeaturesEmbedded(%q): %v", text, err)
+		}
+		return f
+	}
+
+	// All weight on the embedding component: similarity is exactly the
+	// vector cosine.
+	params := KernelParams{EmbeddingWeight: 1}
+	if err := params.Validate(); err != nil {
+		t.Fatalf("embedding-only params invalid: %v", err)
+	}
+	query := extract("query")
+	for text, want := range map[string]float64{
+		"aligned":    1.0,
+		"orthogonal": 0.0,
+		"angled":     0.6,
+	} {
+		got := Similarity(query, extract(text), params)
+		// float32 vector components carry ~1e-7 of representation error.
+		if math.Abs(got-want) > 1e-6 {
+			t.Fatalf("similarity to %q = %f, want cosine %f", text, got, want)
+		}
+	}
+
+	// Features without embeddings fall back to TF-IDF under the same
+	// params instead of scoring zero.
+	mixed := KernelParams{SemanticWeight: 0.2, EmbeddingWeight: 0.4, LexicalWeight: 0.3, StructuralWeight: 0.1}
+	plainA := ExtractFeatures([]byte("machine learning models in production"))
+	plainB := ExtractFeatures([]byte("machine learning models"))
+	fallback := Similarity(plainA, plainB, mixed)
+	folded := Similarity(plainA, plainB, KernelParams{SemanticWeight: 0.6, LexicalWeight: 0.3, StructuralWeight: 0.1})
+	if math.Abs(fallback-folded) > 1e-9 {
+		t.Fatalf("fallback similarity %f differs from folded weights %f", fallback, folded)
+	}
+
+	if err := (KernelParams{EmbeddingWeight: -0.5}).Validate(); err == nil {
+		t.Fatal("Validate accepted a negative embedding weight")
+	}
+}
@@ -0,0 +1,616 @@
+// Package semantic implements the feature extraction and similarity
+// kernel used for interest matching. Where the crypto package answers
+// "is this extension authentic", this package answers "is it relevant":
+// content is reduced to a bag-of-words TF-IDF vector, a character
+// n-gram set and a few structural measurements, and the kernel combines
+// per-component similarities into a single score in [0,1].
+package semantic
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ngramSize is the character n-gram width used for the lexical
+// component.
+const ngramSize = 3
+
+// topKeywordCount bounds the TopKeywords slice on Features.
+const topKeywordCount = 10
+
+// Features is the semantic fingerprint of a piece of content.
+type Features struct {
+	// TFIDF maps each token to its term frequency weighted by inverse
+	// document frequency (plain TF when no IDF corpus is available).
+	TFIDF map[string]float64 `json:"tfidf"`
+	// Ngrams records which character n-grams occur in the content.
+	Ngrams map[string]bool `json:"ngrams"`
+	// NgramCounts records how often each n-gram occurs, for the
+	// weighted lexical metric; Ngrams keeps the presence set the
+	// default Jaccard metric compares.
+	NgramCounts map[string]int `json:"ngram_counts"`
+	// TopKeywords are the highest-weighted terms, for display and
+	// cheap keyword-level matching.
+	TopKeywords []string `json:"top_keywords"`
+	// CharCount and WordCount feed the structural component.
+	CharCount int `json:"char_count"`
+	WordCount int `json:"word_count"`
+	// UniqueWords is the vocabulary size of the content.
+	UniqueWords int `json:"unique_words"`
+	// CharFreq counts each character of the text. It exists so Entropy
+	// is recomputable after ExtendWith merges in a delta's characters;
+	// nil for binary content.
+	CharFreq map[string]int `json:"char_freq"`
+	// Entropy is the Shannon entropy in bits of the character
+	// distribution. Natural prose sits around 4 bits; uniformly random
+	// characters score higher, which the structural component uses to
+	// tell the two apart. Zero when no distribution was recorded.
+	Entropy float64 `json:"entropy,omitempty"`
+	// AvgWordLen is the mean token length in runes. Zero when there are
+	// no tokens.
+	AvgWordLen float64 `json:"avg_word_len,omitempty"`
+	// MinHash caches a signature from MinHashSignature so repeated
+	// prefilter comparisons skip recomputation. Nil until computed.
+	MinHash []uint64 `json:"minhash,omitempty"`
+	// NgramSize records the n-gram width Ngrams was built with, so
+	// comparisons can detect features extracted at different widths.
+	// Zero on features persisted before the field existed.
+	NgramSize int `json:"ngram_size,omitempty"`
+	// Language is the detected language code from DetectLanguage, or
+	// empty when detection was not confident enough to commit.
+	Language string `json:"language,omitempty"`
+	// Tail preserves the end of the gram-normalized text — one rune
+	// short of an n-gram window, or all of it when the content is
+	// shorter than a window — so ExtendWith can bridge n-grams across
+	// an append boundary.
+	Tail string `json:"tail,omitempty"`
+	// IsBinary marks content that looks like raw bytes rather than
+	// text; tokens and n-grams are skipped and ByteHistogram carries
+	// the fingerprint instead.
+	IsBinary bool `json:"is_binary,omitempty"`
+	// ByteHistogram is the normalized byte-value distribution of
+	// binary content, the semantic stand-in for a TF-IDF vector. Nil
+	// for text.
+	ByteHistogram []float64 `json:"byte_histogram,omitempty"`
+	// Embedding is an optional dense vector from an Embedder, scored
+	// by the kernel's embedding component when both sides carry one.
+	// Nil unless extracted through ExtractFeaturesEmbedded.
+	Embedding []float32 `json:"embedding,omitempty"`
+	// norm caches the Euclidean magnitude of TFIDF so repeated cosine
+	// comparisons skip the per-call sum of squares. Zero until computed;
+	// access through Norm.
+	norm float64
+}
+
+// Norm returns the Euclidean magnitude of the TF-IDF vector, computing
+// it on first use and caching it. Lazy rather than eager so features
+// stay comparable with reflect.DeepEqual across a JSON round trip.
+func (f *Features) Norm() float64 {
+	if f.norm == 0 && len(f.TFIDF) > 0 {
+		var sum float64
+		for _, w := range f.TFIDF {
+			sum += w * w
+		}
+		f.norm = math.Sqrt(sum)
+	}
+	return f.norm
+}
+
+// WithTFIDF returns a copy of the features carrying a replacement
+// term-weight vector, with the cached norm invalidated. Everything
+// else — n-grams, structural measurements — is shared with the
+// original, for callers like query refinement that reweight terms
+// without changing the underlying text.
+func (f *Features) WithTFIDF(tf map[string]float64) *Features {
+	nf := *f
+	nf.TFIDF = tf
+	nf.norm = 0
+	return &nf
+}
+
+// MarshalJSON serializes the features with map keys in sorted order,
+// guaranteed by construction rather than by encoder behavior: equal
+// Features always produce byte-identical JSON, which signing payload
+// bytes depends on.
+func (f *Features) MarshalJSON() ([]byte, error) {
+	tfidf, err := sortedMapJSON(f.TFIDF)
+	if err != nil {
+		return nil, err
+	}
+	ngrams, err := sortedMapJSON(f.Ngrams)
+	if err != nil {
+		return nil, err
+	}
+	ngramCounts, err := sortedMapJSON(f.NgramCounts)
+	if err != nil {
+		return nil, err
+	}
+	charFreq, err := sortedMapJSON(f.CharFreq)
+	if err != nil {
+		return nil, err
+	}
+	type alias Features // shed the method to avoid recursion
+	return json.Marshal(&struct {
+		TFIDF       json.RawMessage `json:"tfidf"`
+		Ngrams      json.RawMessage `json:"ngrams"`
+		NgramCounts json.RawMessage `json:"ngram_counts"`
+		CharFreq    json.RawMessage `json:"char_freq"`
+		*alias
+	}{tfidf, ngrams, ngramCounts, charFreq, (*alias)(f)})
+}
+
+// sortedMapJSON encodes a map as a JSON object with keys in sorted
+// order. Nil maps encode as null, matching encoding/json.
+func sortedMapJSON[V any](m map[string]V) (json.RawMessage, error) {
+	if m == nil {
+		return json.RawMessage("null"), nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(m[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Tokenize normalizes text to NFC (folding diacritics when
+// FoldDiacritics is set), lowercases it, and splits it into runs of
+// letters and digits; everything else is a separator.
+func Tokenize(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	for _, r := range strings.ToLower(normalizeText(text, FoldDiacritics)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			current.WriteRune(r)
+		} else if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// GenerateNgrams returns the set of character n-grams of width n over
+// the lowercased text with whitespace collapsed.
+func GenerateNgrams(text string, n int) map[string]bool {
+	return ngramSet([]rune(normalizeGramText(text)), n)
+}
+
+// GenerateNgramCounts is GenerateNgrams keeping occurrence counts
+// rather than presence.
+func GenerateNgramCounts(text string, n int) map[string]int {
+	return ngramCounts([]rune(normalizeGramText(text)), n)
+}
+
+// normalizeGramText lowercases text and collapses whitespace runs to
+// single spaces, the canonical form n-grams are generated over.
+func normalizeGramText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// ngramSet slides a width-n window over already-normalized runes.
+// Content shorter than one window contributes itself as a single gram.
+func ngramSet(runes []rune, n int) map[string]bool {
+	grams := make(map[string]bool)
+	if len(runes) < n {
+		if len(runes) > 0 {
+			grams[string(runes)] = true
+		}
+		return grams
+	}
+	for i := 0; i+n <= len(runes); i++ {
+		grams[string(runes[i:i+n])] = true
+	}
+	return grams
+}
+
+// ngramCounts is ngramSet keeping occurrence counts per gram.
+func ngramCounts(runes []rune, n int) map[string]int {
+	counts := make(map[string]int)
+	if len(runes) < n {
+		if len(runes) > 0 {
+			counts[string(runes)] = 1
+		}
+		return counts
+	}
+	for i := 0; i+n <= len(runes); i++ {
+		counts[string(runes[i:i+n])]++
+	}
+	return counts
+}
+
+// IDF accumulates document frequencies over a corpus so term weights
+// can discount ubiquitous words.
+type IDF struct {
+	docFreq   map[string]int
+	totalDocs int
+}
+
+// NewIDF returns an empty corpus.
+func NewIDF() *IDF {
+	return &IDF{docFreq: make(map[string]int)}
+}
+
+// Add records one document's tokens. Each distinct token counts once
+// per document.
+func (idf *IDF) Add(tokens []string) {
+	idf.totalDocs++
+	seen := make(map[string]bool, len(tokens))
+	for _, tok := range tokens {
+		if !seen[tok] {
+			seen[tok] = true
+			idf.docFreq[tok]++
+		}
+	}
+}
+
+// TotalDocs returns how many documents have been added to the corpus,
+// so callers no longer have to track the count themselves.
+func (idf *IDF) TotalDocs() int {
+	return idf.totalDocs
+}
+
+// Get returns the smoothed inverse document frequency of term given
+// the total number of documents in the corpus.
+func (idf *IDF) Get(term string, totalDocs int) float64 {
+	df := idf.docFreq[term]
+	if totalDocs <= 0 {
+		return 1.0
+	}
+	return math.Log(float64(totalDocs+1)/float64(df+1)) + 1.0
+}
+
+// FeatureConfig tunes feature extraction. The zero value is not
+// useful; start from DefaultFeatureConfig.
+type FeatureConfig struct {
+	// NgramSize is the character n-gram width for the lexical
+	// component.
+	NgramSize int
+	// Stemming collapses morphological variants to their Porter stem
+	// before TF computation. Off by default: stemmed features are not
+	// comparable with unstemmed ones, so flipping it on an existing
+	// corpus means re-extracting.
+	Stemming bool
+	// TopKeywords bounds the TopKeywords slice on Features.
+	TopKeywords int
+	// Tokenizer tunes token splitting; the zero value keeps the
+	// default Tokenize behavior. As with Stemming, features built
+	// under different tokenizer rules are not comparable.
+	Tokenizer TokenizerConfig
+}
+
+// DefaultFeatureConfig matches what ExtractFeatures has always done.
+func DefaultFeatureConfig() FeatureConfig {
+	return FeatureConfig{NgramSize: ngramSize, TopKeywords: topKeywordCount}
+}
+
+// ExtractFeatures computes the semantic fingerprint of content. The
+// TFIDF map holds normalized term frequencies; callers with a trained
+// IDF corpus can reweight afterwards.
+func ExtractFeatures(data []byte) *Features {
+	return ExtractFeaturesWith(data, DefaultFeatureConfig())
+}
+
+// ExtractFeaturesN is ExtractFeatures with an explicit n-gram width.
+// Features extracted at different widths are not comparable; use
+// SimilarityChecked when mixing sources.
+func ExtractFeaturesN(data []byte, n int) *Features {
+	cfg := DefaultFeatureConfig()
+	cfg.NgramSize = n
+	return ExtractFeaturesWith(data, cfg)
+}
+
+// binaryControlRatio is the fraction of control bytes above which
+// content is treated as binary even when it happens to decode as
+// UTF-8.
+const binaryControlRatio = 0.3
+
+// IsBinaryContent reports whether data looks like raw bytes rather
+// than text: invalid UTF-8, a NUL byte, or a high ratio of control
+// characters. Empty content counts as text.
+func IsBinaryContent(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			control++
+		}
+	}
+	return float64(control)/float64(len(data)) > binaryControlRatio
+}
+
+// byteHistogram returns the normalized byte-value distribution of
+// data.
+func byteHistogram(data []byte) []float64 {
+	hist := make([]float64, 256)
+	for _, b := range data {
+		hist[b]++
+	}
+	for i := range hist {
+		hist[i] /= float64(len(data))
+	}
+	return hist
+}
+
+// charFrequencies counts each rune of text, keyed by its string form
+// for JSON friendliness.
+func charFrequencies(text string) map[string]int {
+	freq := make(map[string]int)
+	for _, r := range text {
+		freq[string(r)]++
+	}
+	return freq
+}
+
+// charEntropy returns the Shannon entropy in bits of a character
+// frequency map. Counts are summed in sorted key order so equal
+// distributions produce bit-identical floats regardless of map
+// iteration order, which ExtendWith's exactness guarantee relies on.
+func charEntropy(freq map[string]int) float64 {
+	total := 0
+	keys := make([]string, 0, len(freq))
+	for k, c := range freq {
+		keys = append(keys, k)
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Strings(keys)
+	var sum float64
+	for _, k := range keys {
+		c := float64(freq[k])
+		sum += c * math.Log2(c)
+	}
+	return math.Log2(float64(total)) - sum/float64(total)
+}
+
+// avgWordLen returns the mean token length in runes.
+func avgWordLen(tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+	total := 0
+	for _, tok := range tokens {
+		total += len([]rune(tok))
+	}
+	return float64(total) / float64(len(tokens))
+}
+
+// ExtractFeaturesWith is ExtractFeatures under an explicit config.
+// Binary content skips tokenization entirely: the fingerprint is the
+// byte-value histogram, which the kernel compares by cosine instead of
+// token TF-IDF.
+func ExtractFeaturesWith(data []byte, cfg FeatureConfig) *Features {
+	if IsBinaryContent(data) {
+		return &Features{
+			IsBinary:      true,
+			ByteHistogram: byteHistogram(data),
+			CharCount:     len(data),
+			NgramSize:     cfg.NgramSize,
+		}
+	}
+	// Normalize once up front so the n-gram and character paths see
+	// the same NFC form the tokenizer does; otherwise composed and
+	// decomposed spellings diverge everywhere but the TF vector.
+	text := normalizeText(string(data), FoldDiacritics)
+	tokens := TokenizeWith(text, cfg.Tokenizer)
+	if cfg.Stemming {
+		for i, tok := range tokens {
+			tokens[i] = Stem(tok)
+		}
+	}
+
+	tf := make(map[string]float64)
+	for _, tok := range tokens {
+		tf[tok]++
+	}
+	if len(tokens) > 0 {
+		for tok := range tf {
+			tf[tok] /= float64(len(tokens))
+		}
+	}
+
+	normRunes := []rune(normalizeGramText(text))
+	freq := charFrequencies(text)
+	f := &Features{
+		TFIDF:       tf,
+		Ngrams:      ngramSet(normRunes, cfg.NgramSize),
+		NgramCounts: ngramCounts(normRunes, cfg.NgramSize),
+		TopKeywords: getTopKeywords(tf, cfg.TopKeywords),
+		CharCount:   len(text),
+		WordCount:   len(tokens),
+		UniqueWords: len(tf),
+		CharFreq:    freq,
+		Entropy:     charEntropy(freq),
+		AvgWordLen:  avgWordLen(tokens),
+		NgramSize:   cfg.NgramSize,
+		Tail:        gramTail(normRunes, cfg.NgramSize),
+	}
+	if lang, conf := DetectLanguage(data); conf >= minLanguageConfidence {
+		f.Language = lang
+	}
+	return f
+}
+
+// gramTail returns the trailing n-gram window of normalized runes: the
+// last n-1 runes, or everything when the content is shorter than that.
+func gramTail(runes []rune, n int) string {
+	if len(runes) > n-1 {
+		runes = runes[len(runes)-(n-1):]
+	}
+	return string(runes)
+}
+
+// ExtendWith returns the features of this content with delta appended,
+// computed incrementally from the fingerprint rather than from the full
+// text: raw term counts are rebuilt from the TF vector, the delta's
+// tokens are folded in, and n-grams spanning the append boundary are
+// bridged through the preserved Tail — the semantic counterpart of the
+// O(1) crypto extension. The join is treated as a token boundary, so
+// when the delta begins with a separator — whitespace or punctuation,
+// the usual case when appending text — the result is identical to
+// ExtractFeatures on the concatenation; a delta starting mid-word
+// re-splits the word. Extraction follows the default config; a cached
+// MinHash is not carried over.
+func (f *Features) ExtendWith(delta []byte) *Features {
+	n := f.NgramSize
+	if n == 0 {
+		n = ngramSize
+	}
+	// The delta is normalized on its own, matching what full extraction
+	// does to the concatenation except for a delta that begins with a
+	// combining mark, which would compose across the boundary.
+	text := normalizeText(string(delta), FoldDiacritics)
+
+	// The normalized TF vector is count/WordCount, so the raw counts
+	// round back exactly.
+	tf := make(map[string]float64, len(f.TFIDF))
+	for tok, w := range f.TFIDF {
+		tf[tok] = math.Round(w * float64(f.WordCount))
+	}
+	tokens := Tokenize(text)
+	for _, tok := range tokens {
+		tf[tok]++
+	}
+	total := f.WordCount + len(tokens)
+	if total > 0 {
+		for tok := range tf {
+			tf[tok] /= float64(total)
+		}
+	}
+
+	// AvgWordLen is a mean of integer rune counts, so the parent's
+	// total recovers exactly; the character frequencies merge directly.
+	tokenLen := int(math.Round(f.AvgWordLen * float64(f.WordCount)))
+	for _, tok := range tokens {
+		tokenLen += len([]rune(tok))
+	}
+	freq := make(map[string]int, len(f.CharFreq))
+	for r, c := range f.CharFreq {
+		freq[r] = c
+	}
+	for _, r := range text {
+		freq[string(r)]++
+	}
+
+	grams := make(map[string]bool, len(f.Ngrams))
+	for g := range f.Ngrams {
+		grams[g] = true
+	}
+	counts := make(map[string]int, len(f.NgramCounts))
+	for g, c := range f.NgramCounts {
+		counts[g] = c
+	}
+	tail := f.Tail
+	if deltaNorm := normalizeGramText(text); deltaNorm != "" {
+		// A gram shorter than the window can only be the whole parent
+		// text; the extension supersedes it.
+		if grams[tail] && len([]rune(tail)) < n {
+			delete(grams, tail)
+			delete(counts, tail)
+		}
+		bridge := deltaNorm
+		if tail != "" {
+			// Whitespace at the join collapses to a single space;
+			// anything else attaches directly to the parent's last rune,
+			// matching GenerateNgrams over the concatenation.
+			sep := ""
+			if r, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(r) {
+				sep = " "
+			}
+			bridge = tail + sep + deltaNorm
+		}
+		runes := []rune(bridge)
+		for g := range ngramSet(runes, n) {
+			grams[g] = true
+		}
+		// Every window over the bridge includes at least one delta rune —
+		// the tail is only n-1 runes — so its occurrences are all new and
+		// add onto the parent's counts without double counting.
+		for g, c := range ngramCounts(runes, n) {
+			counts[g] += c
+		}
+		tail = gramTail(runes, n)
+	}
+
+	avg := 0.0
+	if total > 0 {
+		avg = float64(tokenLen) / float64(total)
+	}
+	nf := &Features{
+		TFIDF:       tf,
+		Ngrams:      grams,
+		NgramCounts: counts,
+		TopKeywords: getTopKeywords(tf, topKeywordCount),
+		CharCount:   f.CharCount + len(text),
+		WordCount:   total,
+		UniqueWords: len(tf),
+		CharFreq:    freq,
+		Entropy:     charEntropy(freq),
+		AvgWordLen:  avg,
+		NgramSize:   n,
+		Tail:        tail,
+	}
+	if n == ngramSize {
+		if lang, conf := detectLanguageGrams(grams); conf >= minLanguageConfidence {
+			nf.Language = lang
+		}
+	} else {
+		// Language profiles are trigram-based; at other widths keep the
+		// parent's verdict rather than guessing.
+		nf.Language = f.Language
+	}
+	return nf
+}
+
+// getTopKeywords returns the count highest-weighted terms, breaking
+// ties alphabetically so output is deterministic.
+func getTopKeywords(tf map[string]float64, count int) []string {
+	terms := make([]string, 0, len(tf))
+	for term := range tf {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if tf[terms[i]] != tf[terms[j]] {
+			return tf[terms[i]] > tf[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > count {
+		terms = terms[:count]
+	}
+	return terms
+}
@@ -0,0 +1,194 @@
+package semantic
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Hello, World! 123 foo-bar")
+	want := []string{"hello", "world", "123", "foo", "bar"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokenize = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateNgrams(t *testing.T) {
+	grams := GenerateNgrams("abcd", 3)
+	for _, g := range []string{"abc", "bcd"} {
+		if !grams[g] {
+			t.Fatalf("missing ngram %q", g)
+		}
+	}
+	if len(grams) != 2 {
+		t.Fatalf("got %d ngrams, want 2", len(grams))
+	}
+
+	short := GenerateNgrams("ab", 3)
+	if !short["ab"] {
+		t.Fatal("short input should yield the whole string")
+	}
+}
+
+func TestExtractFeatures(t *testing.T) {
+	f := ExtractFeatures([]byte("the quick brown fox jumps over the lazy dog"))
+	if f.WordCount != 9 {
+		t.Fatalf("WordCount = %d, want 9", f.WordCount)
+	}
+	if f.UniqueWords != 8 {
+		t.Fatalf("UniqueWords = %d, want 8", f.UniqueWords)
+	}
+	// "the" appears twice out of nine tokens.
+	if got := f.TFIDF["the"]; got != 2.0/9.0 {
+		t.Fatalf("TFIDF[the] = %f, want %f", got, 2.0/9.0)
+	}
+	if len(f.TopKeywords) == 0 || f.TopKeywords[0] != "the" {
+		t.Fatalf("TopKeywords = %v, want leading \"the\"", f.TopKeywords)
+	}
+}
+
+func TestExtractFeaturesEmpty(t *testing.T) {
+	f := ExtractFeatures(nil)
+	if f.WordCount != 0 || len(f.TFIDF) != 0 || len(f.Ngrams) != 0 {
+		t.Fatalf("empty content produced non-empty features: %+v", f)
+	}
+}
+
+func TestIDF(t *testing.T) {
+	idf := NewIDF()
+	idf.Add([]string{"machine", "learning"})
+	idf.Add([]string{"machine", "vision"})
+	idf.Add([]string{"cooking"})
+
+	common := idf.Get("machine", 3)
+	rare := idf.Get("cooking", 3)
+	if common >= rare {
+		t.Fatalf("common term IDF %f should be below rare term IDF %f", common, rare)
+	}
+}
+
+func TestTopKeywordCountConfigurable(t *testing.T) {
+	data := []byte("one two three four five six seven eight nine ten eleven twelve")
+
+	if got := len(ExtractFeatures(data).TopKeywords); got != topKeywordCount {
+		t.Fatalf("default TopKeywords length = %d, want %d", got, topKeywordCount)
+	}
+
+	cfg := DefaultFeatureConfig()
+	cfg.TopKeywords = 12
+	if got := len(ExtractFeaturesWith(data, cfg).TopKeywords); got != 12 {
+		t.Fatalf("TopKeywords length = %d, want 12", got)
+	}
+}
+
+func TestFeaturesJSONDeterministic(t *testing.T) {
+	data := []byte("deterministic serialization of the same features every single time")
+	first, err := json.Marshal(ExtractFeatures(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		// Fresh extraction each round so map iteration order, had it
+		// leaked into the encoding, would differ.
+		next, err := json.Marshal(ExtractFeatures(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(next, first) {
+			t.Fatalf("marshal %d produced different bytes", i)
+		}
+	}
+
+	var decoded Features
+	if err := json.Unmarshal(first, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, ExtractFeatures(data)) {
+		t.Fatal("deterministic encoding does not round-trip")
+	}
+}
+
+func TestExtendWithMatchesFullExtraction(t *testing.T) {
+	content := []byte("the quick brown fox jumps over the lazy dog")
+	f := ExtractFeatures(content)
+	deltas := [][]byte{
+		[]byte(" and runs away"),
+		[]byte(". A second sentence arrives some time later"),
+		[]byte("\nmachine learning models learn from training data"),
+	}
+	for _, delta := range deltas {
+		f = f.ExtendWith(delta)
+		content = append(content, delta...)
+		if want := ExtractFeatures(content); !reflect.DeepEqual(f, want) {
+			t.Fatalf("ExtendWith(%q) diverged from full extraction:\n got %+v\nwant %+v",
+				delta, f, want)
+		}
+	}
+}
+
+func TestExtendWithShortParent(t *testing.T) {
+	// A parent shorter than one n-gram window records itself as a
+	// single short gram, which the extension must supersede.
+	got := ExtractFeatures([]byte("hi")).ExtendWith([]byte(" there, general kenobi"))
+	want := ExtractFeatures([]byte("hi there, general kenobi"))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtendWith on short parent:\n got %+v\nwant %+v", got, want)
+	}
+	if got.Ngrams["hi"] {
+		t.Fatal("short parent gram survived the extension")
+	}
+}
+
+func TestExtendWithEmptyEnds(t *testing.T) {
+	got := ExtractFeatures(nil).ExtendWith([]byte("from nothing"))
+	if want := ExtractFeatures([]byte("from nothing")); !reflect.DeepEqual(got, want) {
+		t.Fatalf("extending empty parent:\n got %+v\nwant %+v", got, want)
+	}
+	got = ExtractFeatures([]byte("already here")).ExtendWith(nil)
+	if want := ExtractFeatures([]byte("already here")); !reflect.DeepEqual(got, want) {
+		t.Fatalf("extending with empty delta:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestBinaryContentFeatures(t *testing.T) {
+	similarA := make([]byte, 512)
+	similarB := make([]byte, 512)
+	dissimilar := make([]byte, 512)
+	for i := range similarA {
+		similarA[i] = byte(i % 7 * 36) // cycles through 0x00 — binary
+		similarB[i] = byte((i + 1) % 7 * 36)
+		dissimilar[i] = byte(200 + i%50)
+	}
+
+	fa := ExtractFeatures(similarA)
+	if !fa.IsBinary {
+		t.Fatal("NUL-bearing content not detected as binary")
+	}
+	if len(fa.TFIDF) != 0 || len(fa.Ngrams) != 0 {
+		t.Fatalf("binary content grew token features: %d terms, %d grams",
+			len(fa.TFIDF), len(fa.Ngrams))
+	}
+
+	params := DefaultKernelParams()
+	fb := ExtractFeatures(similarB)
+	fd := ExtractFeatures(append([]byte{0}, dissimilar...))
+	if got := Similarity(fa, fb, params); got < 0.7 {
+		t.Fatalf("similar binary blobs scored %f, want >= 0.7", got)
+	}
+	if got := Similarity(fa, fd, params); got > 0.2 {
+		t.Fatalf("dissimilar binary blobs scored %f, want <= 0.2", got)
+	}
+
+	text := ExtractFeatures([]byte("machine learning models in production"))
+	if text.IsBinary {
+		t.Fatal("plain text detected as binary")
+	}
+	if got := Similarity(fa, text, params); got != 0 {
+		t.Fatalf("binary scored %f against text, want 0", got)
+	}
+	if got := Similarity(text, ExtractFeatures([]byte("machine learning models")), params); got <= 0.3 {
+		t.Fatalf("text scoring degraded: %f", got)
+	}
+}
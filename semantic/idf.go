@@ -0,0 +1,93 @@
+package semantic
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// idfFormatVersion guards the Save/Load encoding so a future layout
+// change can be detected instead of silently misread.
+const idfFormatVersion = 1
+
+// Save writes the corpus to w in a compact binary form: the format
+// version, the document count, and each term with its document
+// frequency. Terms are written in sorted order so the output is
+// deterministic.
+func (idf *IDF) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var buf [binary.MaxVarintLen64]byte
+	writeUvarint := func(v uint64) error {
+		n := binary.PutUvarint(buf[:], v)
+		_, err := bw.Write(buf[:n])
+		return err
+	}
+
+	if err := writeUvarint(idfFormatVersion); err != nil {
+		return err
+	}
+	if err := writeUvarint(uint64(idf.totalDocs)); err != nil {
+		return err
+	}
+	if err := writeUvarint(uint64(len(idf.docFreq))); err != nil {
+		return err
+	}
+	terms := make([]string, 0, len(idf.docFreq))
+	for term := range idf.docFreq {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	for _, term := range terms {
+		if err := writeUvarint(uint64(len(term))); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(term); err != nil {
+			return err
+		}
+		if err := writeUvarint(uint64(idf.docFreq[term])); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// LoadIDF reads a corpus previously written by Save.
+func LoadIDF(r io.Reader) (*IDF, error) {
+	br := bufio.NewReader(r)
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("read idf version: %w", err)
+	}
+	if version != idfFormatVersion {
+		return nil, fmt.Errorf("unsupported idf format version %d", version)
+	}
+	totalDocs, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("read document count: %w", err)
+	}
+	numTerms, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("read term count: %w", err)
+	}
+
+	idf := NewIDF()
+	idf.totalDocs = int(totalDocs)
+	for i := uint64(0); i < numTerms; i++ {
+		termLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("read term %d: %w", i, err)
+		}
+		term := make([]byte, termLen)
+		if _, err := io.ReadFull(br, term); err != nil {
+			return nil, fmt.Errorf("read term %d: %w", i, err)
+		}
+		df, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("read frequency of term %d: %w", i, err)
+		}
+		idf.docFreq[string(term)] = int(df)
+	}
+	return idf, nil
+}
@@ -0,0 +1,50 @@
+package semantic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIDFSaveLoadRoundTrip(t *testing.T) {
+	idf := NewIDF()
+	docs := []string{
+		"the quick brown fox",
+		"the lazy dog sleeps",
+		"distributed hash tables route the queries",
+	}
+	for _, doc := range docs {
+		idf.Add(Tokenize(doc))
+	}
+	if idf.TotalDocs() != len(docs) {
+		t.Fatalf("TotalDocs = %d, want %d", idf.TotalDocs(), len(docs))
+	}
+
+	var buf bytes.Buffer
+	if err := idf.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadIDF(&buf)
+	if err != nil {
+		t.Fatalf("LoadIDF: %v", err)
+	}
+	if loaded.TotalDocs() != idf.TotalDocs() {
+		t.Fatalf("loaded TotalDocs = %d, want %d", loaded.TotalDocs(), idf.TotalDocs())
+	}
+	for _, term := range []string{"the", "fox", "queries", "neverseen"} {
+		before := idf.Get(term, idf.TotalDocs())
+		after := loaded.Get(term, loaded.TotalDocs())
+		if before != after {
+			t.Fatalf("Get(%q) = %f after load, want %f", term, after, before)
+		}
+	}
+}
+
+func TestLoadIDFRejectsBadVersion(t *testing.T) {
+	if _, err := LoadIDF(strings.NewReader("\xff\x01")); err == nil {
+		t.Fatal("bogus version accepted")
+	}
+	if _, err := LoadIDF(strings.NewReader("")); err == nil {
+		t.Fatal("empty input accepted")
+	}
+}
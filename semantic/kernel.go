@@ -0,0 +1,644 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Metric selects how the semantic (TF-IDF) component of the kernel is
+// computed.
+type Metric string
+
+const (
+	// MetricCosine is the default normalized dot product.
+	MetricCosine Metric = ""
+	// MetricDot is the raw, unnormalized dot product.
+	MetricDot Metric = "dot"
+	// MetricEuclidean maps Euclidean distance into (0,1] via
+	// 1/(1+distance).
+	MetricEuclidean Metric = "euclidean"
+)
+
+// EmptyPolicy controls how the kernel scores two content-free
+// documents against each other.
+type EmptyPolicy string
+
+const (
+	// EmptyScoresZero, the default, treats two empty documents as
+	// dissimilar so content-free extensions cannot ride a
+	// lexical-heavy gate to a perfect score.
+	EmptyScoresZero EmptyPolicy = ""
+	// EmptyScoresMatch treats two empty documents as identical, the
+	// set-theoretic reading of empty n-gram sets.
+	EmptyScoresMatch EmptyPolicy = "match"
+)
+
+// LexicalMetric selects how the lexical (n-gram) component of the
+// kernel is computed.
+type LexicalMetric string
+
+const (
+	// LexicalJaccard, the default, is set overlap over the n-gram
+	// presence sets.
+	LexicalJaccard LexicalMetric = ""
+	// LexicalWeighted is cosine over n-gram occurrence counts, so a
+	// phrase both documents repeat counts for more than one they each
+	// mention once.
+	LexicalWeighted LexicalMetric = "weighted"
+)
+
+// Transform selects an optional shaping applied to the combined score.
+type Transform string
+
+const (
+	// TransformNone, the default, keeps the weighted combination
+	// linear.
+	TransformNone Transform = ""
+	// TransformSigmoid pushes the combined score through a logistic
+	// curve, spreading the narrow mid-range band real documents
+	// cluster in so thresholds separate relevance more cleanly.
+	TransformSigmoid Transform = "sigmoid"
+)
+
+// Default logistic shape: steep enough to spread the mid-range,
+// centered where linear scores cluster.
+const (
+	defaultSigmoidSteepness = 10.0
+	defaultSigmoidMidpoint  = 0.5
+)
+
+// KernelParams weights the components of the similarity kernel. The
+// weights should sum to 1 so the combined score stays in [0,1].
+type KernelParams struct {
+	SemanticWeight   float64 `json:"semantic_weight"`
+	LexicalWeight    float64 `json:"lexical_weight"`
+	StructuralWeight float64 `json:"structural_weight"`
+	// Metric selects the semantic-component computation; the zero
+	// value is cosine.
+	Metric Metric `json:"metric,omitempty"`
+	// Lexical selects the lexical-component computation; the zero
+	// value is Jaccard over the presence sets. The weighted metric
+	// falls back to Jaccard when either side predates n-gram counts.
+	Lexical LexicalMetric `json:"lexical_metric,omitempty"`
+	// Empty selects how two content-free documents score; the zero
+	// value is EmptyScoresZero.
+	Empty EmptyPolicy `json:"empty_policy,omitempty"`
+	// LanguageStrict zeroes the score when both feature sets carry a
+	// detected language and the languages differ, so cross-language
+	// token coincidences cannot pass the gate. Features without a
+	// confident detection are never penalized.
+	LanguageStrict bool `json:"language_strict,omitempty"`
+	// Transform shapes the combined score; the zero value keeps it
+	// linear. Component scores in the breakdown stay raw either way.
+	Transform Transform `json:"transform,omitempty"`
+	// SigmoidSteepness and SigmoidMidpoint tune the sigmoid transform:
+	// the curve is steepest at the midpoint, and larger steepness
+	// spreads scores around it harder. Zero values take the defaults
+	// (10, 0.5). Ignored unless Transform is sigmoid.
+	SigmoidSteepness float64 `json:"sigmoid_steepness,omitempty"`
+	SigmoidMidpoint  float64 `json:"sigmoid_midpoint,omitempty"`
+	// EmbeddingWeight scores the dense-vector cosine when both feature
+	// sets carry an embedding; when either lacks one, the weight rides
+	// the TF-IDF semantic component instead, so features without
+	// embeddings stay fully comparable. Counts toward the weight sum.
+	EmbeddingWeight float64 `json:"embedding_weight,omitempty"`
+}
+
+// DefaultKernelParams favors the TF-IDF component, with the n-gram and
+// structural components as tie-breakers.
+func DefaultKernelParams() KernelParams {
+	return KernelParams{
+		SemanticWeight:   0.6,
+		LexicalWeight:    0.3,
+		StructuralWeight: 0.1,
+	}
+}
+
+// Validate rejects weight sets that cannot produce a score in [0,1].
+func (p KernelParams) Validate() error {
+	if p.SemanticWeight < 0 || p.LexicalWeight < 0 || p.StructuralWeight < 0 || p.EmbeddingWeight < 0 {
+		return fmt.Errorf("kernel weights must be non-negative")
+	}
+	sum := p.SemanticWeight + p.LexicalWeight + p.StructuralWeight + p.EmbeddingWeight
+	if math.Abs(sum-1.0) > 1e-9 {
+		return fmt.Errorf("kernel weights sum to %.4f, want 1.0", sum)
+	}
+	switch p.Metric {
+	case MetricCosine, MetricDot, MetricEuclidean:
+	default:
+		return fmt.Errorf("unknown similarity metric %q", p.Metric)
+	}
+	switch p.Lexical {
+	case LexicalJaccard, LexicalWeighted:
+	default:
+		return fmt.Errorf("unknown lexical metric %q", p.Lexical)
+	}
+	switch p.Empty {
+	case EmptyScoresZero, EmptyScoresMatch:
+	default:
+		return fmt.Errorf("unknown empty-document policy %q", p.Empty)
+	}
+	switch p.Transform {
+	case TransformNone, TransformSigmoid:
+	default:
+		return fmt.Errorf("unknown output transform %q", p.Transform)
+	}
+	if p.SigmoidSteepness < 0 {
+		return fmt.Errorf("sigmoid steepness %.4f must be non-negative", p.SigmoidSteepness)
+	}
+	return nil
+}
+
+// shape applies the configured output transform to a combined score.
+// The sigmoid is rescaled so 0 and 1 map to themselves exactly, keeping
+// the output range [0,1]; being strictly increasing, it never reorders
+// a ranking.
+func (p KernelParams) shape(score float64) float64 {
+	switch p.Transform {
+	case TransformSigmoid:
+		k := p.SigmoidSteepness
+		if k == 0 {
+			k = defaultSigmoidSteepness
+		}
+		m := p.SigmoidMidpoint
+		if m == 0 {
+			m = defaultSigmoidMidpoint
+		}
+		sig := func(x float64) float64 { return 1 / (1 + math.Exp(-k*(x-m))) }
+		lo, hi := sig(0), sig(1)
+		return (sig(score) - lo) / (hi - lo)
+	default:
+		return score
+	}
+}
+
+// SimilarityBreakdown carries the per-component scores behind a
+// combined similarity, for explainability.
+type SimilarityBreakdown struct {
+	Semantic   float64 `json:"semantic"`
+	Lexical    float64 `json:"lexical"`
+	Structural float64 `json:"structural"`
+	// Embedding is the dense-vector cosine; zero unless both sides
+	// carry an embedding and the params weight it.
+	Embedding float64 `json:"embedding,omitempty"`
+	Combined  float64 `json:"combined"`
+}
+
+// CosineSimilarity computes the cosine of two sparse term vectors.
+func CosineSimilarity(a, b map[string]float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for term, wa := range a {
+		normA += wa * wa
+		if wb, ok := b[term]; ok {
+			dot += wa * wb
+		}
+	}
+	for _, wb := range b {
+		normB += wb * wb
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// DotSimilarity computes the raw dot product of two sparse term
+// vectors. Unlike cosine it is not normalized, so longer documents
+// score higher against themselves.
+func DotSimilarity(a, b map[string]float64) float64 {
+	var dot float64
+	for term, wa := range a {
+		if wb, ok := b[term]; ok {
+			dot += wa * wb
+		}
+	}
+	return dot
+}
+
+// EuclideanSimilarity maps the Euclidean distance between two sparse
+// term vectors into (0,1]: identical vectors score 1, and the score
+// decays as 1/(1+distance).
+func EuclideanSimilarity(a, b map[string]float64) float64 {
+	var sum float64
+	for term, wa := range a {
+		diff := wa - b[term]
+		sum += diff * diff
+	}
+	for term, wb := range b {
+		if _, ok := a[term]; !ok {
+			sum += wb * wb
+		}
+	}
+	return 1 / (1 + math.Sqrt(sum))
+}
+
+// CosineSimilarityPrenorm is CosineSimilarity over two feature sets,
+// reusing their cached vector norms instead of recomputing the sums of
+// squares on every call. Ranking a query against N candidates then
+// costs one norm per vector rather than N recomputations.
+func CosineSimilarityPrenorm(a, b *Features) float64 {
+	normA, normB := a.Norm(), b.Norm()
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	var dot float64
+	for term, wa := range a.TFIDF {
+		if wb, ok := b.TFIDF[term]; ok {
+			dot += wa * wb
+		}
+	}
+	return dot / (normA * normB)
+}
+
+// semanticSimilarity dispatches on the configured metric.
+func semanticSimilarity(a, b *Features, metric Metric) float64 {
+	switch metric {
+	case MetricDot:
+		return DotSimilarity(a.TFIDF, b.TFIDF)
+	case MetricEuclidean:
+		return EuclideanSimilarity(a.TFIDF, b.TFIDF)
+	default:
+		return CosineSimilarityPrenorm(a, b)
+	}
+}
+
+// JaccardSimilarity computes set overlap of two n-gram sets. Two empty
+// sets are considered identical.
+func JaccardSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1.0
+	}
+	intersection := 0
+	for gram := range a {
+		if b[gram] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+// NgramCosine computes the cosine of two n-gram count vectors, the
+// frequency-aware counterpart of JaccardSimilarity.
+func NgramCosine(a, b map[string]int) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for gram, ca := range a {
+		fa := float64(ca)
+		normA += fa * fa
+		if cb, ok := b[gram]; ok {
+			dot += fa * float64(cb)
+		}
+	}
+	for _, cb := range b {
+		fb := float64(cb)
+		normB += fb * fb
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// lexicalSimilarity computes the lexical component under the selected
+// metric. The weighted metric needs occurrence counts on both sides;
+// against features persisted before counts were recorded it falls
+// back to Jaccard rather than scoring zero.
+func lexicalSimilarity(a, b *Features, metric LexicalMetric) float64 {
+	if metric == LexicalWeighted && len(a.NgramCounts) > 0 && len(b.NgramCounts) > 0 {
+		return NgramCosine(a.NgramCounts, b.NgramCounts)
+	}
+	return JaccardSimilarity(a.Ngrams, b.Ngrams)
+}
+
+// KeywordSimilarity is a lightweight alternative metric: Jaccard
+// overlap of the two top-keyword sets. It ignores term weights beyond
+// the top-keyword cutoff, so it is cheap enough for prefiltering but
+// coarser than the full kernel. Identical content scores 1.0.
+func KeywordSimilarity(a, b *Features) float64 {
+	setA := make(map[string]bool, len(a.TopKeywords))
+	for _, kw := range a.TopKeywords {
+		setA[kw] = true
+	}
+	setB := make(map[string]bool, len(b.TopKeywords))
+	for _, kw := range b.TopKeywords {
+		setB[kw] = true
+	}
+	return JaccardSimilarity(setA, setB)
+}
+
+// StructuralSimilarity compares shape rather than content: document
+// length, vocabulary size, character entropy and average word length
+// ratios. The entropy and word-length terms separate high-entropy
+// noise from natural prose of the same length; either is skipped when
+// a side lacks it (features persisted before the fields existed), so
+// older fingerprints keep their original scores.
+func StructuralSimilarity(a, b *Features) float64 {
+	sum := ratio(float64(a.WordCount), float64(b.WordCount)) +
+		ratio(float64(a.UniqueWords), float64(b.UniqueWords))
+	terms := 2.0
+	if a.Entropy > 0 && b.Entropy > 0 {
+		sum += ratio(a.Entropy, b.Entropy)
+		terms++
+	}
+	if a.AvgWordLen > 0 && b.AvgWordLen > 0 {
+		sum += ratio(a.AvgWordLen, b.AvgWordLen)
+		terms++
+	}
+	return sum / terms
+}
+
+// ratio returns min/max of two non-negative values, treating two zeros
+// as a perfect match.
+func ratio(x, y float64) float64 {
+	if x == 0 && y == 0 {
+		return 1.0
+	}
+	if x > y {
+		x, y = y, x
+	}
+	if y == 0 {
+		return 0
+	}
+	return x / y
+}
+
+// Similarity combines the kernel components under the given weights
+// into a single score in [0,1]. Both feature sets must come from the
+// same n-gram width; use SimilarityChecked when that is not certain.
+func Similarity(a, b *Features, params KernelParams) float64 {
+	return Explain(a, b, params).Combined
+}
+
+// SimilarityChecked is Similarity with a compatibility check: it
+// fails instead of returning a meaningless lexical overlap when the
+// feature sets were extracted at different n-gram widths. Features
+// persisted before the width was recorded (NgramSize zero) are
+// assumed compatible.
+func SimilarityChecked(a, b *Features, params KernelParams) (float64, error) {
+	if a.NgramSize != 0 && b.NgramSize != 0 && a.NgramSize != b.NgramSize {
+		return 0, fmt.Errorf("n-gram size mismatch: %d vs %d", a.NgramSize, b.NgramSize)
+	}
+	return Similarity(a, b, params), nil
+}
+
+// featuresEmpty reports whether f describes content-free input.
+func featuresEmpty(f *Features) bool {
+	return f.CharCount == 0 && f.WordCount == 0
+}
+
+// Explain computes the similarity of two feature sets along with the
+// per-component breakdown. Two content-free documents score under the
+// params' EmptyPolicy rather than through the components, which would
+// call empty sets identical.
+func Explain(a, b *Features, params KernelParams) SimilarityBreakdown {
+	if params.LanguageStrict && a.Language != "" && b.Language != "" && a.Language != b.Language {
+		return SimilarityBreakdown{}
+	}
+	if featuresEmpty(a) && featuresEmpty(b) {
+		if params.Empty == EmptyScoresMatch {
+			return SimilarityBreakdown{Semantic: 1, Lexical: 1, Structural: 1, Combined: 1}
+		}
+		return SimilarityBreakdown{}
+	}
+	if a.IsBinary || b.IsBinary {
+		return binaryExplain(a, b, params)
+	}
+	breakdown := SimilarityBreakdown{
+		Semantic:   semanticSimilarity(a, b, params.Metric),
+		Lexical:    lexicalSimilarity(a, b, params.Lexical),
+		Structural: StructuralSimilarity(a, b),
+	}
+	semWeight, embWeight := params.SemanticWeight, params.EmbeddingWeight
+	if embWeight > 0 {
+		if len(a.Embedding) > 0 && len(b.Embedding) > 0 {
+			breakdown.Embedding = clamp01(DenseCosine(a.Embedding, b.Embedding))
+		} else {
+			// Without vectors on both sides the embedding weight rides
+			// the TF-IDF component, keeping embedded and plain features
+			// comparable on one scale.
+			semWeight += embWeight
+			embWeight = 0
+		}
+	}
+	combined := semWeight*breakdown.Semantic +
+		embWeight*breakdown.Embedding +
+		params.LexicalWeight*breakdown.Lexical +
+		params.StructuralWeight*breakdown.Structural
+	breakdown.Combined = params.shape(clamp01(combined))
+	return breakdown
+}
+
+// binaryExplain scores binary content. Token TF-IDF and character
+// n-grams mean nothing for raw bytes, so the byte-histogram cosine
+// stands in for both the semantic and lexical components, keeping the
+// combined score on the same scale as text; the structural component
+// is the size ratio. Binary content never matches text.
+func binaryExplain(a, b *Features, params KernelParams) SimilarityBreakdown {
+	if a.IsBinary != b.IsBinary {
+		return SimilarityBreakdown{}
+	}
+	hist := HistogramSimilarity(a.ByteHistogram, b.ByteHistogram)
+	breakdown := SimilarityBreakdown{
+		Semantic:   hist,
+		Lexical:    hist,
+		Structural: ratio(float64(a.CharCount), float64(b.CharCount)),
+	}
+	combined := params.SemanticWeight*breakdown.Semantic +
+		params.LexicalWeight*breakdown.Lexical +
+		params.StructuralWeight*breakdown.Structural
+	breakdown.Combined = params.shape(clamp01(combined))
+	return breakdown
+}
+
+// HistogramSimilarity is the cosine of two dense byte-value
+// distributions. Mismatched or missing histograms score 0.
+func HistogramSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// TermContribution is one shared term's contribution to the semantic
+// dot product between two feature sets.
+type TermContribution struct {
+	Term         string  `json:"term"`
+	Contribution float64 `json:"contribution"`
+}
+
+// ExplainTerms returns the terms present in both TF vectors ordered by
+// how much their weight product contributes to the dot product, best
+// first, capped at topN (non-positive topN returns all). Where Explain
+// says how similar two documents are, this says which words made them
+// so. Ties break alphabetically for deterministic output.
+func ExplainTerms(a, b *Features, topN int) []TermContribution {
+	var terms []TermContribution
+	for term, wa := range a.TFIDF {
+		if wb, ok := b.TFIDF[term]; ok {
+			terms = append(terms, TermContribution{Term: term, Contribution: wa * wb})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Contribution != terms[j].Contribution {
+			return terms[i].Contribution > terms[j].Contribution
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if topN > 0 && len(terms) > topN {
+		terms = terms[:topN]
+	}
+	return terms
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// RankedResult is one entry of a similarity ranking. Index refers to
+// the candidate's position in the input slice.
+type RankedResult struct {
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+}
+
+// RankBySimilarity scores every candidate against the query features
+// and returns the results ordered best-first. Ties are broken by input
+// order so rankings are deterministic.
+func RankBySimilarity(query *Features, candidates []*Features, params KernelParams) []RankedResult {
+	results := make([]RankedResult, len(candidates))
+	for i, cand := range candidates {
+		results[i] = RankedResult{Index: i, Score: Similarity(query, cand, params)}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// RankBySimilarityRecency is RankBySimilarity with a freshness blend:
+// each candidate's score is (1-recencyWeight) times its similarity plus
+// recencyWeight times its recency, where recency is the candidate's
+// timestamp normalized linearly over the candidate set (oldest 0,
+// newest 1). timestamps[i] pairs with candidates[i]. A recencyWeight of
+// zero (or a timestamp slice of the wrong length) reproduces the plain
+// ranking exactly; weights above 1 are clamped to rank purely by age.
+func RankBySimilarityRecency(query *Features, candidates []*Features, timestamps []int64, params KernelParams, recencyWeight float64) []RankedResult {
+	if recencyWeight <= 0 || len(timestamps) != len(candidates) {
+		return RankBySimilarity(query, candidates, params)
+	}
+	if recencyWeight > 1 {
+		recencyWeight = 1
+	}
+	minTS, maxTS := timestamps[0], timestamps[0]
+	for _, ts := range timestamps[1:] {
+		if ts < minTS {
+			minTS = ts
+		}
+		if ts > maxTS {
+			maxTS = ts
+		}
+	}
+	results := make([]RankedResult, len(candidates))
+	for i, cand := range candidates {
+		// Identical timestamps carry no ordering information; score
+		// every candidate's recency as 1 so the blend cannot deflate an
+		// otherwise-equal set.
+		recency := 1.0
+		if maxTS > minTS {
+			recency = float64(timestamps[i]-minTS) / float64(maxTS-minTS)
+		}
+		score := (1-recencyWeight)*Similarity(query, cand, params) + recencyWeight*recency
+		results[i] = RankedResult{Index: i, Score: score}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// SimilarityMatrix computes all-pairs similarity over a document set,
+// the building block for clustering and visualization. The result is
+// a symmetric NxN matrix with 1.0 on the diagonal; each pair is
+// computed once and mirrored.
+func SimilarityMatrix(features []*Features, params KernelParams) [][]float64 {
+	matrix := make([][]float64, len(features))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(features))
+		matrix[i][i] = 1.0
+	}
+	for i := 0; i < len(features); i++ {
+		for j := i + 1; j < len(features); j++ {
+			score := Similarity(features[i], features[j], params)
+			matrix[i][j] = score
+			matrix[j][i] = score
+		}
+	}
+	return matrix
+}
+
+// RankBySimilarityParallel is RankBySimilarity with the similarity
+// computations spread over a pool of workers, for large candidate
+// sets. Results are identical to the serial version, including tie
+// order. Workers at or below one falls back to the serial path.
+func RankBySimilarityParallel(query *Features, candidates []*Features, params KernelParams, workers int) []RankedResult {
+	if workers <= 1 || len(candidates) < 2 {
+		return RankBySimilarity(query, candidates, params)
+	}
+	if workers > len(candidates) {
+		workers = len(candidates)
+	}
+
+	// The query is shared by every worker while each candidate belongs
+	// to exactly one, so the query's lazily cached norm is the only
+	// write they could race on; compute it before the pool starts.
+	query.Norm()
+
+	results := make([]RankedResult, len(candidates))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = RankedResult{Index: i, Score: Similarity(query, candidates[i], params)}
+			}
+		}()
+	}
+	for i := range candidates {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
@@ -0,0 +1,567 @@
+package semantic
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestKernelParamsValidate(t *testing.T) {
+	if err := DefaultKernelParams().Validate(); err != nil {
+		t.Fatalf("default params invalid: %v", err)
+	}
+	bad := KernelParams{SemanticWeight: 0.9, LexicalWeight: 0.9, StructuralWeight: 0.9}
+	if err := bad.Validate(); err == nil {
+		t.Fatal("overweight params accepted")
+	}
+	negative := KernelParams{SemanticWeight: -0.5, LexicalWeight: 1.0, StructuralWeight: 0.5}
+	if err := negative.Validate(); err == nil {
+		t.Fatal("negative weight accepted")
+	}
+}
+
+func TestSimilarityIdentical(t *testing.T) {
+	f := ExtractFeatures([]byte("machine learning models learn from data"))
+	score := Similarity(f, f, DefaultKernelParams())
+	if score < 0.999 {
+		t.Fatalf("self similarity = %f, want ~1.0", score)
+	}
+}
+
+func TestSimilarityOrdering(t *testing.T) {
+	ml1 := ExtractFeatures([]byte("neural networks and machine learning models"))
+	ml2 := ExtractFeatures([]byte("training machine learning models with neural networks"))
+	cooking := ExtractFeatures([]byte("recipes for baking sourdough bread at home"))
+
+	params := DefaultKernelParams()
+	related := Similarity(ml1, ml2, params)
+	unrelated := Similarity(ml1, cooking, params)
+	if related <= unrelated {
+		t.Fatalf("related score %f should exceed unrelated score %f", related, unrelated)
+	}
+}
+
+func TestExplainComponents(t *testing.T) {
+	a := ExtractFeatures([]byte("distributed hash tables route by key"))
+	b := ExtractFeatures([]byte("hash tables store values by key"))
+	breakdown := Explain(a, b, DefaultKernelParams())
+
+	for name, v := range map[string]float64{
+		"semantic":   breakdown.Semantic,
+		"lexical":    breakdown.Lexical,
+		"structural": breakdown.Structural,
+		"combined":   breakdown.Combined,
+	} {
+		if v < 0 || v > 1 {
+			t.Fatalf("%s component %f outside [0,1]", name, v)
+		}
+	}
+}
+
+func TestRankBySimilarity(t *testing.T) {
+	query := ExtractFeatures([]byte("machine learning"))
+	candidates := []*Features{
+		ExtractFeatures([]byte("gardening tips for spring")),
+		ExtractFeatures([]byte("machine learning in production")),
+		ExtractFeatures([]byte("a history of sailing ships")),
+	}
+	results := RankBySimilarity(query, candidates, DefaultKernelParams())
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[0].Index != 1 {
+		t.Fatalf("best result index = %d, want 1", results[0].Index)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Fatal("results not sorted by score")
+		}
+	}
+}
+
+func TestJaccardEmptySets(t *testing.T) {
+	if got := JaccardSimilarity(nil, nil); got != 1.0 {
+		t.Fatalf("empty sets Jaccard = %f, want 1.0", got)
+	}
+	nonEmpty := map[string]bool{"abc": true}
+	if got := JaccardSimilarity(nonEmpty, nil); got != 0 {
+		t.Fatalf("empty vs non-empty Jaccard = %f, want 0", got)
+	}
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	empty := ExtractFeatures(nil)
+	nonempty := ExtractFeatures([]byte("machine learning models learn from data"))
+	params := DefaultKernelParams()
+
+	if got := Similarity(empty, empty, params); got != 0 {
+		t.Fatalf("empty vs empty = %f, want 0 under default policy", got)
+	}
+	if got := Similarity(empty, nonempty, params); got != 0 {
+		t.Fatalf("empty vs non-empty = %f, want 0", got)
+	}
+	if got := Similarity(nonempty, nonempty, params); got < 0.999 {
+		t.Fatalf("non-empty self similarity = %f, want ~1.0", got)
+	}
+
+	params.Empty = EmptyScoresMatch
+	if got := Similarity(empty, empty, params); got != 1.0 {
+		t.Fatalf("empty vs empty = %f, want 1.0 under match policy", got)
+	}
+	if err := params.Validate(); err != nil {
+		t.Fatalf("match policy invalid: %v", err)
+	}
+	params.Empty = "sometimes"
+	if err := params.Validate(); err == nil {
+		t.Fatal("unknown empty policy accepted")
+	}
+}
+
+func TestMetricsAgreeOnIdenticalUnitVectors(t *testing.T) {
+	vec := map[string]float64{"tera": 1.0}
+	for name, score := range map[string]float64{
+		"cosine":    CosineSimilarity(vec, vec),
+		"dot":       DotSimilarity(vec, vec),
+		"euclidean": EuclideanSimilarity(vec, vec),
+	} {
+		if math.Abs(score-1.0) > 1e-9 {
+			t.Fatalf("%s(v, v) = %f, want 1.0", name, score)
+		}
+	}
+}
+
+func TestDotDiffersFromCosineUnnormalized(t *testing.T) {
+	vec := map[string]float64{"tera": 2.0, "net": 2.0}
+	cos := CosineSimilarity(vec, vec)
+	dot := DotSimilarity(vec, vec)
+	if math.Abs(cos-1.0) > 1e-9 {
+		t.Fatalf("cosine(v, v) = %f, want 1.0", cos)
+	}
+	if math.Abs(dot-8.0) > 1e-9 {
+		t.Fatalf("dot(v, v) = %f, want 8.0", dot)
+	}
+}
+
+func TestValidateRejectsUnknownMetric(t *testing.T) {
+	params := DefaultKernelParams()
+	params.Metric = "manhattan"
+	if err := params.Validate(); err == nil {
+		t.Fatal("unknown metric accepted")
+	}
+	for _, m := range []Metric{MetricCosine, MetricDot, MetricEuclidean} {
+		params.Metric = m
+		if err := params.Validate(); err != nil {
+			t.Fatalf("metric %q rejected: %v", m, err)
+		}
+	}
+}
+
+func TestEuclideanMetricInExplain(t *testing.T) {
+	a := ExtractFeatures([]byte("distributed hash tables"))
+	params := DefaultKernelParams()
+	params.Metric = MetricEuclidean
+	breakdown := Explain(a, a, params)
+	if math.Abs(breakdown.Semantic-1.0) > 1e-9 {
+		t.Fatalf("euclidean self-similarity = %f, want 1.0", breakdown.Semantic)
+	}
+}
+
+func TestSimilarityCheckedNgramMismatch(t *testing.T) {
+	text := []byte("machine learning models learn from data")
+	bigram := ExtractFeaturesN(text, 2)
+	trigram := ExtractFeaturesN(text, 3)
+	params := DefaultKernelParams()
+
+	if _, err := SimilarityChecked(bigram, trigram, params); err == nil {
+		t.Fatal("mismatched n-gram widths accepted")
+	}
+	score, err := SimilarityChecked(trigram, ExtractFeatures(text), params)
+	if err != nil {
+		t.Fatalf("matching widths rejected: %v", err)
+	}
+	if score < 0.999 {
+		t.Fatalf("self similarity = %f, want ~1.0", score)
+	}
+
+	// Features persisted before the width was recorded pass the check.
+	legacy := ExtractFeatures(text)
+	legacy.NgramSize = 0
+	if _, err := SimilarityChecked(legacy, trigram, params); err != nil {
+		t.Fatalf("legacy features rejected: %v", err)
+	}
+}
+
+// rankCorpus builds n synthetic candidate feature sets with enough
+// variety to produce distinct scores and some ties.
+func rankCorpus(n int) []*Features {
+	topics := []string{
+		"machine learning models",
+		"distributed hash tables",
+		"sourdough bread baking",
+		"sailing ship history",
+	}
+	candidates := make([]*Features, n)
+	for i := range candidates {
+		text := fmt.Sprintf("%s document number %d", topics[i%len(topics)], i/len(topics))
+		candidates[i] = ExtractFeatures([]byte(text))
+	}
+	return candidates
+}
+
+func TestRankBySimilarityParallelMatchesSerial(t *testing.T) {
+	// The query carries a deliberately large vocabulary so computing
+	// its norm is slow enough for the workers' first uses of the
+	// cached norm to overlap under the race detector.
+	var bulk strings.Builder
+	bulk.WriteString("machine learning models")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&bulk, " term%05d", i)
+	}
+	query := ExtractFeatures([]byte(bulk.String()))
+	candidates := rankCorpus(500)
+	params := DefaultKernelParams()
+
+	// Parallel first, against a query whose norm is not yet cached, so
+	// the race detector sees the workers' first use of it.
+	first := RankBySimilarityParallel(query, candidates, params, 8)
+	serial := RankBySimilarity(query, candidates, params)
+	for i := range serial {
+		if first[i] != serial[i] {
+			t.Fatalf("uncached-norm result %d = %+v, want %+v", i, first[i], serial[i])
+		}
+	}
+	for _, workers := range []int{1, 2, 8, len(candidates) + 1} {
+		parallel := RankBySimilarityParallel(query, candidates, params, workers)
+		if len(parallel) != len(serial) {
+			t.Fatalf("workers=%d: got %d results, want %d", workers, len(parallel), len(serial))
+		}
+		for i := range serial {
+			if parallel[i] != serial[i] {
+				t.Fatalf("workers=%d: result %d = %+v, want %+v",
+					workers, i, parallel[i], serial[i])
+			}
+		}
+	}
+}
+
+func BenchmarkRankBySimilarityParallel(b *testing.B) {
+	query := ExtractFeatures([]byte("machine learning models"))
+	candidates := rankCorpus(50000)
+	params := DefaultKernelParams()
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				RankBySimilarityParallel(query, candidates, params, workers)
+			}
+		})
+	}
+}
+
+func TestCosineSimilarityPrenormMatchesCosine(t *testing.T) {
+	a := ExtractFeatures([]byte("machine learning models learn from data"))
+	b := ExtractFeatures([]byte("training machine learning models"))
+	want := CosineSimilarity(a.TFIDF, b.TFIDF)
+	if got := CosineSimilarityPrenorm(a, b); math.Abs(got-want) > 1e-12 {
+		t.Fatalf("prenorm cosine = %f, want %f", got, want)
+	}
+
+	// Features decoded from JSON lose the cached norm and must compute
+	// it lazily, landing on the same score.
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Features
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := CosineSimilarityPrenorm(&decoded, b); math.Abs(got-want) > 1e-12 {
+		t.Fatalf("prenorm cosine after JSON round trip = %f, want %f", got, want)
+	}
+}
+
+func BenchmarkRankBySimilarity(b *testing.B) {
+	query := ExtractFeatures([]byte("machine learning models"))
+	candidates := rankCorpus(50000)
+	params := DefaultKernelParams()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RankBySimilarity(query, candidates, params)
+	}
+}
+
+func TestExplainTermsSurfacesSharedKeywords(t *testing.T) {
+	a := ExtractFeatures([]byte("neural networks for machine learning learning learning"))
+	b := ExtractFeatures([]byte("deep learning learning with neural networks"))
+
+	terms := ExplainTerms(a, b, 3)
+	if len(terms) != 3 {
+		t.Fatalf("got %d terms, want 3", len(terms))
+	}
+	if terms[0].Term != "learning" {
+		t.Fatalf("top contributor = %q, want learning", terms[0].Term)
+	}
+	seen := map[string]bool{}
+	for i, term := range terms {
+		seen[term.Term] = true
+		if term.Contribution <= 0 {
+			t.Fatalf("term %q has non-positive contribution %f", term.Term, term.Contribution)
+		}
+		if i > 0 && term.Contribution > terms[i-1].Contribution {
+			t.Fatal("terms not sorted by contribution")
+		}
+	}
+	if !seen["neural"] || !seen["networks"] {
+		t.Fatalf("shared keywords missing from top terms: %v", terms)
+	}
+
+	if got := ExplainTerms(a, ExtractFeatures([]byte("sourdough baking")), 0); len(got) != 0 {
+		t.Fatalf("disjoint documents produced contributions: %v", got)
+	}
+}
+
+func TestKeywordSimilarity(t *testing.T) {
+	data := []byte("machine learning models learn from data")
+	a := ExtractFeatures(data)
+	b := ExtractFeatures(data)
+	if got := KeywordSimilarity(a, b); got != 1.0 {
+		t.Fatalf("identical content keyword similarity = %f, want 1.0", got)
+	}
+	c := ExtractFeatures([]byte("sourdough bread baking at home"))
+	if got := KeywordSimilarity(a, c); got != 0 {
+		t.Fatalf("disjoint content keyword similarity = %f, want 0", got)
+	}
+}
+
+func TestSigmoidTransformShape(t *testing.T) {
+	p := DefaultKernelParams()
+	p.Transform = TransformSigmoid
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	// Endpoints map to themselves exactly.
+	if got := p.shape(0); got != 0 {
+		t.Fatalf("shape(0) = %f, want 0", got)
+	}
+	if got := p.shape(1); got != 1 {
+		t.Fatalf("shape(1) = %f, want 1", got)
+	}
+
+	// Strictly increasing, so it can never reorder a ranking.
+	prev := -1.0
+	for x := 0.0; x <= 1.0; x += 0.01 {
+		y := p.shape(x)
+		if y <= prev {
+			t.Fatalf("shape not strictly increasing at %f: %f <= %f", x, y, prev)
+		}
+		prev = y
+	}
+
+	// The mid-range spreads while the tails squash: the slope around
+	// the midpoint beats linear, the slope near zero does not reach it.
+	if spread := p.shape(0.55) - p.shape(0.45); spread <= 0.1 {
+		t.Fatalf("mid-range spread %f, want > 0.1", spread)
+	}
+	if tail := p.shape(0.1) - p.shape(0.0); tail >= 0.1 {
+		t.Fatalf("tail spread %f, want < 0.1", tail)
+	}
+}
+
+func TestSigmoidTransformEndToEnd(t *testing.T) {
+	query := ExtractFeatures([]byte("machine learning models"))
+	near := ExtractFeatures([]byte("machine learning models in production"))
+	far := ExtractFeatures([]byte("a history of sailing ships"))
+
+	linear := DefaultKernelParams()
+	shaped := DefaultKernelParams()
+	shaped.Transform = TransformSigmoid
+
+	if Similarity(query, near, shaped) <= Similarity(query, far, shaped) {
+		t.Fatal("sigmoid transform reordered relevance")
+	}
+	if Similarity(query, near, shaped) == Similarity(query, near, linear) {
+		t.Fatal("sigmoid transform left the score untouched")
+	}
+}
+
+func TestValidateRejectsBadTransform(t *testing.T) {
+	p := DefaultKernelParams()
+	p.Transform = "logit"
+	if err := p.Validate(); err == nil {
+		t.Fatal("unknown transform passed validation")
+	}
+	p = DefaultKernelParams()
+	p.Transform = TransformSigmoid
+	p.SigmoidSteepness = -3
+	if err := p.Validate(); err == nil {
+		t.Fatal("negative steepness passed validation")
+	}
+}
+
+func TestSimilarityMatrix(t *testing.T) {
+	docs := []*Features{
+		ExtractFeatures([]byte("machine learning models learn from data")),
+		ExtractFeatures([]byte("deep learning is a machine learning technique")),
+		ExtractFeatures([]byte("slow-roasted vegetables with garlic butter")),
+	}
+	m := SimilarityMatrix(docs, DefaultKernelParams())
+
+	if len(m) != len(docs) {
+		t.Fatalf("matrix has %d rows, want %d", len(m), len(docs))
+	}
+	for i := range m {
+		if len(m[i]) != len(docs) {
+			t.Fatalf("row %d has %d columns, want %d", i, len(m[i]), len(docs))
+		}
+		if m[i][i] != 1.0 {
+			t.Fatalf("diagonal [%d][%d] = %f, want 1.0", i, i, m[i][i])
+		}
+		for j := range m[i] {
+			if m[i][j] != m[j][i] {
+				t.Fatalf("matrix not symmetric at [%d][%d]: %f vs %f", i, j, m[i][j], m[j][i])
+			}
+		}
+	}
+	if m[0][1] <= m[0][2] {
+		t.Fatalf("ML pair scored %f, not above ML-vs-cooking %f", m[0][1], m[0][2])
+	}
+}
+
+func TestRankBySimilarityRecency(t *testing.T) {
+	query := ExtractFeatures([]byte("machine learning models"))
+	// Two identical documents differ only in age.
+	candidates := []*Features{
+		ExtractFeatures([]byte("machine learning models in production")),
+		ExtractFeatures([]byte("machine learning models in production")),
+		ExtractFeatures([]byte("a history of sailing ships")),
+	}
+	timestamps := []int64{100, 200, 300}
+	params := DefaultKernelParams()
+
+	plain := RankBySimilarity(query, candidates, params)
+	unweighted := RankBySimilarityRecency(query, candidates, timestamps, params, 0)
+	for i := range plain {
+		if unweighted[i] != plain[i] {
+			t.Fatalf("zero recency weight changed the ranking at %d: %+v vs %+v",
+				i, unweighted[i], plain[i])
+		}
+	}
+
+	blended := RankBySimilarityRecency(query, candidates, timestamps, params, 0.2)
+	if blended[0].Index != 1 || blended[1].Index != 0 {
+		t.Fatalf("equal-similarity docs not ordered newest-first: %+v", blended)
+	}
+	if blended[2].Index != 2 {
+		t.Fatalf("recency blend promoted an irrelevant doc above relevant ones: %+v", blended)
+	}
+}
+
+func TestStructuralSimilarityEntropy(t *testing.T) {
+	natural1 := ExtractFeatures([]byte(
+		"the committee met on tuesday to review the budget and agreed " +
+			"to fund the library for another year of public service"))
+	natural2 := ExtractFeatures([]byte(
+		"volunteers gathered at the river on saturday to clear debris " +
+			"and plant native trees along the eroded southern bank"))
+
+	// A random-character blob of the same byte length as natural1.
+	rng := rand.New(rand.NewSource(7))
+	blob := make([]byte, natural1.CharCount)
+	for i := range blob {
+		blob[i] = byte('a' + rng.Intn(26))
+	}
+	random := ExtractFeatures(blob)
+
+	if random.Entropy <= natural1.Entropy {
+		t.Fatalf("random entropy %f not above prose entropy %f",
+			random.Entropy, natural1.Entropy)
+	}
+	prosePair := StructuralSimilarity(natural1, natural2)
+	noisePair := StructuralSimilarity(natural1, random)
+	if prosePair <= noisePair {
+		t.Fatalf("prose pair %f not above noise pair %f", prosePair, noisePair)
+	}
+	if prosePair < 0.8 {
+		t.Fatalf("prose pair %f, want >= 0.8", prosePair)
+	}
+	if noisePair > 0.5 {
+		t.Fatalf("noise pair %f, want <= 0.5", noisePair)
+	}
+
+	// Noise shaped into prose-length words still scores below real
+	// prose: the entropy and word-length terms do the separating once
+	// the word counts line up.
+	var words []string
+	for len(strings.Join(words, " ")) < natural1.CharCount {
+		n := 3 + rng.Intn(5)
+		w := make([]byte, n)
+		for i := range w {
+			w[i] = byte('a' + rng.Intn(26))
+		}
+		words = append(words, string(w))
+	}
+	shaped := ExtractFeatures([]byte(strings.Join(words, " ")))
+	shapedPair := StructuralSimilarity(natural1, shaped)
+	if prosePair <= shapedPair {
+		t.Fatalf("prose pair %f not above shaped-noise pair %f", prosePair, shapedPair)
+	}
+
+	// Features persisted before the entropy fields existed fall back to
+	// the original two-ratio score.
+	legacyA := &Features{WordCount: 20, UniqueWords: 15}
+	legacyB := &Features{WordCount: 10, UniqueWords: 10}
+	if got, want := StructuralSimilarity(legacyA, legacyB), (0.5+10.0/15.0)/2; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("legacy structural similarity = %f, want %f", got, want)
+	}
+}
+
+func TestWeightedLexicalMetric(t *testing.T) {
+	// Both documents repeat the same phrase; the filler differs. The
+	// presence sets weight every gram equally, so the differing filler
+	// drags Jaccard down, while the count cosine is dominated by the
+	// repeated shared phrase.
+	repeat := func(phrase string, n int) string {
+		return strings.TrimSpace(strings.Repeat(phrase+" ", n))
+	}
+	a := ExtractFeatures([]byte(repeat("distributed hash tables", 8) +
+		" annotated by the archival working group"))
+	b := ExtractFeatures([]byte(repeat("distributed hash tables", 8) +
+		" compiled for the visiting review panel"))
+
+	params := DefaultKernelParams()
+	jaccard := Explain(a, b, params).Lexical
+	params.Lexical = LexicalWeighted
+	weighted := Explain(a, b, params).Lexical
+	if weighted <= jaccard {
+		t.Fatalf("weighted lexical %f not above jaccard %f", weighted, jaccard)
+	}
+	if self := NgramCosine(a.NgramCounts, a.NgramCounts); math.Abs(self-1.0) > 1e-9 {
+		t.Fatalf("count cosine self-similarity = %f, want 1.0", self)
+	}
+
+	// Features persisted before counts were recorded fall back to
+	// Jaccard instead of scoring zero.
+	legacyA, legacyB := *a, *b
+	legacyA.NgramCounts, legacyB.NgramCounts = nil, nil
+	if got := Explain(&legacyA, &legacyB, params).Lexical; got != jaccard {
+		t.Fatalf("legacy fallback lexical = %f, want jaccard %f", got, jaccard)
+	}
+}
+
+func TestValidateRejectsUnknownLexicalMetric(t *testing.T) {
+	params := DefaultKernelParams()
+	params.Lexical = "tfidf"
+	if err := params.Validate(); err == nil {
+		t.Fatal("unknown lexical metric accepted")
+	}
+	for _, m := range []LexicalMetric{LexicalJaccard, LexicalWeighted} {
+		params.Lexical = m
+		if err := params.Validate(); err != nil {
+			t.Fatalf("lexical metric %q rejected: %v", m, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package semantic
+
+// Language detection by character trigram profiles. The profiles are
+// built in rather than trained so the package stays dependency-free;
+// they cover a handful of high-traffic languages, which is enough to
+// keep cross-language content from polluting similarity scores.
+
+// minLanguageConfidence is the detection confidence below which
+// ExtractFeatures leaves the Language field empty rather than guessing.
+const minLanguageConfidence = 0.3
+
+// languageProfiles maps a language code to its most frequent character
+// trigrams, best first. Spaces mark word boundaries, matching the
+// normalization GenerateNgrams applies.
+var languageProfiles = map[string][]string{
+	"en": {
+		" th", "the", "he ", " an", "and", "nd ", "ing", "ng ", " of",
+		"of ", "ed ", " to", "to ", " in", "in ", "ion", "tio", "ent",
+		"er ", "at ", " is", "is ", "es ", "on ", " co",
+	},
+	"es": {
+		" de", "de ", " la", "la ", " el", "el ", "os ", "as ", " qu",
+		"que", "ue ", " en", "en ", "es ", " es", "ión", "ció", "aci",
+		"ar ", "nte", " co", "an ", " se", "do ", " po",
+	},
+	"fr": {
+		" de", "de ", "es ", " le", "le ", "ent", "nt ", " la", "la ",
+		" et", "et ", "on ", " pa", " qu", "que", "ue ", "re ", "ion",
+		"tio", " co", "les", " un", "un ", "ait", "eur",
+	},
+	"de": {
+		"en ", "er ", " de", "der", " di", "die", "ie ", "nd ", " un",
+		"und", "ein", "ch ", "ich", "sch", " ge", "ung", "ng ", "che",
+		" be", "ten", "cht", "ht ", " da", "das", "it ",
+	},
+}
+
+// DetectLanguage guesses the language of content from its character
+// trigrams. It returns the language code with the best profile match
+// and a confidence in [0,1]: the winner's share of the total score
+// across all profiles. Content too short to form trigrams, or matching
+// no profile at all, returns ("", 0).
+func DetectLanguage(content []byte) (lang string, confidence float64) {
+	return detectLanguageGrams(GenerateNgrams(string(content), ngramSize))
+}
+
+// detectLanguageGrams is DetectLanguage over an already-built trigram
+// set, so the streaming extractor can reuse the grams it accumulated.
+func detectLanguageGrams(grams map[string]bool) (lang string, confidence float64) {
+	if len(grams) == 0 {
+		return "", 0
+	}
+
+	var total float64
+	var best float64
+	for code, profile := range languageProfiles {
+		var score float64
+		for rank, gram := range profile {
+			if grams[gram] {
+				// Earlier profile entries are more frequent in the
+				// language, so weight them higher.
+				score += float64(len(profile) - rank)
+			}
+		}
+		total += score
+		if score > best || (score == best && score > 0 && code < lang) {
+			best = score
+			lang = code
+		}
+	}
+	if best == 0 {
+		return "", 0
+	}
+	return lang, best / total
+}
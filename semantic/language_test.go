@@ -0,0 +1,58 @@
+package semantic
+
+import "testing"
+
+const englishSample = "The quick brown fox jumps over the lazy dog and " +
+	"the rest of the animals in the field are watching the show."
+
+const spanishSample = "La historia de la ciudad es que los habitantes " +
+	"construyeron el puente para que el comercio de la región creciera."
+
+func TestDetectLanguageEnglishVsSpanish(t *testing.T) {
+	lang, conf := DetectLanguage([]byte(englishSample))
+	if lang != "en" {
+		t.Fatalf("english sample detected as %q (confidence %f)", lang, conf)
+	}
+	if conf <= 0 || conf > 1 {
+		t.Fatalf("confidence %f outside (0,1]", conf)
+	}
+
+	lang, _ = DetectLanguage([]byte(spanishSample))
+	if lang == "en" {
+		t.Fatal("spanish sample detected as english")
+	}
+}
+
+func TestDetectLanguageEmptyContent(t *testing.T) {
+	if lang, conf := DetectLanguage(nil); lang != "" || conf != 0 {
+		t.Fatalf("empty content detected as %q with confidence %f", lang, conf)
+	}
+}
+
+func TestExtractFeaturesPopulatesLanguage(t *testing.T) {
+	f := ExtractFeatures([]byte(englishSample))
+	if f.Language != "en" {
+		t.Fatalf("Language = %q, want en", f.Language)
+	}
+}
+
+func TestLanguageStrictZeroesCrossLanguageSimilarity(t *testing.T) {
+	a := ExtractFeatures([]byte(englishSample))
+	b := ExtractFeatures([]byte(spanishSample))
+	if a.Language == "" || b.Language == "" || a.Language == b.Language {
+		t.Fatalf("samples not detected as distinct languages: %q vs %q",
+			a.Language, b.Language)
+	}
+
+	params := DefaultKernelParams()
+	params.LanguageStrict = true
+	if got := Similarity(a, b, params); got != 0 {
+		t.Fatalf("cross-language similarity = %f, want 0", got)
+	}
+
+	// Without a confident detection on one side the gate stays open.
+	b.Language = ""
+	if got := Similarity(a, b, params); got == 0 {
+		t.Fatal("undetected language should not be penalized")
+	}
+}
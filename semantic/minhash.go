@@ -0,0 +1,56 @@
+package semantic
+
+import "hash/fnv"
+
+// MinHashSignature computes a MinHash signature of the feature set's
+// n-grams. Signatures of equal length can be compared with
+// EstimateJaccard to approximate JaccardSimilarity without touching
+// the underlying sets, which makes them a cheap prefilter for large
+// candidate pools.
+func MinHashSignature(f *Features, numHashes int) []uint64 {
+	sig := make([]uint64, numHashes)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for gram := range f.Ngrams {
+		h := fnv.New64a()
+		h.Write([]byte(gram))
+		base := h.Sum64()
+		for i := range sig {
+			// splitmix64 of base mixed with the slot index gives
+			// numHashes independent hash functions from one digest.
+			v := splitmix64(base + uint64(i)*0x9e3779b97f4a7c15)
+			if v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// splitmix64 is the finalizer of the SplitMix64 generator; it mixes
+// its input into a well-distributed 64-bit value.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// EstimateJaccard approximates the Jaccard similarity of the two sets
+// the signatures were derived from: the fraction of slots where the
+// minima agree. Both signatures must come from MinHashSignature with
+// the same length. Two empty-set signatures estimate 1, matching
+// JaccardSimilarity's convention.
+func EstimateJaccard(sigA, sigB []uint64) float64 {
+	if len(sigA) == 0 || len(sigA) != len(sigB) {
+		return 0
+	}
+	matches := 0
+	for i := range sigA {
+		if sigA[i] == sigB[i] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(sigA))
+}
@@ -0,0 +1,89 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomNgramFeatures builds a Features with a random n-gram set drawn
+// from a shared universe, so pairs overlap to varying degrees.
+func randomNgramFeatures(rng *rand.Rand, universe, size int) *Features {
+	grams := make(map[string]bool, size)
+	for len(grams) < size {
+		grams[fmt.Sprintf("g%04d", rng.Intn(universe))] = true
+	}
+	return &Features{Ngrams: grams}
+}
+
+func TestEstimateJaccardTracksExact(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	const numHashes = 256
+	for trial := 0; trial < 20; trial++ {
+		a := randomNgramFeatures(rng, 500, 120)
+		b := randomNgramFeatures(rng, 500, 120)
+		exact := JaccardSimilarity(a.Ngrams, b.Ngrams)
+		estimate := EstimateJaccard(
+			MinHashSignature(a, numHashes),
+			MinHashSignature(b, numHashes),
+		)
+		// Standard error of a 256-slot signature is about 0.03; 0.12
+		// gives comfortable headroom over 20 trials.
+		if math.Abs(exact-estimate) > 0.12 {
+			t.Fatalf("trial %d: estimate %.3f vs exact %.3f", trial, estimate, exact)
+		}
+	}
+}
+
+func TestEstimateJaccardIdenticalAndDisjoint(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	a := randomNgramFeatures(rng, 500, 100)
+	sig := MinHashSignature(a, 128)
+	if got := EstimateJaccard(sig, sig); got != 1.0 {
+		t.Fatalf("identical signatures estimate %.3f, want 1.0", got)
+	}
+	if got := EstimateJaccard(sig, MinHashSignature(a, 64)); got != 0 {
+		t.Fatalf("mismatched signature lengths estimate %.3f, want 0", got)
+	}
+}
+
+func BenchmarkRankBySimilarity10k(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	query := randomNgramFeatures(rng, 2000, 150)
+	query.TFIDF = map[string]float64{"tera": 1}
+	candidates := make([]*Features, 10000)
+	for i := range candidates {
+		candidates[i] = randomNgramFeatures(rng, 2000, 150)
+		candidates[i].TFIDF = map[string]float64{"tera": 1}
+	}
+	params := DefaultKernelParams()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RankBySimilarity(query, candidates, params)
+	}
+}
+
+func BenchmarkMinHashPrefilteredRanking10k(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	query := randomNgramFeatures(rng, 2000, 150)
+	query.TFIDF = map[string]float64{"tera": 1}
+	query.MinHash = MinHashSignature(query, 64)
+	candidates := make([]*Features, 10000)
+	for i := range candidates {
+		candidates[i] = randomNgramFeatures(rng, 2000, 150)
+		candidates[i].TFIDF = map[string]float64{"tera": 1}
+		candidates[i].MinHash = MinHashSignature(candidates[i], 64)
+	}
+	params := DefaultKernelParams()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		survivors := make([]*Features, 0, len(candidates))
+		for _, cand := range candidates {
+			if EstimateJaccard(query.MinHash, cand.MinHash) >= 0.1 {
+				survivors = append(survivors, cand)
+			}
+		}
+		RankBySimilarity(query, survivors, params)
+	}
+}
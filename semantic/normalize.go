@@ -0,0 +1,41 @@
+package semantic
+
+import (
+	"io"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldDiacritics makes Tokenize strip diacritical marks after
+// normalization, so "naïve" and "naive" produce the same token. Off by
+// default because folding loses information in languages where accents
+// are distinctive.
+var FoldDiacritics = false
+
+// normalizeText brings text into NFC so composed and decomposed forms
+// of the same string tokenize identically; with fold set, combining
+// marks are removed as well.
+func normalizeText(text string, fold bool) string {
+	if fold {
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if folded, _, err := transform.String(t, text); err == nil {
+			return folded
+		}
+		// Fall back to plain NFC if the transform chain fails.
+	}
+	return norm.NFC.String(text)
+}
+
+// normalizeReader applies the same normalization as normalizeText to a
+// stream, for the streaming feature extractor. Unlike normalizeText
+// there is no silent fallback: transform failures surface as read
+// errors.
+func normalizeReader(r io.Reader, fold bool) io.Reader {
+	if fold {
+		return transform.NewReader(r, transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC))
+	}
+	return transform.NewReader(r, norm.NFC)
+}
@@ -0,0 +1,37 @@
+package semantic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeNormalizesUnicodeForms(t *testing.T) {
+	composed := "caf\u00e9 culture"    // é as one rune
+	decomposed := "cafe\u0301 culture" // e + combining acute
+	a := Tokenize(composed)
+	b := Tokenize(decomposed)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("tokens differ across normalization forms: %q vs %q", a, b)
+	}
+
+	fa := ExtractFeatures([]byte(composed))
+	fb := ExtractFeatures([]byte(decomposed))
+	if sim := Similarity(fa, fb, DefaultKernelParams()); sim < 0.999 {
+		t.Fatalf("similarity across normalization forms = %f, want ~1.0", sim)
+	}
+}
+
+func TestFoldDiacritics(t *testing.T) {
+	defer func(prev bool) { FoldDiacritics = prev }(FoldDiacritics)
+
+	FoldDiacritics = false
+	if reflect.DeepEqual(Tokenize("naïve"), Tokenize("naive")) {
+		t.Fatal("diacritics folded with the flag off")
+	}
+
+	FoldDiacritics = true
+	if !reflect.DeepEqual(Tokenize("naïve"), Tokenize("naive")) {
+		t.Fatalf("Tokenize(naïve) = %q with folding on, want %q",
+			Tokenize("naïve"), Tokenize("naive"))
+	}
+}
@@ -0,0 +1,261 @@
+package semantic
+
+// An implementation of the classic Porter (1980) suffix-stripping
+// algorithm, so morphological variants ("learning", "learns",
+// "learned") collapse to one stem before TF computation. Self-contained
+// on purpose: pulling in a stemming dependency for ~150 lines of
+// well-specified algorithm is not worth the supply-chain surface.
+
+// Stem reduces an English word to its Porter stem. Words shorter than
+// three letters, and words containing non-ASCII-letter characters, are
+// returned unchanged.
+func Stem(word string) string {
+	if len(word) < 3 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	b := []byte(word)
+	b = stemStep1a(b)
+	b = stemStep1b(b)
+	b = stemStep1c(b)
+	b = stemStep2(b)
+	b = stemStep3(b)
+	b = stemStep4(b)
+	b = stemStep5(b)
+	return string(b)
+}
+
+// StemmingTokenizer tokenizes text like Tokenize and then stems each
+// token.
+func StemmingTokenizer(text string) []string {
+	tokens := Tokenize(text)
+	for i, tok := range tokens {
+		tokens[i] = Stem(tok)
+	}
+	return tokens
+}
+
+// isConsonant implements Porter's consonant definition: a letter other
+// than a, e, i, o, u, where y counts as a vowel when preceded by a
+// consonant.
+func isConsonant(b []byte, i int) bool {
+	switch b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(b, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences of b[:end], Porter's m.
+func measure(b []byte, end int) int {
+	m := 0
+	i := 0
+	for i < end && isConsonant(b, i) {
+		i++
+	}
+	for i < end {
+		for i < end && !isConsonant(b, i) {
+			i++
+		}
+		if i >= end {
+			break
+		}
+		m++
+		for i < end && isConsonant(b, i) {
+			i++
+		}
+	}
+	return m
+}
+
+// hasVowel reports whether b[:end] contains a vowel.
+func hasVowel(b []byte, end int) bool {
+	for i := 0; i < end; i++ {
+		if !isConsonant(b, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsDoubleConsonant reports whether b ends with the same consonant
+// twice.
+func endsDoubleConsonant(b []byte) bool {
+	n := len(b)
+	return n >= 2 && b[n-1] == b[n-2] && isConsonant(b, n-1)
+}
+
+// endsCVC reports whether b[:end] ends consonant-vowel-consonant with
+// the final consonant not w, x or y.
+func endsCVC(b []byte, end int) bool {
+	if end < 3 {
+		return false
+	}
+	if !isConsonant(b, end-3) || isConsonant(b, end-2) || !isConsonant(b, end-1) {
+		return false
+	}
+	switch b[end-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// hasSuffix reports whether b ends with suffix.
+func hasSuffix(b []byte, suffix string) bool {
+	return len(b) >= len(suffix) && string(b[len(b)-len(suffix):]) == suffix
+}
+
+// replaceSuffix swaps a trailing suffix for its replacement when the
+// stem before the suffix has measure greater than minMeasure. It
+// reports whether the suffix matched at all (not whether it was
+// replaced), so callers can stop at the first matching rule.
+func replaceSuffix(b []byte, suffix, replacement string, minMeasure int) ([]byte, bool) {
+	if !hasSuffix(b, suffix) {
+		return b, false
+	}
+	stem := len(b) - len(suffix)
+	if measure(b, stem) > minMeasure {
+		b = append(b[:stem], replacement...)
+	}
+	return b, true
+}
+
+func stemStep1a(b []byte) []byte {
+	switch {
+	case hasSuffix(b, "sses"):
+		return b[:len(b)-2]
+	case hasSuffix(b, "ies"):
+		return b[:len(b)-2]
+	case hasSuffix(b, "ss"):
+		return b
+	case hasSuffix(b, "s"):
+		return b[:len(b)-1]
+	}
+	return b
+}
+
+func stemStep1b(b []byte) []byte {
+	if hasSuffix(b, "eed") {
+		if measure(b, len(b)-3) > 0 {
+			return b[:len(b)-1]
+		}
+		return b
+	}
+	stem := -1
+	if hasSuffix(b, "ed") && hasVowel(b, len(b)-2) {
+		stem = len(b) - 2
+	} else if hasSuffix(b, "ing") && hasVowel(b, len(b)-3) {
+		stem = len(b) - 3
+	}
+	if stem < 0 {
+		return b
+	}
+	b = b[:stem]
+	switch {
+	case hasSuffix(b, "at"), hasSuffix(b, "bl"), hasSuffix(b, "iz"):
+		return append(b, 'e')
+	case endsDoubleConsonant(b):
+		switch b[len(b)-1] {
+		case 'l', 's', 'z':
+			return b
+		}
+		return b[:len(b)-1]
+	case measure(b, len(b)) == 1 && endsCVC(b, len(b)):
+		return append(b, 'e')
+	}
+	return b
+}
+
+func stemStep1c(b []byte) []byte {
+	if hasSuffix(b, "y") && hasVowel(b, len(b)-1) {
+		b[len(b)-1] = 'i'
+	}
+	return b
+}
+
+// step2Rules through step4Rules pair each suffix with its replacement;
+// order matters, longest or most specific first, exactly as in the
+// published algorithm.
+var step2Rules = []struct{ suffix, replacement string }{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"},
+	{"anci", "ance"}, {"izer", "ize"}, {"abli", "able"},
+	{"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+	{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"},
+	{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"},
+	{"biliti", "ble"},
+}
+
+var step3Rules = []struct{ suffix, replacement string }{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+var step4Rules = []struct{ suffix, replacement string }{
+	{"al", ""}, {"ance", ""}, {"ence", ""}, {"er", ""}, {"ic", ""},
+	{"able", ""}, {"ible", ""}, {"ant", ""}, {"ement", ""},
+	{"ment", ""}, {"ent", ""}, {"ou", ""}, {"ism", ""}, {"ate", ""},
+	{"iti", ""}, {"ous", ""}, {"ive", ""}, {"ize", ""},
+}
+
+func stemStep2(b []byte) []byte {
+	for _, rule := range step2Rules {
+		if out, matched := replaceSuffix(b, rule.suffix, rule.replacement, 0); matched {
+			return out
+		}
+	}
+	return b
+}
+
+func stemStep3(b []byte) []byte {
+	for _, rule := range step3Rules {
+		if out, matched := replaceSuffix(b, rule.suffix, rule.replacement, 0); matched {
+			return out
+		}
+	}
+	return b
+}
+
+func stemStep4(b []byte) []byte {
+	for _, rule := range step4Rules {
+		if !hasSuffix(b, rule.suffix) {
+			continue
+		}
+		stem := len(b) - len(rule.suffix)
+		if measure(b, stem) > 1 {
+			return b[:stem]
+		}
+		return b
+	}
+	// "ion" has its own condition: it only drops after s or t.
+	if hasSuffix(b, "ion") {
+		stem := len(b) - 3
+		if stem > 0 && (b[stem-1] == 's' || b[stem-1] == 't') && measure(b, stem) > 1 {
+			return b[:stem]
+		}
+	}
+	return b
+}
+
+func stemStep5(b []byte) []byte {
+	// Step 5a: drop a final e when the stem is long enough.
+	if hasSuffix(b, "e") {
+		stem := len(b) - 1
+		m := measure(b, stem)
+		if m > 1 || (m == 1 && !endsCVC(b, stem)) {
+			b = b[:stem]
+		}
+	}
+	// Step 5b: reduce a final double l.
+	if hasSuffix(b, "ll") && measure(b, len(b)) > 1 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+package semantic
+
+import "testing"
+
+func TestStemCollapsesVariants(t *testing.T) {
+	for _, tc := range []struct{ word, stem string }{
+		{"running", "run"},
+		{"runs", "run"},
+		{"ran", "ran"},
+		{"learning", "learn"},
+		{"learned", "learn"},
+		{"machines", "machin"},
+		{"machine", "machin"},
+		{"relational", "relat"},
+		{"caresses", "caress"},
+		{"cats", "cat"},
+		{"by", "by"},
+	} {
+		if got := Stem(tc.word); got != tc.stem {
+			t.Errorf("Stem(%q) = %q, want %q", tc.word, got, tc.stem)
+		}
+	}
+}
+
+func TestStemLeavesNonASCIIAlone(t *testing.T) {
+	if got := Stem("naïveté"); got != "naïveté" {
+		t.Fatalf("Stem mangled non-ASCII word: %q", got)
+	}
+}
+
+func TestStemmingTokenizer(t *testing.T) {
+	got := StemmingTokenizer("running runs ran")
+	want := []string{"run", "run", "ran"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("token %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStemmingRaisesSimilarity(t *testing.T) {
+	a := []byte("machine learning")
+	b := []byte("learn about machines")
+	params := DefaultKernelParams()
+
+	plain := Similarity(ExtractFeatures(a), ExtractFeatures(b), params)
+
+	cfg := DefaultFeatureConfig()
+	cfg.Stemming = true
+	stemmed := Similarity(ExtractFeaturesWith(a, cfg), ExtractFeaturesWith(b, cfg), params)
+
+	if stemmed <= plain {
+		t.Fatalf("stemming did not raise similarity: %f (stemmed) vs %f (plain)",
+			stemmed, plain)
+	}
+}
@@ -0,0 +1,191 @@
+package semantic
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ExtractFeaturesReader is ExtractFeatures for content too large to
+// buffer: it consumes r once, building the TF map and n-gram set
+// incrementally in memory bounded by the vocabulary rather than the
+// content. Tokens and n-grams that straddle read boundaries are
+// handled correctly; the result is identical to ExtractFeatures on the
+// fully buffered equivalent.
+func ExtractFeaturesReader(r io.Reader) (*Features, error) {
+	cfg := DefaultFeatureConfig()
+
+	// The n-gram and character paths read through the same Unicode
+	// normalizer as the token path, exactly mirroring the buffered
+	// extractor; a tee feeds both from one pass.
+	ng := newNgramStream(cfg.NgramSize)
+	normalized := bufio.NewReader(io.TeeReader(normalizeReader(r, FoldDiacritics), ng))
+
+	tf := make(map[string]float64)
+	words, tokenLen := 0, 0
+	var current strings.Builder
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		tok := current.String()
+		if cfg.Stemming {
+			tok = Stem(tok)
+		}
+		tf[tok]++
+		words++
+		tokenLen += utf8.RuneCountInString(tok)
+		current.Reset()
+	}
+	for {
+		r, _, err := normalized.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r = unicode.ToLower(r)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			current.WriteRune(r)
+		} else {
+			flush()
+		}
+	}
+	flush()
+	ng.finish()
+
+	if words > 0 {
+		for tok := range tf {
+			tf[tok] /= float64(words)
+		}
+	}
+
+	avg := 0.0
+	if words > 0 {
+		avg = float64(tokenLen) / float64(words)
+	}
+	f := &Features{
+		TFIDF:       tf,
+		Ngrams:      ng.grams,
+		NgramCounts: ng.counts,
+		TopKeywords: getTopKeywords(tf, cfg.TopKeywords),
+		CharCount:   ng.bytes,
+		WordCount:   words,
+		UniqueWords: len(tf),
+		CharFreq:    ng.freq,
+		Entropy:     charEntropy(ng.freq),
+		AvgWordLen:  avg,
+		NgramSize:   cfg.NgramSize,
+		Tail:        ng.tail(),
+	}
+	if lang, conf := detectLanguageGrams(ng.grams); conf >= minLanguageConfidence {
+		f.Language = lang
+	}
+	return f, nil
+}
+
+// ngramStream accumulates the GenerateNgrams result incrementally: it
+// lowercases, collapses whitespace runs to single spaces, and slides a
+// rune window of width n over the result, holding partial UTF-8
+// sequences across writes.
+type ngramStream struct {
+	n        int
+	grams    map[string]bool
+	counts   map[string]int
+	window   []rune
+	emitted  int
+	pending  bool // a space is owed before the next non-space rune
+	leftover []byte
+	bytes    int
+	freq     map[string]int // raw rune counts, for the entropy feature
+}
+
+func newNgramStream(n int) *ngramStream {
+	return &ngramStream{
+		n:      n,
+		grams:  make(map[string]bool),
+		counts: make(map[string]int),
+		freq:   make(map[string]int),
+	}
+}
+
+// Write consumes a chunk of raw content; it never fails, satisfying
+// io.Writer for use under io.TeeReader.
+func (s *ngramStream) Write(p []byte) (int, error) {
+	s.bytes += len(p)
+	data := p
+	if len(s.leftover) > 0 {
+		data = append(s.leftover, p...)
+		s.leftover = nil
+	}
+	for len(data) > 0 {
+		if !utf8.FullRune(data) && len(data) < utf8.UTFMax {
+			// A rune split across writes; wait for the rest.
+			s.leftover = append([]byte(nil), data...)
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		s.freq[string(r)]++
+		s.pushRune(r)
+		data = data[size:]
+	}
+	return len(p), nil
+}
+
+// pushRune feeds one content rune through whitespace collapsing into
+// the sliding window.
+func (s *ngramStream) pushRune(r rune) {
+	if unicode.IsSpace(r) {
+		if s.emitted > 0 {
+			s.pending = true
+		}
+		return
+	}
+	if s.pending {
+		s.pending = false
+		s.slide(' ')
+	}
+	s.slide(unicode.ToLower(r))
+}
+
+// slide appends one normalized rune to the window and records a gram
+// once the window is full.
+func (s *ngramStream) slide(r rune) {
+	s.emitted++
+	s.window = append(s.window, r)
+	if len(s.window) > s.n {
+		s.window = s.window[1:]
+	}
+	if len(s.window) == s.n {
+		s.grams[string(s.window)] = true
+		s.counts[string(s.window)]++
+	}
+}
+
+// finish flushes end-of-stream state: any dangling partial UTF-8 bytes
+// decode as replacement runes (matching what string conversion does),
+// and content shorter than the window width contributes itself as a
+// single gram, as GenerateNgrams does.
+// tail returns the trailing window of normalized runes, matching the
+// Tail field ExtractFeatures records. Call after finish.
+func (s *ngramStream) tail() string {
+	if len(s.window) == s.n {
+		return string(s.window[1:])
+	}
+	return string(s.window)
+}
+
+func (s *ngramStream) finish() {
+	for range s.leftover {
+		s.freq[string(utf8.RuneError)]++
+		s.pushRune(utf8.RuneError)
+	}
+	s.leftover = nil
+	if s.emitted > 0 && s.emitted < s.n {
+		s.grams[string(s.window)] = true
+		s.counts[string(s.window)] = 1
+	}
+}
@@ -0,0 +1,49 @@
+package semantic
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestExtractFeaturesReaderMatchesBuffered(t *testing.T) {
+	samples := []string{
+		"The quick brown fox jumps over the lazy dog and the rest of " +
+			"the animals in the field are watching the show.",
+		"tabs\tand\nnewlines   and   runs of spaces",
+		"multi-byte runes: straße, naïve, 日本語のテキスト mixed in",
+		"ab", // shorter than the n-gram width
+		"",
+	}
+	for _, sample := range samples {
+		want := ExtractFeatures([]byte(sample))
+
+		// One byte at a time forces every token, n-gram and rune to
+		// straddle a read boundary.
+		got, err := ExtractFeaturesReader(iotest.OneByteReader(strings.NewReader(sample)))
+		if err != nil {
+			t.Fatalf("ExtractFeaturesReader(%q): %v", sample, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("streamed features differ from buffered for %q:\ngot  %+v\nwant %+v",
+				sample, got, want)
+		}
+
+		got, err = ExtractFeaturesReader(bytes.NewReader([]byte(sample)))
+		if err != nil {
+			t.Fatalf("ExtractFeaturesReader(%q): %v", sample, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("full-buffer streamed features differ for %q", sample)
+		}
+	}
+}
+
+func TestExtractFeaturesReaderPropagatesErrors(t *testing.T) {
+	broken := iotest.TimeoutReader(strings.NewReader("some content to read"))
+	if _, err := ExtractFeaturesReader(iotest.OneByteReader(broken)); err == nil {
+		t.Fatal("read error swallowed")
+	}
+}
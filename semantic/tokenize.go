@@ -0,0 +1,82 @@
+package semantic
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in token-preservation patterns. They run against normalized,
+// lowercased text, so case variants need no alternation.
+var (
+	decimalPattern = regexp.MustCompile(`\d+\.\d+`)
+	urlPattern     = regexp.MustCompile(`https?://[^\s]+`)
+	emailPattern   = regexp.MustCompile(`[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`)
+)
+
+// TokenizerConfig tunes tokenization for content where splitting on
+// every non-alphanumeric destroys meaning — "3.14" into "3" and "14",
+// "example.com" into fragments. The zero value reproduces Tokenize
+// exactly.
+type TokenizerConfig struct {
+	// KeepDecimals keeps decimal numbers like "3.14" as single tokens
+	// instead of splitting at the point.
+	KeepDecimals bool
+	// KeepURLs keeps http(s) URLs and email addresses as single
+	// tokens.
+	KeepURLs bool
+	// Patterns are additional rules whose matches are kept as single
+	// tokens; they run against the normalized, lowercased text.
+	Patterns []*regexp.Regexp
+}
+
+// rules collects the active preservation patterns.
+func (c TokenizerConfig) rules() []*regexp.Regexp {
+	var rules []*regexp.Regexp
+	if c.KeepDecimals {
+		rules = append(rules, decimalPattern)
+	}
+	if c.KeepURLs {
+		rules = append(rules, urlPattern, emailPattern)
+	}
+	return append(rules, c.Patterns...)
+}
+
+// TokenizeWith is Tokenize under an explicit config: spans matched by
+// a preservation rule become single tokens, kept verbatim after
+// lowercasing, and the text between them splits as usual. Overlapping
+// matches resolve to the earliest, longest span, so a decimal inside
+// a kept URL does not split it.
+func TokenizeWith(text string, cfg TokenizerConfig) []string {
+	rules := cfg.rules()
+	if len(rules) == 0 {
+		return Tokenize(text)
+	}
+
+	normalized := strings.ToLower(normalizeText(text, FoldDiacritics))
+	type span struct{ start, end int }
+	var spans []span
+	for _, re := range rules {
+		for _, m := range re.FindAllStringIndex(normalized, -1) {
+			spans = append(spans, span{m[0], m[1]})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	var tokens []string
+	prev := 0
+	for _, sp := range spans {
+		if sp.start < prev {
+			continue // inside a span already kept
+		}
+		tokens = append(tokens, Tokenize(normalized[prev:sp.start])...)
+		tokens = append(tokens, normalized[sp.start:sp.end])
+		prev = sp.end
+	}
+	return append(tokens, Tokenize(normalized[prev:])...)
+}
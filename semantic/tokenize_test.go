@@ -0,0 +1,68 @@
+package semantic
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestTokenizeWithZeroConfigMatchesTokenize(t *testing.T) {
+	text := "version 3.14 at http://example.com"
+	if got, want := TokenizeWith(text, TokenizerConfig{}), Tokenize(text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("TokenizeWith zero config = %v, want %v", got, want)
+	}
+}
+
+func TestTokenizeKeepsDecimals(t *testing.T) {
+	got := TokenizeWith("version 3.14", TokenizerConfig{KeepDecimals: true})
+	want := []string{"version", "3.14"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// Default behavior still fragments.
+	if got := Tokenize("version 3.14"); !reflect.DeepEqual(got, []string{"version", "3", "14"}) {
+		t.Fatalf("Tokenize changed: %v", got)
+	}
+}
+
+func TestTokenizeKeepsURLsAndEmails(t *testing.T) {
+	cfg := TokenizerConfig{KeepURLs: true}
+	got := TokenizeWith("visit http://example.com", cfg)
+	want := []string{"visit", "http://example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	got = TokenizeWith("mail Bob@Example.COM today", cfg)
+	want = []string{"mail", "bob@example.com", "today"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTokenizeOverlappingRulesKeepLongest(t *testing.T) {
+	// The decimal inside the URL must not split it.
+	cfg := TokenizerConfig{KeepDecimals: true, KeepURLs: true}
+	got := TokenizeWith("see https://example.com/v1.2 now", cfg)
+	want := []string{"see", "https://example.com/v1.2", "now"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTokenizeCustomPattern(t *testing.T) {
+	cfg := TokenizerConfig{Patterns: []*regexp.Regexp{regexp.MustCompile(`[a-z]+-[0-9]+`)}}
+	got := TokenizeWith("ticket TERA-42 is open", cfg)
+	want := []string{"ticket", "tera-42", "is", "open"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestFeatureConfigTokenizer(t *testing.T) {
+	cfg := DefaultFeatureConfig()
+	cfg.Tokenizer.KeepDecimals = true
+	f := ExtractFeaturesWith([]byte("version 3.14 of the spec"), cfg)
+	if _, ok := f.TFIDF["3.14"]; !ok {
+		t.Fatalf("decimal token lost: %v", f.TFIDF)
+	}
+}
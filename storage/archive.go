@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// Archive format: a JSON stream opened by a self-describing header,
+// followed by one entry per line. Blocks are written before the
+// extension records that reference them, so Import can re-verify each
+// record against its delta as it arrives.
+
+const (
+	archiveFormat  = "tera-archive"
+	archiveVersion = 1
+)
+
+// archiveHeader identifies the stream so Import can reject files that
+// are not archives (or came from a future format).
+type archiveHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// archiveEntry is one element of the stream: exactly one of Block or
+// Extension is set.
+type archiveEntry struct {
+	Block     *archiveBlock    `json:"block,omitempty"`
+	Extension *ExtensionRecord `json:"extension,omitempty"`
+}
+
+// archiveBlock carries a block's identity and uncompressed data;
+// compression is a property of the receiving store, not the archive.
+type archiveBlock struct {
+	Hash     string `json:"hash"`
+	Data     []byte `json:"data"`
+	Segments []int  `json:"segments,omitempty"`
+}
+
+// Export writes a self-describing archive of every block and extension
+// record reachable from roots (following children links, plus the
+// delta blocks those records reference) so the content can be moved
+// to another node offline and imported with Import.
+func (s *Store) Export(roots []*crypto.Hash, w io.Writer) error {
+	var records []*ExtensionRecord
+	blockHashes := append([]*crypto.Hash(nil), roots...)
+	seen := make(map[string]bool)
+
+	queue := append([]*crypto.Hash(nil), roots...)
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if seen[h.Hex()] {
+			continue
+		}
+		seen[h.Hex()] = true
+		children, err := s.Graph.GetChildren(h)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			rec, err := s.Graph.GetExtension(child)
+			if IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			records = append(records, rec)
+			if rec.DeltaHash != "" {
+				deltaHash, err := crypto.FromHex(rec.DeltaHash)
+				if err != nil {
+					return fmt.Errorf("corrupt delta reference %q: %w", rec.DeltaHash, err)
+				}
+				blockHashes = append(blockHashes, deltaHash)
+			}
+		}
+		queue = append(queue, children...)
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(archiveHeader{Format: archiveFormat, Version: archiveVersion}); err != nil {
+		return err
+	}
+	written := make(map[string]bool)
+	for _, h := range blockHashes {
+		if written[h.Hex()] {
+			continue
+		}
+		written[h.Hex()] = true
+		block, err := s.Blocks.Get(h)
+		if IsNotFound(err) {
+			// Chain tips are virtual: reachable as hashes but backed
+			// by records and deltas, not a block of their own.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entry := archiveEntry{Block: &archiveBlock{
+			Hash:     block.Hash,
+			Data:     block.Data,
+			Segments: block.Segments,
+		}}
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	for _, rec := range records {
+		if err := enc.Encode(archiveEntry{Extension: rec}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import loads an archive written by Export. Every block is checked
+// against its declared hash and every extension record is re-verified
+// against its delta before anything from it becomes visible; an
+// archive containing an invalid entry is rejected mid-stream, so
+// callers should import into a scratch store if partial loads matter.
+func (s *Store) Import(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	var header archiveHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("read archive header: %w", err)
+	}
+	if header.Format != archiveFormat {
+		return fmt.Errorf("not a %s stream (format %q)", archiveFormat, header.Format)
+	}
+	if header.Version != archiveVersion {
+		return fmt.Errorf("unsupported archive version %d", header.Version)
+	}
+
+	for {
+		var entry archiveEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read archive entry: %w", err)
+		}
+		switch {
+		case entry.Block != nil:
+			if err := s.importBlock(entry.Block); err != nil {
+				return err
+			}
+		case entry.Extension != nil:
+			if err := s.importExtension(entry.Extension); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("archive entry is neither block nor extension")
+		}
+	}
+}
+
+// importBlock verifies the archived data against its declared hash and
+// stores it under the receiving store's compression and TTL settings.
+func (s *Store) importBlock(ab *archiveBlock) error {
+	hash, err := crypto.FromHex(ab.Hash)
+	if err != nil {
+		return fmt.Errorf("corrupt block hash %q: %w", ab.Hash, err)
+	}
+	check := &Block{Data: ab.Data, Segments: ab.Segments}
+	contentHash, err := check.ContentHash()
+	if err != nil {
+		return fmt.Errorf("block %s: %w", ab.Hash, err)
+	}
+	if !contentHash.Equal(hash) {
+		return fmt.Errorf("block %s does not match its data", ab.Hash)
+	}
+	_, err = s.Blocks.putBlock(hash, ab.Data, ab.Segments, s.Blocks.ttl)
+	return err
+}
+
+// importExtension re-verifies the homomorphic link of an archived
+// record against its delta (which Export writes before the record)
+// and adds it to the graph.
+func (s *Store) importExtension(rec *ExtensionRecord) error {
+	parent, err := crypto.FromHex(rec.Parent)
+	if err != nil {
+		return fmt.Errorf("corrupt extension record: %w", err)
+	}
+	child, err := crypto.FromHex(rec.Child)
+	if err != nil {
+		return fmt.Errorf("corrupt extension record: %w", err)
+	}
+	// Records arrive parent-first, so a coded delta's parent content is
+	// reconstructable from what has already been imported.
+	var parentData []byte
+	if rec.Codec != "" && !parent.Equal(crypto.Zero()) {
+		parentData, err = s.Reconstruct(parent)
+		if err != nil {
+			return fmt.Errorf("archived extension %s: parent content: %w", rec.Child, err)
+		}
+	}
+	delta, err := resolveRecordDelta(s.Blocks, rec, parentData)
+	if err != nil {
+		return fmt.Errorf("archived extension %s: %w", rec.Child, err)
+	}
+	if !crypto.Extend(parent, delta).Equal(child) {
+		return fmt.Errorf("archived extension %s does not verify against its delta", rec.Child)
+	}
+	return s.Graph.AddExtension(rec)
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	a := newTestStore(t)
+	root := core.NewContent([]byte("archive base"))
+	if _, err := a.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	tip := root.Hash
+	want := string(root.Data)
+	for _, delta := range []string{" one", " two", " three"} {
+		ext := core.NewExtension(tip, []byte(delta))
+		if err := a.PutExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+		tip = ext.Child
+		want += delta
+	}
+	// Content outside the exported roots must stay behind.
+	if _, err := a.PutContent([]byte("unrelated content")); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := a.Export([]*crypto.Hash{root.Hash}, &archive); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	b := newTestStore(t)
+	if err := b.Import(&archive); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	data, err := b.Reconstruct(tip)
+	if err != nil {
+		t.Fatalf("Reconstruct after import: %v", err)
+	}
+	if string(data) != want {
+		t.Fatalf("imported content %q, want %q", data, want)
+	}
+	if has, err := b.Blocks.Has(crypto.HashElement([]byte("unrelated content"))); err != nil || has {
+		t.Fatalf("unreachable block leaked into archive (has %v, err %v)", has, err)
+	}
+}
+
+func TestImportRejectsInvalidExtension(t *testing.T) {
+	a := newTestStore(t)
+	root := core.NewContent([]byte("tamper base"))
+	if _, err := a.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	ext := core.NewExtension(root.Hash, []byte(" tail"))
+	if err := a.PutExtension(ext); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := a.Export([]*crypto.Hash{root.Hash}, &archive); err != nil {
+		t.Fatal(err)
+	}
+	// Point the record's child somewhere the delta cannot produce.
+	tampered := strings.Replace(archive.String(),
+		ext.Child.Hex(), crypto.HashElement([]byte("elsewhere")).Hex(), 1)
+
+	b := newTestStore(t)
+	if err := b.Import(strings.NewReader(tampered)); err == nil {
+		t.Fatal("Import accepted an archive with an invalid extension")
+	}
+}
+
+func TestImportRejectsNonArchive(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Import(strings.NewReader(`{"format":"something-else","version":1}`)); err == nil {
+		t.Fatal("Import accepted a non-archive stream")
+	}
+}
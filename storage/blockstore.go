@@ -0,0 +1,412 @@
+// Package storage persists content blocks and the extension graph in
+// BadgerDB, keyed by the homomorphic crypto hash. It can reconstruct
+// and re-verify any chain it holds, which is what lets a node serve
+// content it merely relayed.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/semantic"
+)
+
+// Key prefixes partition the single badger keyspace.
+const (
+	prefixBlock     = "block:"
+	prefixExtension = "ext:"
+	prefixChildren  = "children:"
+	prefixPublisher = "pub:"
+	prefixTime      = "time:"
+	prefixSemantic  = "sem:"
+	prefixDeleted   = "del:"
+)
+
+// ErrNotFound is returned when a block or extension record does not
+// exist.
+var ErrNotFound = errors.New("storage: not found")
+
+// ErrContentDeleted is returned when content exists but has been
+// tombstoned by its publisher.
+var ErrContentDeleted = errors.New("storage: content deleted")
+
+// ErrBlockCorrupted is returned by verified reads when a block's bytes
+// no longer hash to its key — bit rot or an on-disk edit.
+var ErrBlockCorrupted = errors.New("storage: block data does not match its hash")
+
+// Block is a unit of stored content. Data is the raw bytes whose
+// crypto hash is the block's key.
+type Block struct {
+	Hash      string `json:"hash"`
+	Data      []byte `json:"data"`
+	Size      int    `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+	// Compressed names the codec Data was stored with, so blocks
+	// written under one Config remain readable under another.
+	Compressed Compression `json:"compressed,omitempty"`
+	// Segments holds the byte lengths of the homomorphically hashed
+	// segments for blocks written by Store.Compact: the block's hash
+	// is the combined hash of the segments, not of Data as a single
+	// element. Empty for ordinary blocks.
+	Segments []int `json:"segments,omitempty"`
+}
+
+// ContentHash returns the crypto hash Data should verify against:
+// the single-element hash for ordinary blocks, or the combined
+// segment hashes for compacted blocks.
+func (b *Block) ContentHash() (*crypto.Hash, error) {
+	if len(b.Segments) == 0 {
+		return crypto.HashElement(b.Data), nil
+	}
+	acc := crypto.Zero()
+	offset := 0
+	for _, n := range b.Segments {
+		if n < 0 || offset+n > len(b.Data) {
+			return nil, fmt.Errorf("block %s: segment lengths exceed data", b.Hash)
+		}
+		acc = crypto.Combine(acc, crypto.HashElement(b.Data[offset:offset+n]))
+		offset += n
+	}
+	if offset != len(b.Data) {
+		return nil, fmt.Errorf("block %s: segment lengths cover %d of %d bytes", b.Hash, offset, len(b.Data))
+	}
+	return acc, nil
+}
+
+// BlockStore is the content-addressed block layer over badger.
+type BlockStore struct {
+	db          *badger.DB
+	compression Compression
+	// ttl expires new blocks after this duration; zero stores them
+	// forever.
+	ttl time.Duration
+	// verifyOnRead makes every Get recheck that the block's bytes hash
+	// to its key, surfacing ErrBlockCorrupted instead of bad data.
+	verifyOnRead bool
+}
+
+// setWithTTL writes key=value, expiring after ttl when ttl > 0.
+func setWithTTL(txn *badger.Txn, key, value []byte, ttl time.Duration) error {
+	entry := badger.NewEntry(key, value)
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+	return txn.SetEntry(entry)
+}
+
+// NewBlockStore wraps an open badger database; blocks are stored
+// uncompressed.
+func NewBlockStore(db *badger.DB) *BlockStore {
+	return &BlockStore{db: db}
+}
+
+func blockKey(h *crypto.Hash) []byte {
+	return []byte(prefixBlock + h.Hex())
+}
+
+func semanticKey(h *crypto.Hash) []byte {
+	return []byte(prefixSemantic + h.Hex())
+}
+
+// Put stores data under its crypto hash and returns the hash. Storing
+// the same data twice is a harmless overwrite.
+func (bs *BlockStore) Put(data []byte) (*crypto.Hash, error) {
+	return bs.putBlock(crypto.HashElement(data), data, nil, bs.ttl)
+}
+
+// PutWithTTL is Put with a per-block expiry overriding the store's
+// configured TTL; zero stores the block forever.
+func (bs *BlockStore) PutWithTTL(data []byte, ttl time.Duration) (*crypto.Hash, error) {
+	return bs.putBlock(crypto.HashElement(data), data, nil, ttl)
+}
+
+// putBlock stores data under an explicit hash; segments, when
+// non-nil, record the homomorphic segment boundaries the hash was
+// combined from.
+func (bs *BlockStore) putBlock(hash *crypto.Hash, data []byte, segments []int, ttl time.Duration) (*crypto.Hash, error) {
+	stored, err := bs.compression.compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("compress block: %w", err)
+	}
+	block := &Block{
+		Hash:       hash.Hex(),
+		Data:       stored,
+		Size:       len(data),
+		CreatedAt:  time.Now().UnixNano(),
+		Compressed: bs.compression,
+		Segments:   segments,
+	}
+	value, err := json.Marshal(block)
+	if err != nil {
+		return nil, fmt.Errorf("marshal block: %w", err)
+	}
+	err = bs.db.Update(func(txn *badger.Txn) error {
+		_, getErr := txn.Get(blockKey(hash))
+		fresh := errors.Is(getErr, badger.ErrKeyNotFound)
+		if getErr != nil && !fresh {
+			return getErr
+		}
+		if err := setWithTTL(txn, blockKey(hash), value, ttl); err != nil {
+			return err
+		}
+		if !fresh {
+			// Same hash means same data, so counters and features are
+			// unchanged.
+			return nil
+		}
+		features, err := json.Marshal(semantic.ExtractFeatures(data))
+		if err != nil {
+			return fmt.Errorf("marshal features: %w", err)
+		}
+		if err := setWithTTL(txn, semanticKey(hash), features, ttl); err != nil {
+			return err
+		}
+		if err := bumpCounter(txn, counterBlockCount, 1); err != nil {
+			return err
+		}
+		return bumpCounter(txn, counterTotalSize, int64(len(data)))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store block: %w", err)
+	}
+	return hash, nil
+}
+
+// Get loads the block stored under hash.
+func (bs *BlockStore) Get(hash *crypto.Hash) (*Block, error) {
+	var block Block
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(blockKey(hash))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &block)
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load block: %w", err)
+	}
+	data, err := block.Compressed.decompress(block.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decompress block %s: %w", block.Hash, err)
+	}
+	block.Data = data
+	block.Compressed = CompressionNone
+	if bs.verifyOnRead {
+		if err := verifyBlock(&block, hash); err != nil {
+			return nil, err
+		}
+	}
+	return &block, nil
+}
+
+// verifyBlock rechecks that a loaded block's bytes hash to the key it
+// was requested under.
+func verifyBlock(block *Block, hash *crypto.Hash) error {
+	got, err := block.ContentHash()
+	if err != nil {
+		return fmt.Errorf("block %s: %w", block.Hash, ErrBlockCorrupted)
+	}
+	if !got.Equal(hash) {
+		return fmt.Errorf("block %s: %w", block.Hash, ErrBlockCorrupted)
+	}
+	return nil
+}
+
+// GetMany loads the blocks stored under hashes in a single read
+// transaction, keyed by hex hash. Missing blocks are omitted from the
+// result rather than failing the call, so callers can diff the map
+// against their request to see what needs fetching; duplicate hashes
+// are fetched once.
+func (bs *BlockStore) GetMany(hashes []*crypto.Hash) (map[string]*Block, error) {
+	blocks := make(map[string]*Block, len(hashes))
+	err := bs.db.View(func(txn *badger.Txn) error {
+		for _, hash := range hashes {
+			if _, ok := blocks[hash.Hex()]; ok {
+				continue
+			}
+			item, err := txn.Get(blockKey(hash))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var block Block
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &block)
+			}); err != nil {
+				return err
+			}
+			data, err := block.Compressed.decompress(block.Data)
+			if err != nil {
+				return fmt.Errorf("decompress block %s: %w", block.Hash, err)
+			}
+			block.Data = data
+			block.Compressed = CompressionNone
+			if bs.verifyOnRead {
+				if err := verifyBlock(&block, hash); err != nil {
+					return err
+				}
+			}
+			blocks[block.Hash] = &block
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// GetFeatures loads the semantic features persisted for a block at
+// Put time. Blocks written before features were stored return
+// ErrNotFound; see Store.blockFeatures for the lazy migration path.
+func (bs *BlockStore) GetFeatures(hash *crypto.Hash) (*semantic.Features, error) {
+	var features semantic.Features
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(semanticKey(hash))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &features)
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load features: %w", err)
+	}
+	return &features, nil
+}
+
+// PutFeatures persists features for an already-stored block.
+func (bs *BlockStore) PutFeatures(hash *crypto.Hash, features *semantic.Features) error {
+	value, err := json.Marshal(features)
+	if err != nil {
+		return fmt.Errorf("marshal features: %w", err)
+	}
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(semanticKey(hash), value)
+	})
+}
+
+// Has reports whether a block exists without loading its value.
+func (bs *BlockStore) Has(hash *crypto.Hash) (bool, error) {
+	err := bs.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(blockKey(hash))
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes a block. Deleting a missing block is not an error.
+func (bs *BlockStore) Delete(hash *crypto.Hash) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(blockKey(hash))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var block Block
+		err = item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &block)
+		})
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(blockKey(hash)); err != nil {
+			return err
+		}
+		if err := txn.Delete(semanticKey(hash)); err != nil {
+			return err
+		}
+		if err := bumpCounter(txn, counterBlockCount, -1); err != nil {
+			return err
+		}
+		return bumpCounter(txn, counterTotalSize, -int64(block.Size))
+	})
+}
+
+// ListPage returns up to limit block hashes in key order, starting
+// after the block whose hex hash is afterHex (pass "" for the first
+// page). nextCursor is the cursor for the following page, or "" when
+// the listing is exhausted.
+func (bs *BlockStore) ListPage(afterHex string, limit int) (hashes []*crypto.Hash, nextCursor string, err error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("invalid page limit %d", limit)
+	}
+	err = bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(prefixBlock)
+		it.Seek([]byte(prefixBlock + afterHex))
+		// Seek lands on the cursor key itself; the page starts after it.
+		if afterHex != "" && it.ValidForPrefix(prefix) &&
+			string(it.Item().Key()) == prefixBlock+afterHex {
+			it.Next()
+		}
+		for ; it.ValidForPrefix(prefix) && len(hashes) < limit; it.Next() {
+			hex := string(it.Item().Key()[len(prefix):])
+			h, err := crypto.FromHex(hex)
+			if err != nil {
+				return fmt.Errorf("corrupt block key %q: %w", hex, err)
+			}
+			hashes = append(hashes, h)
+		}
+		if it.ValidForPrefix(prefix) && len(hashes) > 0 {
+			nextCursor = hashes[len(hashes)-1].Hex()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return hashes, nextCursor, nil
+}
+
+// List returns the hashes of every stored block.
+func (bs *BlockStore) List() ([]*crypto.Hash, error) {
+	var hashes []*crypto.Hash
+	err := bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(prefixBlock)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			hex := string(it.Item().Key()[len(prefix):])
+			h, err := crypto.FromHex(hex)
+			if err != nil {
+				return fmt.Errorf("corrupt block key %q: %w", hex, err)
+			}
+			hashes = append(hashes, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
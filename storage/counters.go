@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Counter keys hold running totals so GetStats never has to scan the
+// keyspace. They are updated inside the same transactions that mutate
+// the data they count.
+const (
+	counterBlockCount     = "meta:block_count"
+	counterExtensionCount = "meta:extension_count"
+	counterTotalSize      = "meta:total_size"
+)
+
+// readCounter returns the counter's value, treating a missing key as
+// zero.
+func readCounter(txn *badger.Txn, key string) (int64, error) {
+	item, err := txn.Get([]byte(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var value int64
+	err = item.Value(func(val []byte) error {
+		if len(val) != 8 {
+			return errors.New("corrupt counter value")
+		}
+		value = int64(binary.BigEndian.Uint64(val))
+		return nil
+	})
+	return value, err
+}
+
+// setCounter writes the counter's value.
+func setCounter(txn *badger.Txn, key string, value int64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(value))
+	return txn.Set([]byte(key), buf[:])
+}
+
+// bumpCounter adds delta to the counter inside the current
+// transaction.
+func bumpCounter(txn *badger.Txn, key string, delta int64) error {
+	value, err := readCounter(txn, key)
+	if err != nil {
+		return err
+	}
+	return setCounter(txn, key, value+delta)
+}
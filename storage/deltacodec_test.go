@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrimDeltaCodecRoundTrip(t *testing.T) {
+	codec := TrimDeltaCodec{}
+	parent := []byte("the quick brown fox jumps over the lazy dog")
+	cases := [][]byte{
+		[]byte("the quick brown cat jumps over the lazy dog"), // small edit
+		[]byte("completely unrelated bytes"),
+		parent, // identical to parent
+		nil,    // empty delta
+	}
+	for i, delta := range cases {
+		encoded := codec.EncodeDelta(parent, delta)
+		decoded, err := codec.ApplyDelta(parent, encoded)
+		if err != nil {
+			t.Fatalf("case %d: ApplyDelta: %v", i, err)
+		}
+		if !bytes.Equal(decoded, delta) {
+			t.Fatalf("case %d: round trip produced %q, want %q", i, decoded, delta)
+		}
+	}
+
+	// The small edit should actually save space.
+	edited := cases[0]
+	if got := len(codec.EncodeDelta(parent, edited)); got >= len(edited) {
+		t.Fatalf("near-duplicate delta encoded to %d bytes, original %d", got, len(edited))
+	}
+
+	if _, err := codec.ApplyDelta([]byte("short"), codec.EncodeDelta(parent, parent)); err == nil {
+		t.Fatal("ApplyDelta accepted affix lengths exceeding the parent")
+	}
+}
+
+func TestDeltaCodecStoreRoundTrip(t *testing.T) {
+	s, err := NewStore(Config{InMemory: true, DeltaCodec: TrimDeltaCodec{}})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	original := []byte("a long paragraph about machine learning models in production")
+	root, err := s.PutContent(original)
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	// A delta that is a small edit of the parent, the case the codec
+	// exists for, followed by an ordinary append.
+	edited := []byte("a long paragraph about machine learning models in practice")
+	mid, err := s.ExtendContent(root, edited)
+	if err != nil {
+		t.Fatalf("ExtendContent: %v", err)
+	}
+	tip, err := s.ExtendContent(mid, []byte(" with a closing remark"))
+	if err != nil {
+		t.Fatalf("ExtendContent: %v", err)
+	}
+
+	rec, err := s.Graph.GetExtension(mid)
+	if err != nil {
+		t.Fatalf("GetExtension: %v", err)
+	}
+	if rec.Codec != "trim" || len(rec.CodedDelta) == 0 || rec.DeltaHash != "" {
+		t.Fatalf("record not delta-coded: %+v", rec)
+	}
+
+	want := append(append(append([]byte(nil), original...), edited...), []byte(" with a closing remark")...)
+	got, err := s.GetContent(tip)
+	if err != nil {
+		t.Fatalf("GetContent: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("reconstructed %q, want %q", got, want)
+	}
+
+	result, err := s.VerifyChainFromTarget(tip)
+	if err != nil {
+		t.Fatalf("VerifyChainFromTarget: %v", err)
+	}
+	if !result.Valid {
+		t.Fatalf("coded chain failed verification: %s", result.Reason)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Graph.ReconstructStream(s.Blocks, tip, &buf); err != nil {
+		t.Fatalf("ReconstructStream: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("streamed %q, want %q", buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// StoreEventType identifies which kind of write a StoreEvent reports.
+type StoreEventType string
+
+const (
+	// ContentPut fires when root content is stored; the hash is the
+	// content's.
+	ContentPut StoreEventType = "content_put"
+	// ExtensionAdded fires when an extension record is stored; the
+	// hash is the child's.
+	ExtensionAdded StoreEventType = "extension_added"
+	// ContentDeleted fires when content is tombstoned; the hash is
+	// the tombstoned content's.
+	ContentDeleted StoreEventType = "content_deleted"
+)
+
+// StoreEvent reports one committed store write to subscribers.
+type StoreEvent struct {
+	Type StoreEventType `json:"type"`
+	Hash *crypto.Hash   `json:"hash"`
+}
+
+// eventBuffer bounds each subscriber's channel. A subscriber that
+// falls this far behind starts losing events rather than blocking
+// writes.
+const eventBuffer = 64
+
+// subscriptions fans committed-write events out to subscriber
+// channels. Delivery happens inline on the writing goroutine — there
+// is no dispatcher goroutine to leak — so sends are non-blocking.
+type subscriptions struct {
+	mu   sync.Mutex
+	subs map[int]chan StoreEvent
+	next int
+}
+
+// subscribe registers a new subscriber and returns its channel with a
+// cancel function. The cancel closes the channel and is safe to call
+// more than once.
+func (p *subscriptions) subscribe() (<-chan StoreEvent, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.subs == nil {
+		p.subs = make(map[int]chan StoreEvent)
+	}
+	id := p.next
+	p.next++
+	ch := make(chan StoreEvent, eventBuffer)
+	p.subs[id] = ch
+	return ch, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.subs[id]; ok {
+			delete(p.subs, id)
+			close(ch)
+		}
+	}
+}
+
+// notify delivers one event to every subscriber. The lock is held
+// across the sends so a concurrent cancel cannot close a channel
+// mid-send; full channels drop the event instead of blocking.
+func (p *subscriptions) notify(t StoreEventType, hash *crypto.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ch := range p.subs {
+		select {
+		case ch <- StoreEvent{Type: t, Hash: hash}:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of store write events and a function
+// that cancels the subscription. Events are delivered after the write
+// commits; a subscriber that stops draining its channel loses events
+// once its buffer fills rather than blocking writes.
+func (s *Store) Subscribe() (<-chan StoreEvent, func()) {
+	return s.events.subscribe()
+}
@@ -0,0 +1,513 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// ExtensionRecord is the persisted form of one chain link: child is
+// parent extended by Delta.
+type ExtensionRecord struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+	// DeltaHash references the delta's content-addressed block, so
+	// identical deltas across extensions are stored once.
+	DeltaHash string `json:"delta_hash,omitempty"`
+	// Delta embeds the raw delta bytes in records written before
+	// DeltaHash existed; new records leave it empty.
+	Delta []byte `json:"delta,omitempty"`
+	// CodedDelta holds the delta re-encoded against the parent's
+	// content by the codec named in Codec, set instead of DeltaHash by
+	// stores configured with a delta codec. Decoding needs the parent
+	// content, so coded deltas resolve during chain walks rather than
+	// through recordDelta.
+	CodedDelta []byte `json:"coded_delta,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+	// Publisher is the peer ID that announced the extension; empty
+	// for locally created records that never crossed the network.
+	Publisher string `json:"publisher,omitempty"`
+	// Timestamp is when the extension was created, in unix
+	// nanoseconds; zero when unknown.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// ExtensionGraph stores chain links and a parent->children index so
+// chains can be walked in both directions.
+type ExtensionGraph struct {
+	db *badger.DB
+	// ttl expires new extension records after this duration; zero
+	// keeps them forever. The children and publisher indexes do not
+	// expire (they are shared lists); stale entries in them resolve
+	// to ErrNotFound records.
+	ttl time.Duration
+}
+
+// NewExtensionGraph wraps an open badger database.
+func NewExtensionGraph(db *badger.DB) *ExtensionGraph {
+	return &ExtensionGraph{db: db}
+}
+
+func extensionKey(childHex string) []byte {
+	return []byte(prefixExtension + childHex)
+}
+
+func childrenKey(parentHex string) []byte {
+	return []byte(prefixChildren + parentHex)
+}
+
+func publisherKey(publisher string) []byte {
+	return []byte(prefixPublisher + publisher)
+}
+
+// timeKey orders extensions by creation time: the zero-padded
+// nanosecond timestamp sorts lexicographically, and the child hash
+// suffix keeps keys unique within one nanosecond.
+func timeKey(timestamp int64, childHex string) []byte {
+	return []byte(fmt.Sprintf("%s%020d:%s", prefixTime, timestamp, childHex))
+}
+
+// recordDelta resolves a record's delta bytes: through the
+// content-addressed reference for current records, or straight from
+// the embedded copy for legacy ones.
+func recordDelta(bs *BlockStore, rec *ExtensionRecord) ([]byte, error) {
+	if rec.Codec != "" {
+		return nil, fmt.Errorf("extension %s: delta coded with %q needs the parent content to resolve", rec.Child, rec.Codec)
+	}
+	if rec.DeltaHash == "" {
+		return rec.Delta, nil
+	}
+	h, err := crypto.FromHex(rec.DeltaHash)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt delta reference %q: %w", rec.DeltaHash, err)
+	}
+	block, err := bs.Get(h)
+	if err != nil {
+		return nil, fmt.Errorf("delta block %s: %w", rec.DeltaHash, err)
+	}
+	return block.Data, nil
+}
+
+// appendToIndex adds value to the JSON string list stored at key if it
+// is not already present, reporting whether the list changed.
+func appendToIndex(txn *badger.Txn, key []byte, value string) (bool, error) {
+	var list []string
+	item, err := txn.Get(key)
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &list)
+		})
+		if err != nil {
+			return false, err
+		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return false, err
+	}
+	for _, v := range list {
+		if v == value {
+			return false, nil
+		}
+	}
+	list = append(list, value)
+	updated, err := json.Marshal(list)
+	if err != nil {
+		return false, err
+	}
+	return true, txn.Set(key, updated)
+}
+
+// removeFromIndex drops value from the JSON string list stored at
+// key, deleting the key when the list empties.
+func removeFromIndex(txn *badger.Txn, key []byte, value string) error {
+	var list []string
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &list)
+	}); err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, v := range list {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == len(list) {
+		return nil
+	}
+	if len(kept) == 0 {
+		return txn.Delete(key)
+	}
+	updated, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	return txn.Set(key, updated)
+}
+
+// DeleteExtension removes a link and its index entries. Deleting a
+// missing link is not an error.
+func (g *ExtensionGraph) DeleteExtension(child *crypto.Hash) error {
+	rec, err := g.GetExtension(child)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return g.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(extensionKey(rec.Child)); err != nil {
+			return err
+		}
+		if err := removeFromIndex(txn, childrenKey(rec.Parent), rec.Child); err != nil {
+			return err
+		}
+		if rec.Publisher != "" {
+			if err := removeFromIndex(txn, publisherKey(rec.Publisher), rec.Child); err != nil {
+				return err
+			}
+		}
+		if rec.Timestamp != 0 {
+			if err := txn.Delete(timeKey(rec.Timestamp, rec.Child)); err != nil {
+				return err
+			}
+		}
+		return bumpCounter(txn, counterExtensionCount, -1)
+	})
+}
+
+// AddExtension stores a link and updates the children index of its
+// parent and, when the record carries one, the publisher index.
+func (g *ExtensionGraph) AddExtension(rec *ExtensionRecord) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal extension record: %w", err)
+	}
+	return g.db.Update(func(txn *badger.Txn) error {
+		if err := setWithTTL(txn, extensionKey(rec.Child), value, g.ttl); err != nil {
+			return err
+		}
+		fresh, err := appendToIndex(txn, childrenKey(rec.Parent), rec.Child)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return nil // already indexed
+		}
+		if rec.Publisher != "" {
+			if _, err := appendToIndex(txn, publisherKey(rec.Publisher), rec.Child); err != nil {
+				return err
+			}
+		}
+		if rec.Timestamp != 0 {
+			if err := setWithTTL(txn, timeKey(rec.Timestamp, rec.Child), nil, g.ttl); err != nil {
+				return err
+			}
+		}
+		return bumpCounter(txn, counterExtensionCount, 1)
+	})
+}
+
+// GetByPublisher returns the child hashes of every extension announced
+// by publisher, in insertion order.
+func (g *ExtensionGraph) GetByPublisher(publisher string) ([]*crypto.Hash, error) {
+	var children []string
+	err := g.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(publisherKey(publisher))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &children)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]*crypto.Hash, 0, len(children))
+	for _, hex := range children {
+		h, err := crypto.FromHex(hex)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt publisher index: %w", err)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, nil
+}
+
+// GetByTimeRange returns the child hashes of extensions created in
+// [from, to] (both inclusive, unix nanoseconds), ordered oldest first.
+func (g *ExtensionGraph) GetByTimeRange(from, to int64) ([]*crypto.Hash, error) {
+	var hashes []*crypto.Hash
+	err := g.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(prefixTime)
+		// Every time key has the same shape, so the hash always starts
+		// at the same offset: "time:" + 20 digits + ":".
+		hexOffset := len(string(timeKey(0, "")))
+		end := string(timeKey(to, ""))
+		for it.Seek(timeKey(from, "")); it.ValidForPrefix(prefix); it.Next() {
+			key := string(it.Item().Key())
+			// Keys at timestamp `to` share end as a prefix and must be
+			// included; anything sorting past that prefix is out of range.
+			if key > end && key[:len(end)] != end {
+				break
+			}
+			hex := key[hexOffset:]
+			h, err := crypto.FromHex(hex)
+			if err != nil {
+				return fmt.Errorf("corrupt time index key %q: %w", key, err)
+			}
+			hashes = append(hashes, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// ForEachExtension calls fn for every stored extension record.
+// Iteration stops at the first error fn returns.
+func (g *ExtensionGraph) ForEachExtension(fn func(*ExtensionRecord) error) error {
+	return g.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(prefixExtension)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var rec ExtensionRecord
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &rec)
+			})
+			if err != nil {
+				return err
+			}
+			if err := fn(&rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetExtension loads the link that produced child.
+func (g *ExtensionGraph) GetExtension(child *crypto.Hash) (*ExtensionRecord, error) {
+	var rec ExtensionRecord
+	err := g.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(extensionKey(child.Hex()))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &rec)
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load extension record: %w", err)
+	}
+	return &rec, nil
+}
+
+// GetChildren returns the direct extensions of parent.
+func (g *ExtensionGraph) GetChildren(parent *crypto.Hash) ([]*crypto.Hash, error) {
+	var children []string
+	err := g.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(childrenKey(parent.Hex()))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &children)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]*crypto.Hash, 0, len(children))
+	for _, hex := range children {
+		h, err := crypto.FromHex(hex)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt children index: %w", err)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, nil
+}
+
+// GetChain walks from target back to the chain's root and returns the
+// records in root-to-target order. A hash with no extension record is
+// itself a root, yielding an empty chain.
+func (g *ExtensionGraph) GetChain(target *crypto.Hash) ([]*ExtensionRecord, error) {
+	var chain []*ExtensionRecord
+	current := target
+	for {
+		rec, err := g.GetExtension(current)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, rec)
+		parent, err := crypto.FromHex(rec.Parent)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt extension record: %w", err)
+		}
+		current = parent
+	}
+	// Reverse into root-to-target order.
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// GetRoot follows parent links from target until it reaches a hash
+// with no extension record.
+func (g *ExtensionGraph) GetRoot(target *crypto.Hash) (*crypto.Hash, error) {
+	current := target
+	for {
+		rec, err := g.GetExtension(current)
+		if errors.Is(err, ErrNotFound) {
+			return current, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent, err := crypto.FromHex(rec.Parent)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt extension record: %w", err)
+		}
+		current = parent
+	}
+}
+
+// ReconstructStream writes the content at target to w: the root
+// block's data followed by every delta in chain order. Only one link's
+// delta is held in memory at a time, so arbitrarily long chains
+// reconstruct in bounded memory.
+func (g *ExtensionGraph) ReconstructStream(bs *BlockStore, target *crypto.Hash, w io.Writer) error {
+	// First pass holds only the hashes, walking back to the root.
+	var order []*crypto.Hash
+	current := target
+	for {
+		rec, err := g.GetExtension(current)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		order = append(order, current)
+		parent, err := crypto.FromHex(rec.Parent)
+		if err != nil {
+			return fmt.Errorf("corrupt extension record: %w", err)
+		}
+		current = parent
+	}
+	root := current
+
+	if !root.Equal(crypto.Zero()) {
+		block, err := bs.Get(root)
+		if err != nil {
+			return fmt.Errorf("root block %s: %w", root, err)
+		}
+		if _, err := w.Write(block.Data); err != nil {
+			return err
+		}
+	}
+	// Second pass re-reads each record root-to-target and streams its
+	// delta out.
+	for i := len(order) - 1; i >= 0; i-- {
+		rec, err := g.GetExtension(order[i])
+		if err != nil {
+			return err
+		}
+		var delta []byte
+		if rec.Codec != "" {
+			// Coded deltas need the parent content, which streaming
+			// deliberately does not retain; materialize it for this
+			// link only.
+			parent, err := crypto.FromHex(rec.Parent)
+			if err != nil {
+				return fmt.Errorf("corrupt extension record: %w", err)
+			}
+			parentData, err := g.ReconstructContent(bs, parent)
+			if err != nil {
+				return err
+			}
+			delta, err = resolveRecordDelta(bs, rec, parentData)
+			if err != nil {
+				return err
+			}
+		} else {
+			delta, err = recordDelta(bs, rec)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconstructContent rebuilds the full content at target: the root
+// block's data followed by every delta in chain order.
+func (g *ExtensionGraph) ReconstructContent(bs *BlockStore, target *crypto.Hash) ([]byte, error) {
+	chain, err := g.GetChain(target)
+	if err != nil {
+		return nil, err
+	}
+	root := target
+	if len(chain) > 0 {
+		root, err = crypto.FromHex(chain[0].Parent)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt extension record: %w", err)
+		}
+	}
+	var data []byte
+	if !root.Equal(crypto.Zero()) {
+		// Chains rooted at the zero hash start from empty content;
+		// anything else starts from a stored root block.
+		block, err := bs.Get(root)
+		if err != nil {
+			return nil, fmt.Errorf("root block %s: %w", root, err)
+		}
+		data = append(data, block.Data...)
+	}
+	for _, rec := range chain {
+		delta, err := resolveRecordDelta(bs, rec, data)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, delta...)
+	}
+	return data, nil
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/systemshift/tera/crypto"
+)
+
+// IntegrityReport is the result of a storage self-audit.
+type IntegrityReport struct {
+	BlocksChecked     int
+	ExtensionsChecked int
+	// OrphanedBlocks counts blocks the extension graph never
+	// references: not a chain root, not a stored delta.
+	OrphanedBlocks int
+	// BrokenChains counts leaves whose chain fails verification when
+	// walked back to its root.
+	BrokenChains int
+	Errors       []string
+}
+
+// Valid reports whether the audit found no problems.
+func (r *IntegrityReport) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// merge appends other's findings onto r.
+func (r *IntegrityReport) merge(other *IntegrityReport) {
+	r.BlocksChecked += other.BlocksChecked
+	r.ExtensionsChecked += other.ExtensionsChecked
+	r.OrphanedBlocks += other.OrphanedBlocks
+	r.BrokenChains += other.BrokenChains
+	r.Errors = append(r.Errors, other.Errors...)
+}
+
+// graphIndex is one pass over the extension records: which hashes the
+// graph references, and which children are leaves.
+type graphIndex struct {
+	referenced map[string]bool
+	parents    map[string]bool
+	children   map[string]bool
+}
+
+func (s *Store) indexGraph() (*graphIndex, error) {
+	idx := &graphIndex{
+		referenced: make(map[string]bool),
+		parents:    make(map[string]bool),
+		children:   make(map[string]bool),
+	}
+	err := s.Graph.ForEachExtension(func(rec *ExtensionRecord) error {
+		idx.referenced[rec.Parent] = true
+		if rec.DeltaHash != "" {
+			idx.referenced[rec.DeltaHash] = true
+		} else {
+			idx.referenced[crypto.HashElement(rec.Delta).Hex()] = true
+		}
+		idx.parents[rec.Parent] = true
+		idx.children[rec.Child] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// verifyBlock re-hashes one block and re-verifies the extensions
+// recorded under it, appending findings to report. The returned error
+// is fatal (the audit itself failed), not a finding.
+func (s *Store) verifyBlock(h *crypto.Hash, idx *graphIndex, report *IntegrityReport) error {
+	block, err := s.Blocks.Get(h)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("block %s: %v", h, err))
+		return nil
+	}
+	report.BlocksChecked++
+	contentHash, err := block.ContentHash()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("block %s: %v", h, err))
+	} else if !contentHash.Equal(h) {
+		report.Errors = append(report.Errors,
+			fmt.Sprintf("block %s: data does not match hash", h))
+	}
+	if !idx.referenced[h.Hex()] {
+		report.OrphanedBlocks++
+	}
+
+	children, err := s.Graph.GetChildren(h)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		rec, err := s.Graph.GetExtension(child)
+		if err != nil {
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("extension %s: %v", child, err))
+			continue
+		}
+		report.ExtensionsChecked++
+		// Children of a stored block extend exactly that block's
+		// content, so coded deltas resolve against its data.
+		delta, err := resolveRecordDelta(s.Blocks, rec, block.Data)
+		if err != nil {
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("extension %s -> %s: %v", h, child, err))
+			continue
+		}
+		if !crypto.VerifyExtension(h, child, delta) {
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("extension %s -> %s: verification failed", h, child))
+		}
+	}
+	return nil
+}
+
+// verifyChains walks each leaf (a child no record extends further)
+// back to its root and re-verifies the whole chain. Leaves are visited
+// in sorted order so the report is deterministic.
+func (s *Store) verifyChains(idx *graphIndex, report *IntegrityReport) {
+	leaves := make([]string, 0, len(idx.children))
+	for childHex := range idx.children {
+		if !idx.parents[childHex] {
+			leaves = append(leaves, childHex)
+		}
+	}
+	sort.Strings(leaves)
+
+	for _, childHex := range leaves {
+		leaf, err := crypto.FromHex(childHex)
+		if err != nil {
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("corrupt extension child %q: %v", childHex, err))
+			continue
+		}
+		root, err := s.Graph.GetRoot(leaf)
+		if err != nil {
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("chain %s: %v", leaf, err))
+			continue
+		}
+		result, err := s.VerifyChain(root, leaf)
+		if err != nil {
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("chain %s: %v", leaf, err))
+			continue
+		}
+		if !result.Valid {
+			report.BrokenChains++
+			report.Errors = append(report.Errors,
+				fmt.Sprintf("chain %s -> %s: %s", root, leaf, result.Reason))
+		}
+	}
+}
+
+// VerifyStorageIntegrity re-hashes every block, re-verifies every
+// extension record against its parent and child hashes, and counts
+// orphaned blocks and broken chains.
+func (s *Store) VerifyStorageIntegrity() (*IntegrityReport, error) {
+	idx, err := s.indexGraph()
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := s.Blocks.List()
+	if err != nil {
+		return nil, err
+	}
+	report := &IntegrityReport{}
+	for _, h := range hashes {
+		if err := s.verifyBlock(h, idx, report); err != nil {
+			return nil, err
+		}
+	}
+	s.verifyChains(idx, report)
+	return report, nil
+}
+
+// VerifyStorageIntegrityParallel is VerifyStorageIntegrity with
+// per-block verification fanned out across workers. Partial reports
+// are merged back in block order, so the result is identical to the
+// serial audit.
+func (s *Store) VerifyStorageIntegrityParallel(workers int) (*IntegrityReport, error) {
+	if workers <= 1 {
+		return s.VerifyStorageIntegrity()
+	}
+	idx, err := s.indexGraph()
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := s.Blocks.List()
+	if err != nil {
+		return nil, err
+	}
+
+	partials := make([]*IntegrityReport, len(hashes))
+	errs := make([]error, len(hashes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				partial := &IntegrityReport{}
+				errs[i] = s.verifyBlock(hashes[i], idx, partial)
+				partials[i] = partial
+			}
+		}()
+	}
+	for i := range hashes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &IntegrityReport{}
+	for i := range hashes {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		report.merge(partials[i])
+	}
+	s.verifyChains(idx, report)
+	return report, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// schemaVersionKey holds the store's on-disk schema version as a
+// decimal string. Stores written before versioning existed have no
+// key and are treated as version 0.
+const schemaVersionKey = "meta:schema_version"
+
+// currentSchemaVersion is the schema this code writes and reads.
+//
+// History:
+//
+//	1 — first versioned layout: blocks, extension records with
+//	    delta-by-reference, persisted features, tombstones.
+const currentSchemaVersion = 1
+
+// A migration brings a store from Version-1 to Version. Migrations
+// must be idempotent: a crash between applying one and recording its
+// version re-runs it on the next open.
+type migration struct {
+	Version int
+	Apply   func(db *badger.DB) error
+}
+
+// migrations are applied in order by Migrate.
+var migrations = []migration{
+	{Version: 1, Apply: func(db *badger.DB) error {
+		// Version 1 introduces only the version key itself; the data
+		// layout of pre-versioning stores is already current.
+		return nil
+	}},
+}
+
+// Migrate brings db up to currentSchemaVersion, applying any pending
+// migrations in order and recording each step. A store written by a
+// newer build is refused rather than opened half-understood.
+func Migrate(db *badger.DB) error {
+	version, err := readSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > currentSchemaVersion {
+		return fmt.Errorf("store schema version %d is newer than this build supports (%d); upgrade the binary",
+			version, currentSchemaVersion)
+	}
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Apply(db); err != nil {
+			return fmt.Errorf("migrate store to schema %d: %w", m.Version, err)
+		}
+		if err := writeSchemaVersion(db, m.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSchemaVersion returns the recorded schema version; a store
+// without the key reads as 0.
+func readSchemaVersion(db *badger.DB) (int, error) {
+	var version int
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(schemaVersionKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			version, err = strconv.Atoi(string(val))
+			if err != nil {
+				return fmt.Errorf("corrupt schema version %q: %w", val, err)
+			}
+			return nil
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// writeSchemaVersion records version; callers apply it after each
+// migration step so progress survives a crash.
+func writeSchemaVersion(db *badger.DB, version int) error {
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+	})
+	if err != nil {
+		return fmt.Errorf("write schema version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestNewStoreRecordsSchemaVersion(t *testing.T) {
+	s := newTestStore(t)
+	version, err := readSchemaVersion(s.db)
+	if err != nil {
+		t.Fatalf("readSchemaVersion: %v", err)
+	}
+	if version != currentSchemaVersion {
+		t.Fatalf("schema version = %d, want %d", version, currentSchemaVersion)
+	}
+}
+
+func TestNewStoreRefusesNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if err := writeSchemaVersion(s.db, currentSchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewStore(Config{Path: dir})
+	if err == nil {
+		t.Fatal("opened a store written by a newer build")
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(currentSchemaVersion+1)) {
+		t.Fatalf("error does not name the offending version: %v", err)
+	}
+}
+
+func TestMigrateBringsOldStoreForward(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	data := []byte("content written before versioning")
+	hash, err := s.PutContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Strip the version key so the store reads as a pre-versioning
+	// layout.
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(schemaVersionKey))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewStore(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("reopening version-0 store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	version, err := readSchemaVersion(s.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != currentSchemaVersion {
+		t.Fatalf("migrated to version %d, want %d", version, currentSchemaVersion)
+	}
+	got, err := s.GetContent(hash)
+	if err != nil {
+		t.Fatalf("content lost in migration: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("migrated content = %q, want %q", got, data)
+	}
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/semantic"
+)
+
+// SearchResult pairs a stored block with its similarity to a query.
+type SearchResult struct {
+	Hash  *crypto.Hash `json:"hash"`
+	Score float64      `json:"score"`
+}
+
+// SearchSimilar ranks every stored block against the query and
+// returns the best limit matches, most similar first. Tombstoned
+// content is excluded. Ties are broken
+// by hash so results are deterministic. limit <= 0 returns the full
+// ranking.
+func (s *Store) SearchSimilar(query *core.Query, limit int) ([]SearchResult, error) {
+	var results []SearchResult
+	cursor := ""
+	for {
+		hashes, next, err := s.Blocks.ListPage(cursor, scanPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hashes {
+			deleted, err := s.IsDeleted(h)
+			if err != nil {
+				return nil, err
+			}
+			if deleted {
+				continue
+			}
+			features, err := s.blockFeatures(h)
+			if err != nil {
+				return nil, err
+			}
+			score := semantic.Similarity(query.Content.Features, features, query.Params)
+			results = append(results, SearchResult{Hash: h, Score: score})
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Hash.Hex() < results[j].Hash.Hex()
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// ChainDrift measures how far each link of a chain has wandered from
+// its root: entry i is the similarity between the reconstructed
+// content after link i and the root content, so a descending series
+// marks a thread drifting off-topic. The result has one entry per
+// chain link.
+func (s *Store) ChainDrift(root, target *crypto.Hash, params semantic.KernelParams) ([]float64, error) {
+	chain, err := s.Graph.GetChain(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if !root.Equal(crypto.Zero()) {
+		block, err := s.Blocks.Get(root)
+		if err != nil {
+			return nil, fmt.Errorf("root block %s: %w", root, err)
+		}
+		data = append(data, block.Data...)
+	}
+	rootFeatures := semantic.ExtractFeatures(data)
+
+	drift := make([]float64, len(chain))
+	for i, rec := range chain {
+		delta, err := resolveRecordDelta(s.Blocks, rec, data)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, delta...)
+		drift[i] = semantic.Similarity(rootFeatures, semantic.ExtractFeatures(data), params)
+	}
+	return drift, nil
+}
+
+// ClusterContent groups the stored blocks into topic clusters:
+// single-linkage agglomerative clustering over the all-pairs
+// similarity matrix, merging two clusters whenever any cross pair
+// scores at or above threshold. Tombstoned content is excluded. Blocks
+// are processed in hex-hash order and clusters are returned ordered by
+// their first member, so output is deterministic.
+func (s *Store) ClusterContent(threshold float64, params semantic.KernelParams) ([][]*crypto.Hash, error) {
+	var hashes []*crypto.Hash
+	cursor := ""
+	for {
+		page, next, err := s.Blocks.ListPage(cursor, scanPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range page {
+			deleted, err := s.IsDeleted(h)
+			if err != nil {
+				return nil, err
+			}
+			if !deleted {
+				hashes = append(hashes, h)
+			}
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Hex() < hashes[j].Hex() })
+
+	features := make([]*semantic.Features, len(hashes))
+	for i, h := range hashes {
+		f, err := s.blockFeatures(h)
+		if err != nil {
+			return nil, err
+		}
+		features[i] = f
+	}
+	matrix := semantic.SimilarityMatrix(features, params)
+
+	// Single linkage is exactly union-find over the above-threshold
+	// pairs.
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := 0; i < len(hashes); i++ {
+		for j := i + 1; j < len(hashes); j++ {
+			if matrix[i][j] >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	// Members keep hex order because i runs over the sorted hashes;
+	// clusters inherit the order of their first member.
+	byRoot := make(map[int]int)
+	var clusters [][]*crypto.Hash
+	for i, h := range hashes {
+		root := find(i)
+		c, ok := byRoot[root]
+		if !ok {
+			c = len(clusters)
+			byRoot[root] = c
+			clusters = append(clusters, nil)
+		}
+		clusters[c] = append(clusters[c], h)
+	}
+	return clusters, nil
+}
+
+// blockFeatures returns the semantic features of a stored block,
+// preferring the copy persisted at Put time. Blocks written before
+// features were stored are migrated lazily: extract once, persist,
+// and every later search reads the stored copy.
+func (s *Store) blockFeatures(h *crypto.Hash) (*semantic.Features, error) {
+	features, err := s.Blocks.GetFeatures(h)
+	if err == nil {
+		return features, nil
+	}
+	if !IsNotFound(err) {
+		return nil, err
+	}
+	block, err := s.Blocks.Get(h)
+	if err != nil {
+		return nil, err
+	}
+	features = semantic.ExtractFeatures(block.Data)
+	if err := s.Blocks.PutFeatures(h, features); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
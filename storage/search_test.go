@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+	"github.com/systemshift/tera/semantic"
+)
+
+func TestSearchSimilarRanksRelevantFirst(t *testing.T) {
+	s := newTestStore(t)
+	docs := map[string][]byte{
+		"ml":      []byte("machine learning models and neural network training"),
+		"cooking": []byte("sourdough bread recipes and fermentation"),
+		"hiking":  []byte("alpine trail maps and weather forecasts"),
+	}
+	hashes := make(map[string]string)
+	for name, data := range docs {
+		h, err := s.PutContent(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[name] = h.Hex()
+	}
+
+	query := core.NewQuery("training neural network models with machine learning", 0.1)
+	results, err := s.SearchSimilar(query, 2)
+	if err != nil {
+		t.Fatalf("SearchSimilar: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Hash.Hex() != hashes["ml"] {
+		t.Fatalf("top result is not the ML doc")
+	}
+	if results[0].Score <= results[1].Score {
+		t.Fatalf("results not ordered: %f then %f", results[0].Score, results[1].Score)
+	}
+}
+
+func TestStoredFeaturesMatchFreshExtraction(t *testing.T) {
+	s := newTestStore(t)
+	data := []byte("stored features should match a fresh extraction exactly")
+	h, err := s.PutContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.Blocks.GetFeatures(h)
+	if err != nil {
+		t.Fatalf("GetFeatures: %v", err)
+	}
+	fresh := semantic.ExtractFeatures(data)
+	if !reflect.DeepEqual(stored, fresh) {
+		t.Fatalf("stored features %+v differ from fresh %+v", stored, fresh)
+	}
+}
+
+func TestBlockFeaturesLazyMigration(t *testing.T) {
+	s := newTestStore(t)
+	data := []byte("pre-features block")
+	h, err := s.PutContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a block written before features were persisted.
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(semanticKey(h))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Blocks.GetFeatures(h); !IsNotFound(err) {
+		t.Fatalf("expected missing features, got %v", err)
+	}
+
+	features, err := s.blockFeatures(h)
+	if err != nil {
+		t.Fatalf("blockFeatures: %v", err)
+	}
+	if features.WordCount == 0 {
+		t.Fatal("migrated features empty")
+	}
+	// The migration persisted them for next time.
+	if _, err := s.Blocks.GetFeatures(h); err != nil {
+		t.Fatalf("features not persisted by migration: %v", err)
+	}
+}
+
+func TestChainDriftDecreasesOffTopic(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("machine learning models and neural network training methods"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	deltas := [][]byte{
+		[]byte(" more neural network training results"),
+		[]byte(" a quick note about sourdough bread"),
+		[]byte(" sourdough starter fermentation schedules and baking temperatures"),
+	}
+	tip := root.Hash
+	for _, delta := range deltas {
+		ext := core.NewExtension(tip, delta)
+		if err := s.PutExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+		tip = ext.Child
+	}
+
+	drift, err := s.ChainDrift(root.Hash, tip, semantic.DefaultKernelParams())
+	if err != nil {
+		t.Fatalf("ChainDrift: %v", err)
+	}
+	if len(drift) != len(deltas) {
+		t.Fatalf("drift has %d entries, want %d", len(drift), len(deltas))
+	}
+	for i := 1; i < len(drift); i++ {
+		if drift[i] >= drift[i-1] {
+			t.Fatalf("drift not decreasing: %v", drift)
+		}
+	}
+}
+
+func TestClusterContentSeparatesTopics(t *testing.T) {
+	s := newTestStore(t)
+
+	ml1, err := s.PutContent([]byte("machine learning models learn patterns from training data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ml2, err := s.PutContent([]byte("training machine learning models on labeled data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cooking, err := s.PutContent([]byte("slow-roasted vegetables with garlic butter and thyme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := s.ClusterContent(0.3, semantic.DefaultKernelParams())
+	if err != nil {
+		t.Fatalf("ClusterContent: %v", err)
+	}
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2: %v", len(clusters), clusters)
+	}
+
+	clusterOf := func(h *crypto.Hash) int {
+		t.Helper()
+		for i, cluster := range clusters {
+			for _, member := range cluster {
+				if member.Equal(h) {
+					return i
+				}
+			}
+		}
+		t.Fatalf("hash %s missing from clusters", h)
+		return -1
+	}
+	if clusterOf(ml1) != clusterOf(ml2) {
+		t.Fatal("the two ML documents landed in different clusters")
+	}
+	if clusterOf(ml1) == clusterOf(cooking) {
+		t.Fatal("cooking document clustered with ML")
+	}
+
+	// Deterministic output: a second run is identical.
+	again, err := s.ClusterContent(0.3, semantic.DefaultKernelParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != len(clusters) {
+		t.Fatalf("second run produced %d clusters, want %d", len(again), len(clusters))
+	}
+	for i := range clusters {
+		if len(again[i]) != len(clusters[i]) {
+			t.Fatalf("cluster %d size changed between runs", i)
+		}
+		for j := range clusters[i] {
+			if !again[i][j].Equal(clusters[i][j]) {
+				t.Fatalf("cluster %d member %d changed between runs", i, j)
+			}
+		}
+	}
+}
@@ -0,0 +1,757 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+)
+
+// Config controls how a Store opens its badger database.
+type Config struct {
+	// Path is the badger directory. Ignored when InMemory is set.
+	Path string
+	// InMemory keeps everything in RAM; used by tests and demos.
+	InMemory bool
+	// Compression is the codec applied to block data at rest. The
+	// zero value stores blocks uncompressed.
+	Compression Compression
+	// TTL expires blocks and extension records this long after they
+	// are written; zero keeps everything forever. Expired entries
+	// vanish from Get, Has and List; the running counters and the
+	// children/publisher indexes are reconciled by RecountStats
+	// rather than on expiry.
+	TTL time.Duration
+	// Tuning overrides the badger options that matter most for block
+	// workloads; zero fields keep badger's defaults. Start from
+	// SmallBlocksTuning or LargeBlocksTuning when the workload shape
+	// is known.
+	Tuning BadgerTuning
+	// VerifyOnRead rechecks on every block read that the stored bytes
+	// hash to the block's key, turning silent bit rot or on-disk
+	// tampering into ErrBlockCorrupted. Costs one hash per read.
+	VerifyOnRead bool
+	// DeltaCodec re-encodes extension deltas against their parent's
+	// content before storage, for chains whose deltas are small edits
+	// of what came before. Nil stores deltas verbatim. Encoding needs
+	// the parent content locally; extensions of content the store
+	// does not hold fall back to verbatim storage.
+	DeltaCodec DeltaCodec
+}
+
+// BadgerTuning maps onto the badger options whose defaults fit
+// neither extreme of the block-size spectrum.
+type BadgerTuning struct {
+	// ValueLogFileSize caps each value log file in bytes.
+	ValueLogFileSize int64
+	// ValueThreshold is the size in bytes above which a value moves
+	// to the value log instead of living in the LSM tree.
+	ValueThreshold int64
+	// NumCompactors sets how many compaction workers run.
+	NumCompactors int
+	// BlockCacheSize is the shared block cache in bytes.
+	BlockCacheSize int64
+}
+
+// SmallBlocksTuning fits stores holding many small deltas: values
+// stay in the LSM tree where point lookups are cheapest, and the
+// value log files stay small.
+func SmallBlocksTuning() BadgerTuning {
+	return BadgerTuning{
+		ValueLogFileSize: 64 << 20,
+		ValueThreshold:   64 << 10,
+		BlockCacheSize:   64 << 20,
+	}
+}
+
+// LargeBlocksTuning fits stores holding few large blocks: values move
+// to the value log early to keep the LSM tree shallow, with more
+// compactors and cache to match.
+func LargeBlocksTuning() BadgerTuning {
+	return BadgerTuning{
+		ValueLogFileSize: 1 << 30,
+		ValueThreshold:   1 << 10,
+		NumCompactors:    8,
+		BlockCacheSize:   256 << 20,
+	}
+}
+
+// apply lays the non-zero tuning fields over opts.
+func (t BadgerTuning) apply(opts badger.Options) badger.Options {
+	if t.ValueLogFileSize > 0 {
+		opts = opts.WithValueLogFileSize(t.ValueLogFileSize)
+	}
+	if t.ValueThreshold > 0 {
+		opts = opts.WithValueThreshold(t.ValueThreshold)
+	}
+	if t.NumCompactors > 0 {
+		opts = opts.WithNumCompactors(t.NumCompactors)
+	}
+	if t.BlockCacheSize > 0 {
+		opts = opts.WithBlockCacheSize(t.BlockCacheSize)
+	}
+	return opts
+}
+
+// Store combines the block layer and the extension graph behind one
+// badger database.
+type Store struct {
+	db         *badger.DB
+	Blocks     *BlockStore
+	Graph      *ExtensionGraph
+	deltaCodec DeltaCodec
+	events     subscriptions
+}
+
+// StoreStats summarizes what a store holds.
+type StoreStats struct {
+	BlockCount     int   `json:"block_count"`
+	ExtensionCount int   `json:"extension_count"`
+	TotalSize      int64 `json:"total_size"`
+}
+
+// NewStore opens (or creates) a store.
+func NewStore(cfg Config) (*Store, error) {
+	if !cfg.Compression.valid() {
+		return nil, fmt.Errorf("unknown compression %q", cfg.Compression)
+	}
+	opts := badger.DefaultOptions(cfg.Path).WithLogger(nil)
+	if cfg.InMemory {
+		opts = opts.WithInMemory(true)
+	}
+	opts = cfg.Tuning.apply(opts)
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("open badger: %w", err)
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	blocks := NewBlockStore(db)
+	blocks.compression = cfg.Compression
+	blocks.ttl = cfg.TTL
+	blocks.verifyOnRead = cfg.VerifyOnRead
+	graph := NewExtensionGraph(db)
+	graph.ttl = cfg.TTL
+	return &Store{
+		db:         db,
+		Blocks:     blocks,
+		Graph:      graph,
+		deltaCodec: cfg.DeltaCodec,
+	}, nil
+}
+
+// Close releases the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// PutContent stores root content and returns its crypto hash.
+func (s *Store) PutContent(data []byte) (*crypto.Hash, error) {
+	hash, err := s.Blocks.Put(data)
+	if err != nil {
+		return nil, err
+	}
+	s.events.notify(ContentPut, hash)
+	return hash, nil
+}
+
+// PutContentWithTTL stores root content that expires after ttl,
+// overriding the store's configured TTL; zero stores it forever.
+func (s *Store) PutContentWithTTL(data []byte, ttl time.Duration) (*crypto.Hash, error) {
+	hash, err := s.Blocks.PutWithTTL(data, ttl)
+	if err != nil {
+		return nil, err
+	}
+	s.events.notify(ContentPut, hash)
+	return hash, nil
+}
+
+// PutExtension persists a verified extension: the delta as a
+// content-addressed block (shared by every extension with the same
+// delta bytes) plus the graph record linking parent to child.
+// Tombstones store no block or record; they mark the parent content
+// deleted instead.
+func (s *Store) PutExtension(ext *core.Extension) error {
+	if !ext.Verify() {
+		return fmt.Errorf("refusing to store unverified extension %s", ext)
+	}
+	if ext.Deleted {
+		return s.MarkDeleted(ext.Parent)
+	}
+	var rec *ExtensionRecord
+	if s.deltaCodec != nil {
+		rec, _ = s.codedRecord(ext)
+	}
+	if rec == nil {
+		deltaHash, err := s.Blocks.Put(ext.Delta)
+		if err != nil {
+			return err
+		}
+		rec = &ExtensionRecord{
+			Parent:    ext.Parent.Hex(),
+			Child:     ext.Child.Hex(),
+			DeltaHash: deltaHash.Hex(),
+			Publisher: ext.Publisher,
+			Timestamp: ext.Timestamp,
+		}
+	}
+	if err := s.Graph.AddExtension(rec); err != nil {
+		return err
+	}
+	s.events.notify(ExtensionAdded, ext.Child)
+	return nil
+}
+
+// codedRecord builds an extension record with the delta re-encoded
+// against the parent's reconstructed content. Extensions whose parent
+// the store cannot reconstruct report false so the caller can fall
+// back to storing the delta verbatim.
+func (s *Store) codedRecord(ext *core.Extension) (*ExtensionRecord, bool) {
+	var parent []byte
+	if !ext.Parent.Equal(crypto.Zero()) {
+		var err error
+		parent, err = s.Reconstruct(ext.Parent)
+		if err != nil {
+			return nil, false
+		}
+	}
+	return &ExtensionRecord{
+		Parent:     ext.Parent.Hex(),
+		Child:      ext.Child.Hex(),
+		CodedDelta: s.deltaCodec.EncodeDelta(parent, ext.Delta),
+		Codec:      s.deltaCodec.Name(),
+		Publisher:  ext.Publisher,
+		Timestamp:  ext.Timestamp,
+	}, true
+}
+
+// ExtendContent appends delta to content the store already holds: it
+// computes the child hash via the homomorphic extension, stores the
+// delta block and the graph record, and returns the child hash — the
+// one-call equivalent of reconstructing the content and extending it
+// through the core package. The parent must be a stored root block or
+// the tip of a stored chain; extending unknown content returns
+// ErrNotFound.
+func (s *Store) ExtendContent(parent *crypto.Hash, delta []byte) (*crypto.Hash, error) {
+	has, err := s.Blocks.Has(parent)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		// Not a root block; a chain tip is known by the record that
+		// produced it.
+		if _, err := s.Graph.GetExtension(parent); err != nil {
+			return nil, err
+		}
+	}
+	ext := core.NewExtension(parent, delta)
+	if err := s.PutExtension(ext); err != nil {
+		return nil, err
+	}
+	return ext.Child, nil
+}
+
+// GetContent returns the fully reconstructed content at target,
+// whether it is a root block or the tip of a chain. Tombstoned
+// content returns ErrContentDeleted; the raw blocks stay on disk for
+// audit until garbage collected.
+func (s *Store) GetContent(target *crypto.Hash) ([]byte, error) {
+	deleted, err := s.IsDeleted(target)
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, ErrContentDeleted
+	}
+	return s.Reconstruct(target)
+}
+
+// GetContentVerified is GetContent with every block read rechecked
+// against its hash, regardless of the store's VerifyOnRead setting.
+// Corrupted blocks surface as ErrBlockCorrupted instead of flowing
+// into the reconstruction.
+func (s *Store) GetContentVerified(target *crypto.Hash) ([]byte, error) {
+	deleted, err := s.IsDeleted(target)
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, ErrContentDeleted
+	}
+	verified := *s.Blocks
+	verified.verifyOnRead = true
+	return s.Graph.ReconstructContent(&verified, target)
+}
+
+// MarkDeleted records a tombstone for the content at target. The
+// blocks themselves are untouched.
+func (s *Store) MarkDeleted(target *crypto.Hash) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(prefixDeleted+target.Hex()), nil)
+	})
+	if err != nil {
+		return err
+	}
+	s.events.notify(ContentDeleted, target)
+	return nil
+}
+
+// IsDeleted reports whether target has been tombstoned.
+func (s *Store) IsDeleted(target *crypto.Hash) (bool, error) {
+	err := s.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(prefixDeleted + target.Hex()))
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reconstruct rebuilds content by walking target's chain back to its
+// root block.
+func (s *Store) Reconstruct(target *crypto.Hash) ([]byte, error) {
+	return s.Graph.ReconstructContent(s.Blocks, target)
+}
+
+// VerifyChain re-verifies the stored chain from root to target, link
+// by link, so the result's FailureCode distinguishes a record that
+// does not verify (ban-worthy) from one that is simply missing
+// locally (fetchable). The returned error covers storage faults only;
+// verification verdicts live in the result.
+func (s *Store) VerifyChain(root, target *crypto.Hash) (*crypto.VerificationResult, error) {
+	chain, err := s.Graph.GetChain(target)
+	if err != nil {
+		return nil, err
+	}
+	res := &crypto.VerificationResult{ChainLength: len(chain), FailedAt: -1}
+	// Coded deltas resolve against the content accumulated so far;
+	// plain chains skip the accumulation to keep verification cheap.
+	coded := false
+	for _, rec := range chain {
+		if rec.Codec != "" {
+			coded = true
+			break
+		}
+	}
+	var data []byte
+	if coded && !root.Equal(crypto.Zero()) {
+		block, err := s.Blocks.Get(root)
+		if err != nil {
+			return nil, fmt.Errorf("root block %s: %w", root, err)
+		}
+		data = append(data, block.Data...)
+	}
+	current := root
+	for i, rec := range chain {
+		parent, err := crypto.FromHex(rec.Parent)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt extension record: %w", err)
+		}
+		child, err := crypto.FromHex(rec.Child)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt extension record: %w", err)
+		}
+		if !parent.Equal(current) {
+			res.FailedAt = i
+			res.Code = crypto.FailureChainBreak
+			res.Reason = fmt.Sprintf("link %d parents %s, want %s", i, parent, current)
+			return res, nil
+		}
+		delta, err := resolveRecordDelta(s.Blocks, rec, data)
+		if IsNotFound(err) {
+			res.FailedAt = i
+			res.Code = crypto.FailureRecordNotFound
+			res.Reason = fmt.Sprintf("link %d delta %s not available locally", i, rec.DeltaHash)
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !crypto.VerifyExtension(parent, child, delta) {
+			res.FailedAt = i
+			res.Code = crypto.FailureInvalidExtension
+			res.Reason = fmt.Sprintf("link %d delta does not produce child %s", i, child)
+			return res, nil
+		}
+		if coded {
+			data = append(data, delta...)
+		}
+		current = child
+	}
+	if !current.Equal(target) {
+		res.FailedAt = len(chain)
+		res.Code = crypto.FailureTargetNotReached
+		res.Reason = fmt.Sprintf("chain of %d links ends at %s, not target %s", len(chain), current, target)
+		return res, nil
+	}
+	res.Valid = true
+	return res, nil
+}
+
+// VerifyChainFromTarget is VerifyChain for callers that only hold the
+// tip: the root is discovered by following the stored parent links,
+// then the chain is verified as usual. The result's RootHash carries
+// the discovered root.
+func (s *Store) VerifyChainFromTarget(target *crypto.Hash) (*crypto.VerificationResult, error) {
+	root, err := s.Graph.GetRoot(target)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.VerifyChain(root, target)
+	if err != nil {
+		return nil, err
+	}
+	res.RootHash = root
+	return res, nil
+}
+
+// WalkChain visits every extension record on the chain ending at
+// target, in root-to-target order, stopping early when fn returns an
+// error (which is passed through). Unlike GetChain it holds only the
+// chain's hashes in memory, not the records and their deltas, so
+// streaming consumers can walk arbitrarily long chains.
+func (s *Store) WalkChain(target *crypto.Hash, fn func(*ExtensionRecord) error) error {
+	// First pass: collect the child hashes back to the root; records
+	// are re-read one at a time on the forward pass.
+	var children []*crypto.Hash
+	current := target
+	for {
+		rec, err := s.Graph.GetExtension(current)
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		children = append(children, current)
+		parent, err := crypto.FromHex(rec.Parent)
+		if err != nil {
+			return fmt.Errorf("corrupt extension record: %w", err)
+		}
+		current = parent
+	}
+	for i := len(children) - 1; i >= 0; i-- {
+		rec, err := s.Graph.GetExtension(children[i])
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanPageSize bounds how many block hashes GetStats and
+// GarbageCollect hold in memory at once.
+const scanPageSize = 1024
+
+// GetStats reads the running counters maintained by the Put and
+// Delete transactions, so it is O(1) regardless of store size.
+func (s *Store) GetStats() (*StoreStats, error) {
+	stats := &StoreStats{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		blocks, err := readCounter(txn, counterBlockCount)
+		if err != nil {
+			return err
+		}
+		exts, err := readCounter(txn, counterExtensionCount)
+		if err != nil {
+			return err
+		}
+		size, err := readCounter(txn, counterTotalSize)
+		if err != nil {
+			return err
+		}
+		stats.BlockCount = int(blocks)
+		stats.ExtensionCount = int(exts)
+		stats.TotalSize = size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// RecountStats rebuilds the counters from a full scan and returns the
+// recomputed stats. Use it if the counters are suspected to have
+// drifted (e.g. after restoring a backup taken mid-write).
+func (s *Store) RecountStats() (*StoreStats, error) {
+	stats := &StoreStats{}
+	cursor := ""
+	for {
+		hashes, next, err := s.Blocks.ListPage(cursor, scanPageSize)
+		if err != nil {
+			return nil, err
+		}
+		stats.BlockCount += len(hashes)
+		for _, h := range hashes {
+			block, err := s.Blocks.Get(h)
+			if err != nil {
+				return nil, err
+			}
+			stats.TotalSize += int64(block.Size)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(prefixExtension)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			stats.ExtensionCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Update(func(txn *badger.Txn) error {
+		if err := setCounter(txn, counterBlockCount, int64(stats.BlockCount)); err != nil {
+			return err
+		}
+		if err := setCounter(txn, counterExtensionCount, int64(stats.ExtensionCount)); err != nil {
+			return err
+		}
+		return setCounter(txn, counterTotalSize, stats.TotalSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// reachableFrom walks children links from keepRoots and marks every
+// hash it visits, including the delta blocks the traversed extension
+// records reference: a delta block shared by a live chain must
+// survive GC even though nothing links to it as a chain node.
+func (s *Store) reachableFrom(keepRoots []*crypto.Hash) (map[string]bool, error) {
+	reachable := make(map[string]bool)
+	queue := append([]*crypto.Hash(nil), keepRoots...)
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if reachable[h.Hex()] {
+			continue
+		}
+		reachable[h.Hex()] = true
+		children, err := s.Graph.GetChildren(h)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			rec, err := s.Graph.GetExtension(child)
+			if IsNotFound(err) {
+				// The record expired out from under its index entry.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if rec.DeltaHash != "" {
+				reachable[rec.DeltaHash] = true
+			}
+		}
+		queue = append(queue, children...)
+	}
+	return reachable, nil
+}
+
+// forEachUnreachable pages through all blocks and calls fn for every
+// one not reachable from keepRoots.
+func (s *Store) forEachUnreachable(keepRoots []*crypto.Hash, fn func(*crypto.Hash) error) error {
+	reachable, err := s.reachableFrom(keepRoots)
+	if err != nil {
+		return err
+	}
+	cursor := ""
+	for {
+		hashes, next, err := s.Blocks.ListPage(cursor, scanPageSize)
+		if err != nil {
+			return err
+		}
+		for _, h := range hashes {
+			if reachable[h.Hex()] {
+				continue
+			}
+			if err := fn(h); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// GarbageCollect deletes blocks unreachable from keepRoots (following
+// children links, and keeping delta blocks those links reference) and
+// returns how many were removed.
+func (s *Store) GarbageCollect(keepRoots []*crypto.Hash) (int, error) {
+	return s.GarbageCollectWithCallback(keepRoots, nil)
+}
+
+// GarbageCollectWithCallback is GarbageCollect with onDelete invoked
+// for every removed block, so operators can log exactly what went.
+func (s *Store) GarbageCollectWithCallback(keepRoots []*crypto.Hash, onDelete func(*crypto.Hash)) (int, error) {
+	removed := 0
+	err := s.forEachUnreachable(keepRoots, func(h *crypto.Hash) error {
+		if err := s.Blocks.Delete(h); err != nil {
+			return err
+		}
+		if onDelete != nil {
+			onDelete(h)
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// GarbageCollectDryRun returns the hashes GarbageCollect would delete
+// for keepRoots without deleting anything.
+func (s *Store) GarbageCollectDryRun(keepRoots []*crypto.Hash) ([]*crypto.Hash, error) {
+	var doomed []*crypto.Hash
+	err := s.forEachUnreachable(keepRoots, func(h *crypto.Hash) error {
+		doomed = append(doomed, h)
+		return nil
+	})
+	return doomed, err
+}
+
+// Compact collapses the chain ending at target into a single block:
+// the chain is verified, its content reconstructed and stored under
+// target's own hash (which the homomorphic property lets the block
+// carry verifiably, via the recorded segment boundaries), and the
+// chain's extension records are removed so target resolves directly
+// to the new block. The chain's old root and delta blocks are left
+// for GarbageCollect. Returns the compacted block's hash, which
+// always equals target.
+func (s *Store) Compact(target *crypto.Hash) (*crypto.Hash, error) {
+	chain, err := s.Graph.GetChain(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("nothing to compact: %s is not the tip of a chain", target)
+	}
+	root, err := crypto.FromHex(chain[0].Parent)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt extension record: %w", err)
+	}
+	result, err := s.VerifyChain(root, target)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid {
+		return nil, fmt.Errorf("refusing to compact broken chain: %s", result.Reason)
+	}
+
+	// Gather the content segments: the root block (unless the chain
+	// starts from empty content) followed by each delta.
+	var data []byte
+	var segments []int
+	if !root.Equal(crypto.Zero()) {
+		block, err := s.Blocks.Get(root)
+		if err != nil {
+			return nil, fmt.Errorf("root block %s: %w", root, err)
+		}
+		data = append(data, block.Data...)
+		segments = append(segments, len(block.Data))
+	}
+	for _, rec := range chain {
+		delta, err := resolveRecordDelta(s.Blocks, rec, data)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, delta...)
+		segments = append(segments, len(delta))
+	}
+
+	if _, err := s.Blocks.putBlock(target, data, segments, s.Blocks.ttl); err != nil {
+		return nil, err
+	}
+	for _, rec := range chain {
+		child, err := crypto.FromHex(rec.Child)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt extension record: %w", err)
+		}
+		if err := s.Graph.DeleteExtension(child); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// Backup streams a full copy of the database to w.
+func (s *Store) Backup(w io.Writer) error {
+	_, err := s.BackupSince(w, 0)
+	return err
+}
+
+// backupVersionKey persists the version returned by the most recent
+// backup, so an operator restarting between backups can resume the
+// incremental chain with LastBackupVersion instead of tracking the
+// number out of band.
+const backupVersionKey = "meta:backup_version"
+
+// BackupSince streams every change after the given version to w and
+// returns the new version, which is both persisted and the since
+// argument for the next incremental backup. Zero means a full backup.
+func (s *Store) BackupSince(w io.Writer, since uint64) (uint64, error) {
+	version, err := s.db.Backup(w, since)
+	if err != nil {
+		return 0, fmt.Errorf("backup since %d: %w", since, err)
+	}
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return setCounter(txn, backupVersionKey, int64(version))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("persist backup version: %w", err)
+	}
+	return version, nil
+}
+
+// LastBackupVersion returns the version persisted by the most recent
+// backup, or zero when no backup has been taken.
+func (s *Store) LastBackupVersion() (uint64, error) {
+	var version int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		version, err = readCounter(txn, backupVersionKey)
+		return err
+	})
+	return uint64(version), err
+}
+
+// Restore loads a backup previously written by Backup or BackupSince
+// into the store. Rebuilding from an incremental chain is repeated
+// Restore calls: the full backup first, then each incremental in the
+// order it was taken.
+func (s *Store) Restore(r io.Reader) error {
+	return s.db.Load(r, 16)
+}
+
+// IsNotFound reports whether err means the content simply is not in
+// the store.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
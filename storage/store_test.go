@@ -0,0 +1,1225 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+
+	"github.com/systemshift/tera/core"
+	"github.com/systemshift/tera/crypto"
+)
+
+func newTestStore(t testing.TB) *Store {
+	t.Helper()
+	s, err := NewStore(Config{InMemory: true})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPutGetContent(t *testing.T) {
+	s := newTestStore(t)
+	data := []byte("hello tera")
+	hash, err := s.PutContent(data)
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	got, err := s.GetContent(hash)
+	if err != nil {
+		t.Fatalf("GetContent: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("GetContent = %q, want %q", got, data)
+	}
+}
+
+func TestExtensionChainReconstruct(t *testing.T) {
+	s := newTestStore(t)
+
+	root := core.NewContent([]byte("chapter one. "))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+
+	ext1 := root.Extend([]byte("chapter two. "))
+	if err := s.PutExtension(ext1); err != nil {
+		t.Fatalf("PutExtension: %v", err)
+	}
+	ext2 := core.NewExtension(ext1.Child, []byte("chapter three."))
+	if err := s.PutExtension(ext2); err != nil {
+		t.Fatalf("PutExtension: %v", err)
+	}
+
+	got, err := s.Reconstruct(ext2.Child)
+	if err != nil {
+		t.Fatalf("Reconstruct: %v", err)
+	}
+	want := []byte("chapter one. chapter two. chapter three.")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Reconstruct = %q, want %q", got, want)
+	}
+
+	res, err := s.VerifyChain(root.Hash, ext2.Child)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !res.Valid {
+		t.Fatalf("stored chain invalid: %s", res.Reason)
+	}
+	if res.ChainLength != 2 {
+		t.Fatalf("ChainLength = %d, want 2", res.ChainLength)
+	}
+}
+
+func TestPutExtensionRejectsInvalid(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("base"))
+	ext := root.Extend([]byte("delta"))
+	ext.Child = crypto.HashElement([]byte("forged"))
+	if err := s.PutExtension(ext); err == nil {
+		t.Fatal("forged extension stored")
+	}
+}
+
+func TestVerifyStorageIntegrity(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("base content"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutExtension(root.Extend([]byte("delta"))); err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.VerifyStorageIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyStorageIntegrity: %v", err)
+	}
+	if !report.Valid() {
+		t.Fatalf("healthy store reported errors: %v", report.Errors)
+	}
+	if report.BlocksChecked == 0 || report.ExtensionsChecked == 0 {
+		t.Fatalf("report did not cover the store: %+v", report)
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	s := newTestStore(t)
+	keep, err := s.PutContent([]byte("keep me"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutContent([]byte("collect me")); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.GarbageCollect([]*crypto.Hash{keep})
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed = %d, want 1", removed)
+	}
+	if _, err := s.GetContent(keep); err != nil {
+		t.Fatalf("kept content lost: %v", err)
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("stats base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutExtension(root.Extend([]byte("stats delta"))); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.BlockCount != 2 {
+		t.Fatalf("BlockCount = %d, want 2", stats.BlockCount)
+	}
+	if stats.ExtensionCount != 1 {
+		t.Fatalf("ExtensionCount = %d, want 1", stats.ExtensionCount)
+	}
+	if stats.TotalSize == 0 {
+		t.Fatal("TotalSize not counted")
+	}
+}
+
+func TestListPageVisitsEveryBlockOnce(t *testing.T) {
+	s := newTestStore(t)
+	want := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		h, err := s.PutContent([]byte{byte(i), 'p', 'a', 'g', 'e'})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[h.Hex()] = true
+	}
+
+	seen := make(map[string]int)
+	cursor := ""
+	pages := 0
+	for {
+		hashes, next, err := s.Blocks.ListPage(cursor, 10)
+		if err != nil {
+			t.Fatalf("ListPage: %v", err)
+		}
+		pages++
+		for _, h := range hashes {
+			seen[h.Hex()]++
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != len(want) {
+		t.Fatalf("visited %d distinct blocks, want %d", len(seen), len(want))
+	}
+	for hex, n := range seen {
+		if !want[hex] {
+			t.Fatalf("ListPage returned unknown hash %s", hex)
+		}
+		if n != 1 {
+			t.Fatalf("hash %s visited %d times, want 1", hex, n)
+		}
+	}
+	if pages < 5 {
+		t.Fatalf("iterated %d pages of 10 for 50 blocks, want at least 5", pages)
+	}
+}
+
+func TestStatsCountersTrackMutations(t *testing.T) {
+	s := newTestStore(t)
+	first, err := s.PutContent([]byte("counter one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutContent([]byte("counter one")); err != nil { // duplicate
+		t.Fatal(err)
+	}
+	second, err := s.PutContent([]byte("counter two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BlockCount != 2 {
+		t.Fatalf("BlockCount = %d, want 2 (duplicate must not double-count)", stats.BlockCount)
+	}
+	wantSize := int64(len("counter one") + len("counter two"))
+	if stats.TotalSize != wantSize {
+		t.Fatalf("TotalSize = %d, want %d", stats.TotalSize, wantSize)
+	}
+
+	if err := s.Blocks.Delete(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Blocks.Delete(second); err != nil { // already gone
+		t.Fatal(err)
+	}
+	stats, err = s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BlockCount != 1 || stats.TotalSize != int64(len("counter one")) {
+		t.Fatalf("after delete: %+v", stats)
+	}
+
+	if _, err := s.PutContent([]byte("unreachable")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GarbageCollect([]*crypto.Hash{first}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BlockCount != 1 {
+		t.Fatalf("BlockCount = %d after GC, want 1", stats.BlockCount)
+	}
+}
+
+func TestRecountStatsRebuildsCounters(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("recount base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutExtension(root.Extend([]byte("recount delta"))); err != nil {
+		t.Fatal(err)
+	}
+
+	recounted, err := s.RecountStats()
+	if err != nil {
+		t.Fatalf("RecountStats: %v", err)
+	}
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != *recounted {
+		t.Fatalf("GetStats %+v disagrees with RecountStats %+v", stats, recounted)
+	}
+	if stats.BlockCount != 2 || stats.ExtensionCount != 1 {
+		t.Fatalf("recounted stats %+v, want 2 blocks and 1 extension", stats)
+	}
+}
+
+func TestCompressedStoreRoundTrips(t *testing.T) {
+	content := bytes.Repeat([]byte("the same sentence over and over "), 64)
+	for _, codec := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(codec), func(t *testing.T) {
+			plain := newTestStore(t)
+			compressed, err := NewStore(Config{InMemory: true, Compression: codec})
+			if err != nil {
+				t.Fatalf("NewStore(%s): %v", codec, err)
+			}
+			t.Cleanup(func() { compressed.Close() })
+
+			h1, err := plain.PutContent(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h2, err := compressed.PutContent(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !h1.Equal(h2) {
+				t.Fatal("compression changed the content address")
+			}
+
+			got, err := compressed.GetContent(h2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatal("compressed store returned different bytes")
+			}
+
+			block, err := compressed.Blocks.Get(h2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if block.Size != len(content) {
+				t.Fatalf("Size = %d, want uncompressed length %d", block.Size, len(content))
+			}
+		})
+	}
+}
+
+func TestUnknownCompressionRejected(t *testing.T) {
+	if _, err := NewStore(Config{InMemory: true, Compression: "lzma"}); err == nil {
+		t.Fatal("unknown codec accepted")
+	}
+}
+
+func TestIntegrityCountsOrphansAndBrokenChains(t *testing.T) {
+	s := newTestStore(t)
+
+	// A healthy chain: root block plus one verified extension.
+	root := core.NewContent([]byte("integrity base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutExtension(root.Extend([]byte("integrity delta"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// A block no extension record references.
+	if _, err := s.PutContent([]byte("nobody links to me")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A chain whose stored delta was tampered after the fact:
+	// the record claims parent -> child but the delta no longer
+	// produces that child.
+	other := core.NewContent([]byte("tamper base"))
+	if _, err := s.PutContent(other.Data); err != nil {
+		t.Fatal(err)
+	}
+	ext := other.Extend([]byte("original delta"))
+	err := s.Graph.AddExtension(&ExtensionRecord{
+		Parent: ext.Parent.Hex(),
+		Child:  ext.Child.Hex(),
+		Delta:  []byte("tampered delta"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.VerifyStorageIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyStorageIntegrity: %v", err)
+	}
+	if report.OrphanedBlocks != 1 {
+		t.Fatalf("OrphanedBlocks = %d, want 1", report.OrphanedBlocks)
+	}
+	if report.BrokenChains != 1 {
+		t.Fatalf("BrokenChains = %d, want 1", report.BrokenChains)
+	}
+	if report.Valid() {
+		t.Fatal("report with a broken chain must not be Valid")
+	}
+}
+
+func TestReconstructStreamMatchesInMemory(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("stream base "))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	tip := root.Extend([]byte("link one "))
+	if err := s.PutExtension(tip); err != nil {
+		t.Fatal(err)
+	}
+	for _, delta := range []string{"link two ", "link three"} {
+		next := core.NewExtension(tip.Child, []byte(delta))
+		if err := s.PutExtension(next); err != nil {
+			t.Fatal(err)
+		}
+		tip = next
+	}
+
+	want, err := s.Reconstruct(tip.Child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.Graph.ReconstructStream(s.Blocks, tip.Child, &buf); err != nil {
+		t.Fatalf("ReconstructStream: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("streamed %q differs from in-memory %q", buf.Bytes(), want)
+	}
+}
+
+func TestGetByPublisher(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("publisher base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := root.Extend([]byte("from alice"))
+	alice.Publisher = "peer-alice"
+	if err := s.PutExtension(alice); err != nil {
+		t.Fatal(err)
+	}
+	bob := root.Extend([]byte("from bob"))
+	bob.Publisher = "peer-bob"
+	if err := s.PutExtension(bob); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Graph.GetByPublisher("peer-alice")
+	if err != nil {
+		t.Fatalf("GetByPublisher: %v", err)
+	}
+	if len(got) != 1 || !got[0].Equal(alice.Child) {
+		t.Fatalf("GetByPublisher(alice) = %v, want just %s", got, alice.Child)
+	}
+
+	none, err := s.Graph.GetByPublisher("peer-nobody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("unknown publisher returned %d hashes", len(none))
+	}
+}
+
+func TestGetByTimeRange(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("time base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Controlled timestamps at 100ns intervals.
+	exts := make([]*core.Extension, 5)
+	for i := range exts {
+		ext := root.Extend([]byte{byte(i), 't', 'i', 'm', 'e'})
+		ext.Timestamp = int64(100 * (i + 1))
+		if err := s.PutExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+		exts[i] = ext
+	}
+
+	got, err := s.Graph.GetByTimeRange(200, 400)
+	if err != nil {
+		t.Fatalf("GetByTimeRange: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d hashes in [200,400], want 3 (boundaries inclusive)", len(got))
+	}
+	for i, want := range exts[1:4] {
+		if !got[i].Equal(want.Child) {
+			t.Fatalf("result %d = %s, want %s", i, got[i], want.Child)
+		}
+	}
+
+	all, err := s.Graph.GetByTimeRange(0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("full range returned %d hashes, want 5", len(all))
+	}
+	none, err := s.Graph.GetByTimeRange(600, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("empty range returned %d hashes", len(none))
+	}
+}
+
+func TestIdenticalDeltasStoredOnce(t *testing.T) {
+	s := newTestStore(t)
+	rootA := core.NewContent([]byte("thread a"))
+	rootB := core.NewContent([]byte("thread b"))
+	for _, root := range []*core.Content{rootA, rootB} {
+		if _, err := s.PutContent(root.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shared := []byte(" -- same closing remark")
+	extA := rootA.Extend(shared)
+	extB := rootB.Extend(shared)
+	if err := s.PutExtension(extA); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutExtension(extB); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two roots plus one shared delta block.
+	if stats.BlockCount != 3 {
+		t.Fatalf("BlockCount = %d, want 3 (shared delta stored once)", stats.BlockCount)
+	}
+
+	gotA, err := s.Reconstruct(extA.Child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]byte(nil), rootA.Data...), shared...); !bytes.Equal(gotA, want) {
+		t.Fatalf("Reconstruct(a) = %q, want %q", gotA, want)
+	}
+	gotB, err := s.Reconstruct(extB.Child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]byte(nil), rootB.Data...), shared...); !bytes.Equal(gotB, want) {
+		t.Fatalf("Reconstruct(b) = %q, want %q", gotB, want)
+	}
+}
+
+func TestGarbageCollectDryRunDeletesNothing(t *testing.T) {
+	s := newTestStore(t)
+	keep, err := s.PutContent([]byte("keep me"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doomedHash, err := s.PutContent([]byte("collect me"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doomed, err := s.GarbageCollectDryRun([]*crypto.Hash{keep})
+	if err != nil {
+		t.Fatalf("GarbageCollectDryRun: %v", err)
+	}
+	if len(doomed) != 1 || !doomed[0].Equal(doomedHash) {
+		t.Fatalf("dry run reported %v, want [%s]", doomed, doomedHash)
+	}
+	// Nothing was actually removed.
+	if _, err := s.Blocks.Get(doomedHash); err != nil {
+		t.Fatalf("dry run deleted a block: %v", err)
+	}
+
+	var logged []*crypto.Hash
+	removed, err := s.GarbageCollectWithCallback([]*crypto.Hash{keep}, func(h *crypto.Hash) {
+		logged = append(logged, h)
+	})
+	if err != nil {
+		t.Fatalf("GarbageCollectWithCallback: %v", err)
+	}
+	if removed != 1 || len(logged) != 1 || !logged[0].Equal(doomedHash) {
+		t.Fatalf("callback saw %v (removed %d), want [%s]", logged, removed, doomedHash)
+	}
+}
+
+func TestGarbageCollectKeepsReferencedDeltas(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("chain base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	ext := root.Extend([]byte(" shared delta"))
+	if err := s.PutExtension(ext); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GarbageCollect([]*crypto.Hash{root.Hash}); err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	// The delta block is not a chain node, but the live chain still
+	// references it; reconstruction must survive GC.
+	data, err := s.Reconstruct(ext.Child)
+	if err != nil {
+		t.Fatalf("Reconstruct after GC: %v", err)
+	}
+	if string(data) != "chain base shared delta" {
+		t.Fatalf("reconstructed %q", data)
+	}
+}
+
+func TestCompactPreservesContentAndHash(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("compact base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	tip := root.Hash
+	want := string(root.Data)
+	for _, delta := range []string{" one", " two", " three"} {
+		ext := core.NewExtension(tip, []byte(delta))
+		if err := s.PutExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+		tip = ext.Child
+		want += delta
+	}
+
+	compacted, err := s.Compact(tip)
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if !compacted.Equal(tip) {
+		t.Fatalf("compacted hash %s differs from chain tip %s", compacted, tip)
+	}
+
+	// Target now resolves in one block read, with the same content.
+	if chain, err := s.Graph.GetChain(tip); err != nil || len(chain) != 0 {
+		t.Fatalf("chain not collapsed: %d links, err %v", len(chain), err)
+	}
+	data, err := s.GetContent(tip)
+	if err != nil {
+		t.Fatalf("GetContent after compact: %v", err)
+	}
+	if string(data) != want {
+		t.Fatalf("compacted content %q, want %q", data, want)
+	}
+
+	// The compacted block still verifies against its hash through the
+	// recorded segment boundaries.
+	block, err := s.Blocks.Get(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentHash, err := block.ContentHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contentHash.Equal(tip) {
+		t.Fatal("compacted block fails content-hash verification")
+	}
+	report, err := s.VerifyStorageIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid() {
+		t.Fatalf("integrity audit after compact: %v", report.Errors)
+	}
+}
+
+func TestCompactRejectsNonChain(t *testing.T) {
+	s := newTestStore(t)
+	h, err := s.PutContent([]byte("just a root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Compact(h); err == nil {
+		t.Fatal("compacted a bare root block")
+	}
+}
+
+func TestTombstoneHidesContent(t *testing.T) {
+	s := newTestStore(t)
+	data := []byte("machine learning content to retract")
+	h, err := s.PutContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tomb := core.NewTombstone(h)
+	if err := s.PutExtension(tomb); err != nil {
+		t.Fatalf("PutExtension(tombstone): %v", err)
+	}
+
+	if _, err := s.GetContent(h); !errors.Is(err, ErrContentDeleted) {
+		t.Fatalf("GetContent after tombstone: %v, want ErrContentDeleted", err)
+	}
+	query := core.NewQuery("machine learning content", 0.1)
+	results, err := s.SearchSimilar(query, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Hash.Equal(h) {
+			t.Fatal("tombstoned content still surfaces in search")
+		}
+	}
+	// The raw block remains on disk for audit.
+	if _, err := s.Blocks.Get(h); err != nil {
+		t.Fatalf("raw block removed by tombstone: %v", err)
+	}
+}
+
+func TestBlockTTLExpiry(t *testing.T) {
+	s := newTestStore(t)
+	forever, err := s.PutContent([]byte("durable content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephemeral, err := s.PutContentWithTTL([]byte("ephemeral content"), 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := s.Blocks.Get(ephemeral); !IsNotFound(err) {
+		t.Fatalf("expired block still readable: %v", err)
+	}
+	if has, err := s.Blocks.Has(ephemeral); err != nil || has {
+		t.Fatalf("Has(expired) = %v, %v", has, err)
+	}
+	hashes, err := s.Blocks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range hashes {
+		if h.Equal(ephemeral) {
+			t.Fatal("expired block still listed")
+		}
+	}
+	if _, err := s.Blocks.Get(forever); err != nil {
+		t.Fatalf("non-TTL block lost: %v", err)
+	}
+	// Counters do not track expiry; a recount reconciles them.
+	stats, err := s.RecountStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BlockCount != 1 {
+		t.Fatalf("BlockCount after recount = %d, want 1", stats.BlockCount)
+	}
+}
+
+func TestVerifyChainFailureCodes(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("failure codes base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	ext := core.NewExtension(root.Hash, []byte(" tail"))
+	if err := s.PutExtension(ext); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrong root: the first link does not attach to it.
+	wrongRoot := crypto.HashElement([]byte("elsewhere"))
+	res, err := s.VerifyChain(wrongRoot, ext.Child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.Code != crypto.FailureChainBreak {
+		t.Fatalf("wrong root: code %q, want %q", res.Code, crypto.FailureChainBreak)
+	}
+
+	// Target with no chain at all: nothing reaches it.
+	res, err = s.VerifyChain(root.Hash, wrongRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.Code != crypto.FailureTargetNotReached {
+		t.Fatalf("unreachable target: code %q, want %q", res.Code, crypto.FailureTargetNotReached)
+	}
+
+	// A record whose delta cannot produce its claimed child.
+	forgedChild := crypto.HashElement([]byte("forged tip"))
+	err = s.Graph.AddExtension(&ExtensionRecord{
+		Parent:    root.Hash.Hex(),
+		Child:     forgedChild.Hex(),
+		DeltaHash: crypto.HashElement(ext.Delta).Hex(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = s.VerifyChain(root.Hash, forgedChild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.Code != crypto.FailureInvalidExtension {
+		t.Fatalf("forged record: code %q, want %q", res.Code, crypto.FailureInvalidExtension)
+	}
+	if res.FailedAt != 0 {
+		t.Fatalf("forged record FailedAt = %d, want 0", res.FailedAt)
+	}
+
+	// Delta block gone: verification needs data we do not hold.
+	if err := s.Blocks.Delete(crypto.HashElement(ext.Delta)); err != nil {
+		t.Fatal(err)
+	}
+	res, err = s.VerifyChain(root.Hash, ext.Child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.Code != crypto.FailureRecordNotFound {
+		t.Fatalf("missing delta: code %q, want %q", res.Code, crypto.FailureRecordNotFound)
+	}
+}
+
+func TestWalkChainVisitsRootToTarget(t *testing.T) {
+	s := newTestStore(t)
+	root := core.NewContent([]byte("walk base"))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	tip := root.Hash
+	var children []string
+	for _, delta := range []string{" one", " two", " three"} {
+		ext := core.NewExtension(tip, []byte(delta))
+		if err := s.PutExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+		tip = ext.Child
+		children = append(children, ext.Child.Hex())
+	}
+
+	var visited []string
+	err := s.WalkChain(tip, func(rec *ExtensionRecord) error {
+		visited = append(visited, rec.Child)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkChain: %v", err)
+	}
+	if len(visited) != len(children) {
+		t.Fatalf("visited %d records, want %d", len(visited), len(children))
+	}
+	for i := range children {
+		if visited[i] != children[i] {
+			t.Fatalf("visit %d = %s, want %s (not root-to-target order)", i, visited[i], children[i])
+		}
+	}
+
+	// An error from fn halts the walk and propagates.
+	sentinel := errors.New("stop here")
+	visited = visited[:0]
+	err = s.WalkChain(tip, func(rec *ExtensionRecord) error {
+		visited = append(visited, rec.Child)
+		return sentinel
+	})
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("WalkChain error = %v, want sentinel", err)
+	}
+	if len(visited) != 1 {
+		t.Fatalf("fn called %d times after error, want 1", len(visited))
+	}
+
+	// A target with no chain walks nothing.
+	if err := s.WalkChain(root.Hash, func(*ExtensionRecord) error {
+		t.Fatal("fn called for chainless target")
+		return nil
+	}); err != nil {
+		t.Fatalf("WalkChain on root: %v", err)
+	}
+}
+
+func TestGetManyMixedPresence(t *testing.T) {
+	s := newTestStore(t)
+
+	var hashes []*crypto.Hash
+	for i := 0; i < 3; i++ {
+		h, err := s.Blocks.Put([]byte(fmt.Sprintf("block number %d", i)))
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		hashes = append(hashes, h)
+	}
+	missing := crypto.HashElement([]byte("never stored"))
+	// A duplicate request for the same hash is served from the first
+	// fetch, not failed.
+	request := append(append([]*crypto.Hash{}, hashes...), missing, hashes[0])
+
+	blocks, err := s.Blocks.GetMany(request)
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if len(blocks) != len(hashes) {
+		t.Fatalf("got %d blocks, want %d", len(blocks), len(hashes))
+	}
+	if _, ok := blocks[missing.Hex()]; ok {
+		t.Fatal("missing hash appeared in the result")
+	}
+	for i, h := range hashes {
+		got, ok := blocks[h.Hex()]
+		if !ok {
+			t.Fatalf("block %d missing from result", i)
+		}
+		want, err := s.Blocks.Get(h)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !bytes.Equal(got.Data, want.Data) {
+			t.Fatalf("block %d data differs between GetMany and Get", i)
+		}
+	}
+}
+
+func BenchmarkGetManyVsLoopedGet(b *testing.B) {
+	s := newTestStore(b)
+	var hashes []*crypto.Hash
+	for i := 0; i < 100; i++ {
+		h, err := s.Blocks.Put([]byte(fmt.Sprintf("benchmark block %d", i)))
+		if err != nil {
+			b.Fatalf("Put: %v", err)
+		}
+		hashes = append(hashes, h)
+	}
+
+	b.Run("GetMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.Blocks.GetMany(hashes); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("LoopedGet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, h := range hashes {
+				if _, err := s.Blocks.Get(h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestExtendContentReconstructs(t *testing.T) {
+	s := newTestStore(t)
+
+	parentData := []byte("the original document")
+	parent, err := s.PutContent(parentData)
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+
+	delta := []byte(" plus an appended delta")
+	child, err := s.ExtendContent(parent, delta)
+	if err != nil {
+		t.Fatalf("ExtendContent: %v", err)
+	}
+	if !crypto.VerifyExtension(parent, child, delta) {
+		t.Fatal("returned child hash does not verify against parent+delta")
+	}
+	got, err := s.Reconstruct(child)
+	if err != nil {
+		t.Fatalf("Reconstruct: %v", err)
+	}
+	if want := append(append([]byte{}, parentData...), delta...); !bytes.Equal(got, want) {
+		t.Fatalf("reconstructed %q, want %q", got, want)
+	}
+
+	// The chain tip is not itself a block, only a graph record; it
+	// must still be extendable.
+	delta2 := []byte(", then another")
+	child2, err := s.ExtendContent(child, delta2)
+	if err != nil {
+		t.Fatalf("ExtendContent from tip: %v", err)
+	}
+	got, err = s.Reconstruct(child2)
+	if err != nil {
+		t.Fatalf("Reconstruct tip: %v", err)
+	}
+	if want := append(append(append([]byte{}, parentData...), delta...), delta2...); !bytes.Equal(got, want) {
+		t.Fatalf("reconstructed %q, want %q", got, want)
+	}
+
+	// Content the store has never seen cannot be extended.
+	unknown := crypto.HashElement([]byte("never stored here"))
+	if _, err := s.ExtendContent(unknown, delta); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("extending unknown parent: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyChainFromTarget(t *testing.T) {
+	s := newTestStore(t)
+
+	root := core.NewContent([]byte("root of a chain. "))
+	if _, err := s.PutContent(root.Data); err != nil {
+		t.Fatal(err)
+	}
+	ext1 := root.Extend([]byte("first delta. "))
+	if err := s.PutExtension(ext1); err != nil {
+		t.Fatal(err)
+	}
+	ext2 := core.NewExtension(ext1.Child, []byte("second delta."))
+	if err := s.PutExtension(ext2); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.VerifyChainFromTarget(ext2.Child)
+	if err != nil {
+		t.Fatalf("VerifyChainFromTarget: %v", err)
+	}
+	if !res.Valid {
+		t.Fatalf("chain invalid: %s", res.Reason)
+	}
+	if res.ChainLength != 2 {
+		t.Fatalf("ChainLength = %d, want 2", res.ChainLength)
+	}
+	if res.RootHash == nil || !res.RootHash.Equal(root.Hash) {
+		t.Fatalf("discovered root %v, want %s", res.RootHash, root.Hash)
+	}
+}
+
+func TestBadgerTuningApplied(t *testing.T) {
+	tuning := SmallBlocksTuning()
+	s, err := NewStore(Config{Path: t.TempDir(), Tuning: tuning})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	opts := s.db.Opts()
+	if opts.ValueThreshold != tuning.ValueThreshold {
+		t.Fatalf("ValueThreshold = %d, want %d", opts.ValueThreshold, tuning.ValueThreshold)
+	}
+	if opts.ValueLogFileSize != tuning.ValueLogFileSize {
+		t.Fatalf("ValueLogFileSize = %d, want %d", opts.ValueLogFileSize, tuning.ValueLogFileSize)
+	}
+	if opts.BlockCacheSize != tuning.BlockCacheSize {
+		t.Fatalf("BlockCacheSize = %d, want %d", opts.BlockCacheSize, tuning.BlockCacheSize)
+	}
+
+	// A value under the raised threshold stays in the LSM tree, and
+	// the store still round-trips it.
+	data := bytes.Repeat([]byte("x"), 4<<10)
+	hash, err := s.PutContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetContent(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("tuned store did not round-trip content")
+	}
+
+	// Large-blocks preset applies its compactor count.
+	large, err := NewStore(Config{Path: t.TempDir(), Tuning: LargeBlocksTuning()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { large.Close() })
+	if got, want := large.db.Opts().NumCompactors, LargeBlocksTuning().NumCompactors; got != want {
+		t.Fatalf("NumCompactors = %d, want %d", got, want)
+	}
+}
+
+// corruptBlock flips a byte of the stored block value in place,
+// simulating bit rot under the store's feet.
+func corruptBlock(t *testing.T, s *Store, hash *crypto.Hash) {
+	t.Helper()
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(blockKey(hash))
+		if err != nil {
+			return err
+		}
+		var block Block
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &block)
+		}); err != nil {
+			return err
+		}
+		block.Data[0] ^= 0xff
+		value, err := json.Marshal(&block)
+		if err != nil {
+			return err
+		}
+		return txn.Set(blockKey(hash), value)
+	})
+	if err != nil {
+		t.Fatalf("corrupt block: %v", err)
+	}
+}
+
+func TestGetContentVerifiedCatchesCorruption(t *testing.T) {
+	s := newTestStore(t)
+	data := []byte("content whose bytes will be flipped on disk")
+	hash, err := s.PutContent(data)
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	corruptBlock(t, s, hash)
+
+	// The unverified path hands back whatever is on disk.
+	got, err := s.GetContent(hash)
+	if err != nil {
+		t.Fatalf("GetContent after corruption: %v", err)
+	}
+	if bytes.Equal(got, data) {
+		t.Fatal("corruption did not take")
+	}
+
+	if _, err := s.GetContentVerified(hash); !errors.Is(err, ErrBlockCorrupted) {
+		t.Fatalf("GetContentVerified error = %v, want ErrBlockCorrupted", err)
+	}
+}
+
+func TestVerifyOnReadConfig(t *testing.T) {
+	s, err := NewStore(Config{InMemory: true, VerifyOnRead: true})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	data := []byte("verified-store content")
+	hash, err := s.PutContent(data)
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	if got, err := s.GetContent(hash); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("verified read of intact block: %q, %v", got, err)
+	}
+	corruptBlock(t, s, hash)
+	if _, err := s.GetContent(hash); !errors.Is(err, ErrBlockCorrupted) {
+		t.Fatalf("GetContent on verifying store = %v, want ErrBlockCorrupted", err)
+	}
+}
+
+func TestIncrementalBackupRestore(t *testing.T) {
+	s := newTestStore(t)
+	first := []byte("the base backup holds this document")
+	firstHash, err := s.PutContent(first)
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+
+	var base bytes.Buffer
+	baseVersion, err := s.BackupSince(&base, 0)
+	if err != nil {
+		t.Fatalf("BackupSince(0): %v", err)
+	}
+	if baseVersion == 0 {
+		t.Fatal("full backup returned version 0")
+	}
+
+	// Changes after the full backup land in the incremental only.
+	second := append(first, []byte(" and the incremental extends it")...)
+	secondHash, err := s.ExtendContent(firstHash, []byte(" and the incremental extends it"))
+	if err != nil {
+		t.Fatalf("ExtendContent: %v", err)
+	}
+	var incr bytes.Buffer
+	incrVersion, err := s.BackupSince(&incr, baseVersion)
+	if err != nil {
+		t.Fatalf("BackupSince(%d): %v", baseVersion, err)
+	}
+	if incrVersion <= baseVersion {
+		t.Fatalf("incremental version %d not past base version %d", incrVersion, baseVersion)
+	}
+	if last, err := s.LastBackupVersion(); err != nil || last != incrVersion {
+		t.Fatalf("LastBackupVersion = %d, %v, want %d", last, err, incrVersion)
+	}
+
+	// A fresh store rebuilt from base plus incremental holds both
+	// generations of content.
+	fresh := newTestStore(t)
+	if err := fresh.Restore(&base); err != nil {
+		t.Fatalf("restore base: %v", err)
+	}
+	if got, err := fresh.GetContent(firstHash); err != nil || !bytes.Equal(got, first) {
+		t.Fatalf("base content after base restore: %q, %v", got, err)
+	}
+	if _, err := fresh.GetContent(secondHash); err == nil {
+		t.Fatal("extension present before the incremental was applied")
+	}
+	if err := fresh.Restore(&incr); err != nil {
+		t.Fatalf("restore incremental: %v", err)
+	}
+	if got, err := fresh.GetContent(secondHash); err != nil || !bytes.Equal(got, second) {
+		t.Fatalf("extended content after incremental restore: %q, %v", got, err)
+	}
+}
+
+func TestSubscribeDeliversCommittedEvents(t *testing.T) {
+	s := newTestStore(t)
+	events, cancel := s.Subscribe()
+	defer cancel()
+
+	hash, err := s.PutContent([]byte("watched content"))
+	if err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	child, err := s.ExtendContent(hash, []byte(" with a delta"))
+	if err != nil {
+		t.Fatalf("ExtendContent: %v", err)
+	}
+	if err := s.MarkDeleted(child); err != nil {
+		t.Fatalf("MarkDeleted: %v", err)
+	}
+
+	want := []StoreEvent{
+		{Type: ContentPut, Hash: hash},
+		{Type: ExtensionAdded, Hash: child},
+		{Type: ContentDeleted, Hash: child},
+	}
+	for i, w := range want {
+		select {
+		case got := <-events:
+			if got.Type != w.Type || !got.Hash.Equal(w.Hash) {
+				t.Fatalf("event %d = {%s %s}, want {%s %s}",
+					i, got.Type, got.Hash, w.Type, w.Hash)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d (%s) never delivered", i, w.Type)
+		}
+	}
+
+	// After cancel the channel closes and later writes reach nobody;
+	// cancelling twice is harmless.
+	cancel()
+	if _, err := s.PutContent([]byte("unwatched content")); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	if ev, ok := <-events; ok {
+		t.Fatalf("event %s delivered after unsubscribe", ev.Type)
+	}
+	cancel()
+}